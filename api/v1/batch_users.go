@@ -0,0 +1,383 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/clintrovert/go-playground/api/model"
+	"github.com/clintrovert/go-playground/pkg/identity"
+	database2 "github.com/clintrovert/go-playground/pkg/postgres/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const maxBatchSize = 1000
+
+var (
+	ErrBatchEmpty          = errors.New("batch contained no users")
+	ErrBatchTooLarge       = errors.New("batch contained more than 1000 users")
+	ErrBatchModeInvalid    = errors.New("batch mode was invalid")
+	ErrUserEmailDuplicated = errors.New("user email appeared more than once in the batch")
+	ErrUserEmailTaken      = errors.New("user email was already taken")
+)
+
+// batchItemError is the error of a single item of a batch, which fails the
+// whole batch in atomic mode.
+type batchItemError struct {
+	index int
+	err   error
+}
+
+func (e *batchItemError) Error() string {
+	return fmt.Sprintf("users[%d]: %v", e.index, e.err)
+}
+
+func (e *batchItemError) Unwrap() error {
+	return e.err
+}
+
+// batchItem is the outcome of a single item of a batch. Failed items hold
+// the gRPC status error reported for them.
+type batchItem struct {
+	user *database2.User
+	err  error
+}
+
+// BatchCreateUsers creates many Users, hashing their passwords in parallel
+// and inserting them in a single statement.
+func (s *UserService) BatchCreateUsers(
+	ctx context.Context,
+	request *model.BatchCreateUsersRequest,
+) (*model.BatchCreateUsersResponse, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if err := validateBatch(len(request.Users), request.Mode); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	atomic := request.Mode != model.BatchMode_BATCH_MODE_BEST_EFFORT
+
+	items := make([]batchItem, len(request.Users))
+	passwords := make([]string, len(request.Users))
+	emails := make(map[string]bool, len(request.Users))
+	for i, user := range request.Users {
+		if err := validateCreateUserRequest(user); err != nil {
+			items[i].err = status.Error(codes.InvalidArgument, err.Error())
+			continue
+		}
+		email := strings.ToLower(strings.TrimSpace(user.Email))
+		if emails[email] {
+			items[i].err = status.Error(codes.AlreadyExists, ErrUserEmailDuplicated.Error())
+			continue
+		}
+		emails[email] = true
+		passwords[i] = user.Password
+	}
+	if err := batchFailure(items, atomic); err != nil {
+		return nil, batchError(ctx, err, ErrUserCreateFailed)
+	}
+
+	hashes, errs := s.hashPasswords(ctx, passwords)
+	var params database2.CreateUsersParams
+	pending := map[string]int{}
+	for i, user := range request.Users {
+		if items[i].err != nil {
+			continue
+		}
+		if errs[i] != nil {
+			s.logger(ctx).Error(errs[i])
+			items[i].err = status.Error(codes.Internal, ErrUserCreateFailed.Error())
+			continue
+		}
+		email := strings.TrimSpace(user.Email)
+		pending[strings.ToLower(email)] = i
+		params.Names = append(params.Names, strings.TrimSpace(user.Name))
+		params.Emails = append(params.Emails, email)
+		params.Passwords = append(params.Passwords, hashes[i])
+		params.IsAdmins = append(params.IsAdmins, user.IsAdmin)
+	}
+	if err := batchFailure(items, atomic); err != nil {
+		return nil, batchError(ctx, err, ErrUserCreateFailed)
+	}
+
+	if len(pending) > 0 {
+		if err := s.write(ctx, func(ctx context.Context, db UserDatabase) error {
+			created, err := db.CreateUsers(ctx, params)
+			if err != nil {
+				return err
+			}
+
+			// Users whose email was already taken are skipped by the insert.
+			byEmail := make(map[string]database2.User, len(created))
+			for _, user := range created {
+				byEmail[strings.ToLower(user.Email)] = user
+			}
+			for email, i := range pending {
+				user, ok := byEmail[email]
+				items[i] = batchItem{}
+				if !ok {
+					items[i].err = status.Error(codes.AlreadyExists, ErrUserEmailTaken.Error())
+					continue
+				}
+				items[i].user = &user
+			}
+			return batchFailure(items, atomic)
+		}); err != nil {
+			s.logger(ctx).Error(err)
+			return nil, batchError(ctx, err, ErrUserCreateFailed)
+		}
+	}
+
+	return &model.BatchCreateUsersResponse{Results: toBatchResults(items)}, nil
+}
+
+// BatchUpdateUsers applies many updates, each of which behaves as an
+// UpdateUser request.
+func (s *UserService) BatchUpdateUsers(
+	ctx context.Context,
+	request *model.BatchUpdateUsersRequest,
+) (*model.BatchUpdateUsersResponse, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if err := validateBatch(len(request.Users), request.Mode); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	atomic := request.Mode != model.BatchMode_BATCH_MODE_BEST_EFFORT
+
+	items := make([]batchItem, len(request.Users))
+	params := make([]database2.UpdateUserParams, len(request.Users))
+	passwords := make([]string, len(request.Users))
+	for i, user := range request.Users {
+		fields, err := validateUpdateUserRequest(user)
+		if err != nil {
+			items[i].err = status.Error(codes.InvalidArgument, err.Error())
+			continue
+		}
+		params[i] = updateUserParams(user, fields)
+		if fields[userFieldPassword] {
+			passwords[i] = user.Password
+		}
+	}
+	if err := batchFailure(items, atomic); err != nil {
+		return nil, batchError(ctx, err, ErrUserUpdateFailed)
+	}
+
+	hashes, errs := s.hashPasswords(ctx, passwords)
+	for i := range params {
+		if errs[i] != nil {
+			s.logger(ctx).Error(errs[i])
+			items[i].err = status.Error(codes.Internal, ErrUserUpdateFailed.Error())
+		} else if passwords[i] != "" {
+			params[i].Password.String, params[i].Password.Valid = hashes[i], true
+		}
+	}
+	if err := batchFailure(items, atomic); err != nil {
+		return nil, batchError(ctx, err, ErrUserUpdateFailed)
+	}
+
+	if err := s.batchWrite(ctx, items, atomic, ErrUserUpdateFailed, func(
+		ctx context.Context,
+		db UserDatabase,
+		i int,
+	) error {
+		updated, err := updateUser(ctx, db, params[i])
+		if err != nil {
+			return err
+		}
+		items[i].user = &updated
+		return nil
+	}); err != nil {
+		s.logger(ctx).Error(err)
+		return nil, batchError(ctx, err, ErrUserUpdateFailed)
+	}
+
+	return &model.BatchUpdateUsersResponse{Results: toBatchResults(items)}, nil
+}
+
+// BatchDeleteUsers applies many deletions, each of which behaves as a
+// DeleteUser request.
+func (s *UserService) BatchDeleteUsers(
+	ctx context.Context,
+	request *model.BatchDeleteUsersRequest,
+) (*model.BatchDeleteUsersResponse, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if err := validateBatch(len(request.Users), request.Mode); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	atomic := request.Mode != model.BatchMode_BATCH_MODE_BEST_EFFORT
+
+	items := make([]batchItem, len(request.Users))
+	for i, user := range request.Users {
+		if err := validateDeleteUserRequest(user); err != nil {
+			items[i].err = status.Error(codes.InvalidArgument, err.Error())
+		} else if user.Hard && !identity.IsAdmin(ctx) {
+			items[i].err = status.Error(codes.PermissionDenied, ErrUserHardDeleteDenied.Error())
+		}
+	}
+	if err := batchFailure(items, atomic); err != nil {
+		return nil, batchError(ctx, err, ErrUserDeletionFailed)
+	}
+
+	if err := s.batchWrite(ctx, items, atomic, ErrUserDeletionFailed, func(
+		ctx context.Context,
+		db UserDatabase,
+		i int,
+	) error {
+		return deleteUser(ctx, db, request.Users[i])
+	}); err != nil {
+		s.logger(ctx).Error(err)
+		return nil, batchError(ctx, err, ErrUserDeletionFailed)
+	}
+
+	return &model.BatchDeleteUsersResponse{Results: toBatchResults(items)}, nil
+}
+
+// batchWrite applies fn to each item which has not failed. Atomic batches
+// are applied in a single transaction, while best effort batches apply each
+// item in its own transaction and record its failure, reported as fallback
+// when it is not one the caller can act upon.
+func (s *UserService) batchWrite(
+	ctx context.Context,
+	items []batchItem,
+	atomic bool,
+	fallback error,
+	fn func(ctx context.Context, db UserDatabase, i int) error,
+) error {
+	if atomic {
+		return s.write(ctx, func(ctx context.Context, db UserDatabase) error {
+			for i := range items {
+				if err := fn(ctx, db, i); err != nil {
+					return &batchItemError{index: i, err: err}
+				}
+			}
+			return nil
+		})
+	}
+
+	for i := range items {
+		if items[i].err != nil {
+			continue
+		}
+		if err := s.write(ctx, func(ctx context.Context, db UserDatabase) error {
+			return fn(ctx, db, i)
+		}); err != nil {
+			if ctxErr := contextError(ctx); ctxErr != nil {
+				return ctxErr
+			}
+			s.logger(ctx).WithField("index", i).Error(err)
+			items[i].err = itemStatus(ctx, err, fallback)
+		}
+	}
+	return nil
+}
+
+// hashPasswords bcrypt hashes passwords with at most the service's hash
+// concurrency in flight, returning the hash or error of each. Empty
+// passwords are not hashed.
+func (s *UserService) hashPasswords(
+	ctx context.Context,
+	passwords []string,
+) ([]string, []error) {
+	hashes := make([]string, len(passwords))
+	errs := make([]error, len(passwords))
+	limit := make(chan struct{}, s.hashConcurrency)
+
+	var wg sync.WaitGroup
+	for i, password := range passwords {
+		if password == "" {
+			continue
+		}
+
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(i int, password string) {
+			defer func() {
+				<-limit
+				wg.Done()
+			}()
+			if errs[i] = ctx.Err(); errs[i] == nil {
+				hashes[i], errs[i] = hashPassword(password)
+			}
+		}(i, password)
+	}
+	wg.Wait()
+
+	return hashes, errs
+}
+
+// batchFailure returns the error failing an atomic batch, which is that of
+// its first failed item.
+func batchFailure(items []batchItem, atomic bool) error {
+	if !atomic {
+		return nil
+	}
+	for i, item := range items {
+		if item.err != nil {
+			return &batchItemError{index: i, err: item.err}
+		}
+	}
+	return nil
+}
+
+// batchError translates the error failing a batch into the gRPC status
+// returned to the caller, naming the item which failed.
+func batchError(ctx context.Context, err error, fallback error) error {
+	var itemErr *batchItemError
+	if !errors.As(err, &itemErr) {
+		return databaseError(ctx, err, fallback)
+	}
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return ctxErr
+	}
+
+	st := status.Convert(itemStatus(ctx, itemErr.err, fallback))
+	return status.Errorf(st.Code(), "users[%d]: %s", itemErr.index, st.Message())
+}
+
+// itemStatus translates the error of a single item into the gRPC status
+// reported for it.
+func itemStatus(ctx context.Context, err error, fallback error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return databaseError(ctx, err, fallback)
+}
+
+func toBatchResults(items []batchItem) []*model.BatchUserResult {
+	results := make([]*model.BatchUserResult, len(items))
+	for i, item := range items {
+		results[i] = &model.BatchUserResult{Index: int32(i)}
+		if item.user != nil {
+			results[i].User = toUserModel(*item.user)
+		}
+		if item.err != nil {
+			results[i].Error = &model.BatchItemError{
+				Code:    int32(status.Code(item.err)),
+				Message: status.Convert(item.err).Message(),
+			}
+		}
+	}
+	return results
+}
+
+func validateBatch(size int, mode model.BatchMode) error {
+	if size == 0 {
+		return ErrBatchEmpty
+	}
+	if size > maxBatchSize {
+		return ErrBatchTooLarge
+	}
+	if _, ok := model.BatchMode_name[int32(mode)]; !ok {
+		return ErrBatchModeInvalid
+	}
+	return nil
+}
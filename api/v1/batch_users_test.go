@@ -0,0 +1,116 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/clintrovert/go-playground/api/model"
+	"github.com/clintrovert/go-playground/internal/test/utils"
+	"github.com/clintrovert/go-playground/pkg/postgres/database"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBatchCreateUsers_BestEffort_ShouldReportEachItem(t *testing.T) {
+	tester := newTestUserService(t)
+	created, taken := utils.GenerateRandomUser(), utils.GenerateRandomUser()
+	request := &model.BatchCreateUsersRequest{
+		Mode: model.BatchMode_BATCH_MODE_BEST_EFFORT,
+		Users: []*model.CreateUserRequest{
+			{Name: created.Name, Email: created.Email, Password: "secret"},
+			{Name: "invalid", Email: "not an email", Password: "secret"},
+			{Name: taken.Name, Email: taken.Email, Password: "secret"},
+		},
+	}
+
+	tester.database.EXPECT().
+		CreateUsers(tester.ctx, gomock.Any()).
+		DoAndReturn(func(
+			_ context.Context,
+			params database.CreateUsersParams,
+		) ([]database.User, error) {
+			assert.Equal(t, []string{created.Email, taken.Email}, params.Emails)
+			assert.NoError(t, bcrypt.CompareHashAndPassword(
+				[]byte(params.Passwords[0]), []byte("secret"),
+			))
+			return []database.User{created}, nil
+		}).
+		Times(1)
+
+	response, err := tester.service.BatchCreateUsers(tester.ctx, request)
+	assert.NoError(t, err)
+	assert.Len(t, response.Results, 3)
+	assert.Equal(t, created.UserID, response.Results[0].User.Id)
+	assert.Nil(t, response.Results[0].Error)
+	assert.Equal(t, int32(codes.InvalidArgument), response.Results[1].Error.Code)
+	assert.Equal(t, int32(codes.AlreadyExists), response.Results[2].Error.Code)
+}
+
+func TestBatchCreateUsers_AtomicWithInvalidItem_ShouldFailBatch(t *testing.T) {
+	tester := newTestUserService(t)
+	request := &model.BatchCreateUsersRequest{
+		Users: []*model.CreateUserRequest{
+			{Name: "valid", Email: "valid@example.com", Password: "secret"},
+			{Name: "invalid", Email: "", Password: "secret"},
+		},
+	}
+
+	response, err := tester.service.BatchCreateUsers(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.True(t, strings.HasPrefix(status.Convert(err).Message(), "users[1]: "))
+}
+
+func TestBatchUpdateUsers_AtomicStaleVersion_ShouldReturnAborted(t *testing.T) {
+	tester := newTestUserService(t)
+	first, second := utils.GenerateRandomUser(), utils.GenerateRandomUser()
+	request := &model.BatchUpdateUsersRequest{
+		Users: []*model.UpdateUserRequest{
+			{Id: first.UserID + 1, Name: "first", Email: first.Email, Password: "secret"},
+			{Id: second.UserID + 1, Name: "second", Email: second.Email, Password: "secret", Version: 3},
+		},
+	}
+
+	gomock.InOrder(
+		tester.database.EXPECT().
+			UpdateUser(tester.ctx, gomock.Any()).
+			Return(first, nil),
+		tester.database.EXPECT().
+			UpdateUser(tester.ctx, gomock.Any()).
+			Return(database.User{}, sql.ErrNoRows),
+		tester.database.EXPECT().
+			GetUser(tester.ctx, second.UserID+1).
+			Return(second, nil),
+	)
+
+	response, err := tester.service.BatchUpdateUsers(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	assert.True(t, strings.HasPrefix(status.Convert(err).Message(), "users[1]: "))
+}
+
+func TestBatchDeleteUsers_HardAsNonAdmin_ShouldOnlyDenyHardDeletes(t *testing.T) {
+	tester := newTestUserService(t)
+	request := &model.BatchDeleteUsersRequest{
+		Mode: model.BatchMode_BATCH_MODE_BEST_EFFORT,
+		Users: []*model.DeleteUserRequest{
+			{UserId: 1},
+			{UserId: 2, Hard: true},
+		},
+	}
+
+	tester.database.EXPECT().
+		SoftDeleteUser(tester.ctx, database.SoftDeleteUserParams{UserID: 1}).
+		Return(int64(1), nil).
+		Times(1)
+
+	response, err := tester.service.BatchDeleteUsers(tester.ctx, request)
+	assert.NoError(t, err)
+	assert.Nil(t, response.Results[0].Error)
+	assert.Equal(t, int32(codes.PermissionDenied), response.Results[1].Error.Code)
+}
@@ -0,0 +1,69 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/clintrovert/go-playground/pkg/postgres"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrVersionMismatch is returned when a conditional write names a version
+// which is no longer current, because the record was modified concurrently.
+var ErrVersionMismatch = errors.New("record was modified concurrently, version did not match")
+
+// ErrTransactorMissing is returned by write paths which must run within a
+// transaction when the service was created without a Transactor.
+var ErrTransactorMissing = errors.New("operation requires transactions, which are not configured")
+
+// databaseError translates an error returned by a database call into the
+// gRPC status returned to the caller. Failures which the caller cannot act
+// upon are reported as Internal with the fallback message, so that driver
+// details are not leaked.
+func databaseError(ctx context.Context, err error, fallback error) error {
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return ctxErr
+	}
+
+	err = postgres.Classify(err)
+	switch {
+	case errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	case errors.Is(err, ErrTransactorMissing):
+		return status.Error(codes.Unimplemented, ErrTransactorMissing.Error())
+	case errors.Is(err, ErrVersionMismatch):
+		return status.Error(codes.Aborted, ErrVersionMismatch.Error())
+	case errors.Is(err, postgres.ErrNotFound):
+		return status.Error(codes.NotFound, postgres.ErrNotFound.Error())
+	case errors.Is(err, postgres.ErrUniqueViolation):
+		return status.Error(codes.AlreadyExists, postgres.ErrUniqueViolation.Error())
+	case errors.Is(err, postgres.ErrForeignKeyViolation):
+		return status.Error(codes.FailedPrecondition, postgres.ErrForeignKeyViolation.Error())
+	case errors.Is(err, postgres.ErrCheckViolation):
+		return status.Error(codes.InvalidArgument, postgres.ErrCheckViolation.Error())
+	case errors.Is(err, postgres.ErrSerializationFailure):
+		return status.Error(codes.Aborted, postgres.ErrSerializationFailure.Error())
+	case errors.Is(err, postgres.ErrConnection):
+		return status.Error(codes.Unavailable, postgres.ErrConnection.Error())
+	default:
+		return status.Error(codes.Internal, fallback.Error())
+	}
+}
+
+// optionalVersion converts a request's version, where zero means the write
+// is unconditional, into a query parameter.
+func optionalVersion(version int32) sql.NullInt32 {
+	return sql.NullInt32{Int32: version, Valid: version > 0}
+}
+
+// contextError returns the status matching a request which was cancelled or
+// ran out of time before the database call completed.
+func contextError(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return nil
+}
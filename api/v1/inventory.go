@@ -0,0 +1,277 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/clintrovert/go-playground/api/model"
+	database2 "github.com/clintrovert/go-playground/pkg/postgres/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const reservationLogField = "reservation_id"
+
+var (
+	ErrReservationIdInvalid       = errors.New("reservation id was not specified")
+	ErrReservationQuantityInvalid = errors.New("reservation quantity must be positive")
+	ErrReservationNotPending      = errors.New("reservation was already committed, released or expired")
+	ErrReservationExpired         = errors.New("reservation expired")
+	ErrStockInsufficient          = errors.New("product stock was insufficient")
+	ErrReserveStockFailed         = errors.New("stock reservation failed")
+	ErrReleaseStockFailed         = errors.New("stock release failed")
+	ErrCommitReservationFailed    = errors.New("reservation commit failed")
+)
+
+var reservationStatuses = map[database2.ReservationStatus]model.ReservationStatus{
+	database2.ReservationStatusPending:   model.ReservationStatus_RESERVATION_STATUS_PENDING,
+	database2.ReservationStatusCommitted: model.ReservationStatus_RESERVATION_STATUS_COMMITTED,
+	database2.ReservationStatusReleased:  model.ReservationStatus_RESERVATION_STATUS_RELEASED,
+	database2.ReservationStatusExpired:   model.ReservationStatus_RESERVATION_STATUS_EXPIRED,
+}
+
+// ReserveStock deducts a quantity from a Product's stock and holds it in a
+// pending Reservation, which expires unless committed in time.
+func (s *ProductService) ReserveStock(
+	ctx context.Context,
+	request *model.ReserveStockRequest,
+) (*model.ReserveStockResponse, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if request.ProductId < 1 {
+		return nil, status.Error(codes.InvalidArgument, ErrProductIdInvalid.Error())
+	}
+	if request.Quantity < 1 {
+		return nil, status.Error(codes.InvalidArgument, ErrReservationQuantityInvalid.Error())
+	}
+
+	var reservation database2.StockReservation
+	if err := s.lockingWrite(ctx, func(ctx context.Context, db ProductDatabase) error {
+		product, err := db.GetProductForUpdate(ctx, request.ProductId)
+		if err != nil {
+			return err
+		}
+		if product.Stock < request.Quantity {
+			return ErrStockInsufficient
+		}
+
+		if err = db.AdjustProductStock(ctx, database2.AdjustProductStockParams{
+			ProductID: request.ProductId,
+			Delta:     -request.Quantity,
+		}); err != nil {
+			return err
+		}
+
+		reservation, err = db.CreateReservation(ctx, database2.CreateReservationParams{
+			ProductID: request.ProductId,
+			Quantity:  request.Quantity,
+			ExpiresAt: time.Now().Add(s.reservationTtl),
+		})
+		return err
+	}); err != nil {
+		s.logger(ctx).
+			WithField(productLogField, request.ProductId).
+			Error(err)
+		if errors.Is(err, ErrStockInsufficient) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, databaseError(ctx, err, ErrReserveStockFailed)
+	}
+
+	return &model.ReserveStockResponse{
+		Reservation: toReservationModel(reservation),
+	}, nil
+}
+
+// ReleaseStock returns the quantity held by a pending Reservation to its
+// Product's stock.
+func (s *ProductService) ReleaseStock(
+	ctx context.Context,
+	request *model.ReleaseStockRequest,
+) (*model.ReleaseStockResponse, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if request.ReservationId < 1 {
+		return nil, status.Error(codes.InvalidArgument, ErrReservationIdInvalid.Error())
+	}
+
+	var released database2.StockReservation
+	if err := s.lockingWrite(ctx, func(ctx context.Context, db ProductDatabase) error {
+		reservation, err := db.GetReservationForUpdate(ctx, request.ReservationId)
+		if err != nil {
+			return err
+		}
+		if reservation.Status != database2.ReservationStatusPending {
+			return ErrReservationNotPending
+		}
+
+		released, err = releaseReservation(
+			ctx, db, reservation, database2.ReservationStatusReleased,
+		)
+		return err
+	}); err != nil {
+		s.logger(ctx).
+			WithField(reservationLogField, request.ReservationId).
+			Error(err)
+		if errors.Is(err, ErrReservationNotPending) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, databaseError(ctx, err, ErrReleaseStockFailed)
+	}
+
+	return &model.ReleaseStockResponse{
+		Reservation: toReservationModel(released),
+	}, nil
+}
+
+// CommitReservation makes the deduction of a pending Reservation's quantity
+// from its Product's stock permanent. Reservations which have expired can no
+// longer be committed.
+func (s *ProductService) CommitReservation(
+	ctx context.Context,
+	request *model.CommitReservationRequest,
+) (*model.CommitReservationResponse, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if request.ReservationId < 1 {
+		return nil, status.Error(codes.InvalidArgument, ErrReservationIdInvalid.Error())
+	}
+
+	var committed database2.StockReservation
+	if err := s.lockingWrite(ctx, func(ctx context.Context, db ProductDatabase) error {
+		reservation, err := db.GetReservationForUpdate(ctx, request.ReservationId)
+		if err != nil {
+			return err
+		}
+		if reservation.Status != database2.ReservationStatusPending {
+			return ErrReservationNotPending
+		}
+		// An expired Reservation's stock is returned by the expiry worker,
+		// which may not have reached it yet.
+		if !time.Now().Before(reservation.ExpiresAt) {
+			return ErrReservationExpired
+		}
+
+		committed, err = db.ResolveReservation(ctx, database2.ResolveReservationParams{
+			ReservationID: reservation.ReservationID,
+			Status:        database2.ReservationStatusCommitted,
+		})
+		return err
+	}); err != nil {
+		s.logger(ctx).
+			WithField(reservationLogField, request.ReservationId).
+			Error(err)
+		if errors.Is(err, ErrReservationNotPending) ||
+			errors.Is(err, ErrReservationExpired) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, databaseError(ctx, err, ErrCommitReservationFailed)
+	}
+
+	return &model.CommitReservationResponse{
+		Reservation: toReservationModel(committed),
+	}, nil
+}
+
+// ReservationExpiryWorker returns a function which, every interval until ctx
+// is cancelled, returns the stock held by expired Reservations to their
+// Products, for use as a background worker.
+func (s *ProductService) ReservationExpiryWorker(
+	interval time.Duration,
+) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if expired, err := s.expireReservations(ctx); err != nil {
+				s.log.WithError(err).Error("failed to expire reservations")
+			} else if expired > 0 {
+				s.log.WithField("expired", expired).Info("expired reservations")
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// expireReservations releases expired Reservations in batches, each within
+// its own transaction so that locks on Products are held briefly.
+func (s *ProductService) expireReservations(ctx context.Context) (int, error) {
+	params := database2.ListExpiredReservationsParams{
+		ExpiredBefore: time.Now(),
+		BatchSize:     reservationBatchSize,
+	}
+
+	var total int
+	for {
+		var expired int
+		err := s.lockingWrite(ctx, func(ctx context.Context, db ProductDatabase) error {
+			reservations, err := db.ListExpiredReservations(ctx, params)
+			if err != nil {
+				return err
+			}
+			for _, reservation := range reservations {
+				if _, err = releaseReservation(
+					ctx, db, reservation, database2.ReservationStatusExpired,
+				); err != nil {
+					return err
+				}
+			}
+			expired = len(reservations)
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+		total += expired
+		if expired < int(params.BatchSize) {
+			return total, nil
+		}
+	}
+}
+
+// releaseReservation returns the quantity held by a locked pending
+// Reservation to its Product's stock, resolving it with status.
+func releaseReservation(
+	ctx context.Context,
+	db ProductDatabase,
+	reservation database2.StockReservation,
+	resolution database2.ReservationStatus,
+) (database2.StockReservation, error) {
+	if err := db.AdjustProductStock(ctx, database2.AdjustProductStockParams{
+		ProductID: reservation.ProductID,
+		Delta:     reservation.Quantity,
+	}); err != nil {
+		return database2.StockReservation{}, err
+	}
+
+	return db.ResolveReservation(ctx, database2.ResolveReservationParams{
+		ReservationID: reservation.ReservationID,
+		Status:        resolution,
+	})
+}
+
+// toReservationModel converts a database Reservation into its API
+// representation.
+func toReservationModel(reservation database2.StockReservation) *model.Reservation {
+	return &model.Reservation{
+		Id:        reservation.ReservationID,
+		ProductId: reservation.ProductID,
+		Quantity:  reservation.Quantity,
+		Status:    reservationStatuses[reservation.Status],
+		CreatedAt: reservation.CreatedAt.UTC().Format(time.RFC3339),
+		ExpiresAt: reservation.ExpiresAt.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,172 @@
+package v1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/clintrovert/go-playground/api/model"
+	"github.com/clintrovert/go-playground/internal/test/utils"
+	"github.com/clintrovert/go-playground/pkg/postgres"
+	"github.com/clintrovert/go-playground/pkg/postgres/database"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testTransactor runs units of work without starting a transaction.
+type testTransactor struct{}
+
+func (testTransactor) RunInTx(
+	ctx context.Context,
+	_ *postgres.TxOptions,
+	fn postgres.TxFunc,
+) error {
+	return fn(ctx, nil)
+}
+
+// newTestInventoryService creates a testProductService whose transactions
+// run against its mock database.
+func newTestInventoryService(t *testing.T) *testProductService {
+	tester := newTestProductService(t)
+	tester.service.WithTransactor(testTransactor{})
+	tester.service.queries = func(*database.Queries) ProductDatabase {
+		return tester.database
+	}
+	return tester
+}
+
+func TestReserveStock_SufficientStock_ShouldDeductStock(t *testing.T) {
+	tester := newTestInventoryService(t)
+	product := utils.GenerateRandomProduct()
+	product.Stock = 5
+	request := &model.ReserveStockRequest{
+		ProductId: product.ProductID,
+		Quantity:  3,
+	}
+	reservation := database.StockReservation{
+		ReservationID: 7,
+		ProductID:     product.ProductID,
+		Quantity:      request.Quantity,
+		Status:        database.ReservationStatusPending,
+		ExpiresAt:     time.Now().Add(defaultReservationTtl),
+	}
+
+	gomock.InOrder(
+		tester.database.EXPECT().
+			GetProductForUpdate(tester.ctx, product.ProductID).
+			Return(product, nil),
+		tester.database.EXPECT().
+			AdjustProductStock(tester.ctx, database.AdjustProductStockParams{
+				ProductID: product.ProductID,
+				Delta:     -3,
+			}).
+			Return(nil),
+		tester.database.EXPECT().
+			CreateReservation(tester.ctx, gomock.Any()).
+			Return(reservation, nil),
+	)
+
+	response, err := tester.service.ReserveStock(tester.ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, reservation.ReservationID, response.Reservation.Id)
+	assert.Equal(t, request.Quantity, response.Reservation.Quantity)
+	assert.Equal(
+		t,
+		model.ReservationStatus_RESERVATION_STATUS_PENDING,
+		response.Reservation.Status,
+	)
+}
+
+func TestReserveStock_InsufficientStock_ShouldReturnFailedPrecondition(t *testing.T) {
+	tester := newTestInventoryService(t)
+	product := utils.GenerateRandomProduct()
+	product.Stock = 2
+	request := &model.ReserveStockRequest{
+		ProductId: product.ProductID,
+		Quantity:  3,
+	}
+
+	tester.database.EXPECT().
+		GetProductForUpdate(tester.ctx, product.ProductID).
+		Return(product, nil).
+		Times(1)
+
+	response, err := tester.service.ReserveStock(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestReleaseStock_PendingReservation_ShouldRestoreStock(t *testing.T) {
+	tester := newTestInventoryService(t)
+	reservation := database.StockReservation{
+		ReservationID: 7,
+		ProductID:     3,
+		Quantity:      2,
+		Status:        database.ReservationStatusPending,
+	}
+	released := reservation
+	released.Status = database.ReservationStatusReleased
+
+	gomock.InOrder(
+		tester.database.EXPECT().
+			GetReservationForUpdate(tester.ctx, reservation.ReservationID).
+			Return(reservation, nil),
+		tester.database.EXPECT().
+			AdjustProductStock(tester.ctx, database.AdjustProductStockParams{
+				ProductID: reservation.ProductID,
+				Delta:     reservation.Quantity,
+			}).
+			Return(nil),
+		tester.database.EXPECT().
+			ResolveReservation(tester.ctx, database.ResolveReservationParams{
+				ReservationID: reservation.ReservationID,
+				Status:        database.ReservationStatusReleased,
+			}).
+			Return(released, nil),
+	)
+
+	response, err := tester.service.ReleaseStock(
+		tester.ctx,
+		&model.ReleaseStockRequest{ReservationId: reservation.ReservationID},
+	)
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		model.ReservationStatus_RESERVATION_STATUS_RELEASED,
+		response.Reservation.Status,
+	)
+}
+
+func TestCommitReservation_Expired_ShouldReturnFailedPrecondition(t *testing.T) {
+	tester := newTestInventoryService(t)
+	reservation := database.StockReservation{
+		ReservationID: 7,
+		ProductID:     3,
+		Quantity:      2,
+		Status:        database.ReservationStatusPending,
+		ExpiresAt:     time.Now().Add(-time.Minute),
+	}
+
+	tester.database.EXPECT().
+		GetReservationForUpdate(tester.ctx, reservation.ReservationID).
+		Return(reservation, nil).
+		Times(1)
+
+	response, err := tester.service.CommitReservation(
+		tester.ctx,
+		&model.CommitReservationRequest{ReservationId: reservation.ReservationID},
+	)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestReserveStock_WithoutTransactor_ShouldReturnUnimplemented(t *testing.T) {
+	tester := newTestProductService(t)
+	request := &model.ReserveStockRequest{ProductId: 1, Quantity: 1}
+
+	response, err := tester.service.ReserveStock(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
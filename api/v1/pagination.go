@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/clintrovert/go-playground/pkg/identity"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultPageSize = 25
+	maxPageSize     = 100
+)
+
+var (
+	ErrPageSizeInvalid  = errors.New("page size must not be negative")
+	ErrPageTokenInvalid = errors.New("page token was invalid")
+)
+
+// pageToken identifies the last record of a page, from which the next page
+// of a keyset paginated list continues. Tokens are opaque to callers.
+type pageToken struct {
+	// Query is the digest of the filters and sort order of the list the
+	// token belongs to.
+	Query string `json:"q"`
+	// Key is the sort key of the last record, when not ordered by ID alone.
+	Key string `json:"k,omitempty"`
+	// ID breaks ties between records which share a sort key.
+	ID int32 `json:"i"`
+}
+
+// pageSize returns the number of records to return for a requested page
+// size, applying the default and maximum.
+func pageSize(requested int32) (int32, error) {
+	switch {
+	case requested < 0:
+		return 0, ErrPageSizeInvalid
+	case requested == 0:
+		return defaultPageSize, nil
+	case requested > maxPageSize:
+		return maxPageSize, nil
+	default:
+		return requested, nil
+	}
+}
+
+// queryDigest fingerprints a list request, which must have its page token
+// and page size cleared, so a token cannot be replayed against a different
+// query.
+func queryDigest(query proto.Message) (string, error) {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(query)
+	if err != nil {
+		return "", err
+	}
+	return identity.Digest(b), nil
+}
+
+func encodePageToken(token pageToken) (string, error) {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodePageToken decodes an encoded token belonging to query, returning nil
+// for the first page.
+func decodePageToken(encoded string, query string) (*pageToken, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrPageTokenInvalid
+	}
+
+	var token pageToken
+	if err = json.Unmarshal(b, &token); err != nil || token.Query != query {
+		return nil, ErrPageTokenInvalid
+	}
+	return &token, nil
+}
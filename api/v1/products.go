@@ -0,0 +1,524 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/clintrovert/go-playground/api/model"
+	"github.com/clintrovert/go-playground/pkg/money"
+	"github.com/clintrovert/go-playground/pkg/postgres"
+	database2 "github.com/clintrovert/go-playground/pkg/postgres/database"
+	"github.com/clintrovert/go-playground/pkg/requestlog"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	productLogField = "product_id"
+
+	productFieldName  = "name"
+	productFieldPrice = "price"
+	productFieldStock = "stock"
+
+	maxProductNameLength = 100
+
+	defaultReservationTtl = time.Minute * 15
+	reservationBatchSize  = 500
+)
+
+var (
+	ErrProductIdInvalid         = errors.New("product id was not specified")
+	ErrProductNameMissing       = errors.New("product name was not specified")
+	ErrProductNameTooLong       = errors.New("product name was too long")
+	ErrProductPriceMissing      = errors.New("product price was not specified")
+	ErrProductPriceInvalid      = errors.New("product price was invalid")
+	ErrProductPriceNegative     = errors.New("product price must not be negative")
+	ErrProductStockNegative     = errors.New("product stock must not be negative")
+	ErrProductRetrievalFailed   = errors.New("product retrieval failed")
+	ErrProductCreateFailed      = errors.New("product creation failed")
+	ErrProductUpdateFailed      = errors.New("product update failed")
+	ErrProductDeletionFailed    = errors.New("product deletion failed")
+	ErrProductListFailed        = errors.New("product listing failed")
+	ErrProductUpdateMaskInvalid = errors.New("product update mask contained an unknown field")
+)
+
+// ProductDatabase provides database operations for Products.
+type ProductDatabase interface {
+	// GetProduct retrieves a Product by its ID from the database.
+	GetProduct(ctx context.Context, id int32) (database2.Product, error)
+	// CreateProduct creates a new Product in the database, returning the
+	// created Product.
+	CreateProduct(
+		ctx context.Context,
+		params database2.CreateProductParams,
+	) (database2.Product, error)
+	// UpdateProduct updates the non-null fields of an existing Product in the
+	// database, returning the updated Product.
+	UpdateProduct(
+		ctx context.Context,
+		params database2.UpdateProductParams,
+	) (database2.Product, error)
+	// DeleteProduct deletes a Product from the database, returning the number
+	// of rows deleted.
+	DeleteProduct(
+		ctx context.Context,
+		params database2.DeleteProductParams,
+	) (int64, error)
+	// ListProducts lists Products in order of their ID.
+	ListProducts(
+		ctx context.Context,
+		params database2.ListProductsParams,
+	) ([]database2.Product, error)
+	// GetProductForUpdate retrieves a Product by its ID, locking its row until
+	// the end of the transaction.
+	GetProductForUpdate(ctx context.Context, id int32) (database2.Product, error)
+	// AdjustProductStock adds a delta, which may be negative, to the stock of
+	// a Product without changing its version.
+	AdjustProductStock(
+		ctx context.Context,
+		params database2.AdjustProductStockParams,
+	) error
+	// CreateReservation creates a pending Reservation, returning the created
+	// Reservation.
+	CreateReservation(
+		ctx context.Context,
+		params database2.CreateReservationParams,
+	) (database2.StockReservation, error)
+	// GetReservationForUpdate retrieves a Reservation by its ID, locking its
+	// row until the end of the transaction.
+	GetReservationForUpdate(
+		ctx context.Context,
+		id int32,
+	) (database2.StockReservation, error)
+	// ResolveReservation sets the final status of a Reservation, returning the
+	// resolved Reservation.
+	ResolveReservation(
+		ctx context.Context,
+		params database2.ResolveReservationParams,
+	) (database2.StockReservation, error)
+	// ListExpiredReservations lists and locks a batch of pending Reservations
+	// which expired, skipping those locked by other transactions.
+	ListExpiredReservations(
+		ctx context.Context,
+		params database2.ListExpiredReservationsParams,
+	) ([]database2.StockReservation, error)
+}
+
+// ProductService provides functionality to manage Products.
+type ProductService struct {
+	model.UnimplementedProductServiceServer
+	db             ProductDatabase
+	tx             Transactor
+	log            *logrus.Logger
+	reservationTtl time.Duration
+	// queries adapts the queries of a transaction to the database passed to
+	// write paths, and is replaced by tests.
+	queries func(q *database2.Queries) ProductDatabase
+}
+
+// NewProductService creates a new instance of a ProductService.
+func NewProductService(
+	db ProductDatabase,
+	log *logrus.Logger,
+) (*ProductService, error) {
+	if db == nil {
+		return nil, errors.New("db is required")
+	}
+	if log == nil {
+		return nil, errors.New("log is required")
+	}
+	return &ProductService{
+		db:             db,
+		log:            log,
+		reservationTtl: defaultReservationTtl,
+		queries: func(q *database2.Queries) ProductDatabase {
+			return q
+		},
+	}, nil
+}
+
+// WithTransactor runs the service's write paths within transactions started
+// by tx. Without a Transactor writes are issued directly against the db, and
+// stock cannot be reserved, released or committed.
+func (s *ProductService) WithTransactor(tx Transactor) *ProductService {
+	s.tx = tx
+	return s
+}
+
+// WithReservationTtl sets how long stock Reservations are held before they
+// expire unless committed, which defaults to 15 minutes.
+func (s *ProductService) WithReservationTtl(ttl time.Duration) *ProductService {
+	s.reservationTtl = ttl
+	return s
+}
+
+// GetProduct retrieves a Product by its ID from the database.
+func (s *ProductService) GetProduct(
+	ctx context.Context,
+	request *model.GetProductRequest,
+) (*model.GetProductResponse, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if request.ProductId < 1 {
+		return nil, status.Error(codes.InvalidArgument, ErrProductIdInvalid.Error())
+	}
+
+	product, err := s.db.GetProduct(ctx, request.ProductId)
+	if err != nil {
+		s.logger(ctx).
+			WithField(productLogField, request.ProductId).
+			Error(err)
+		return nil, databaseError(ctx, err, ErrProductRetrievalFailed)
+	}
+
+	return &model.GetProductResponse{
+		Product: toProductModel(product),
+	}, nil
+}
+
+// CreateProduct generates a new Product in the database.
+func (s *ProductService) CreateProduct(
+	ctx context.Context,
+	request *model.CreateProductRequest,
+) (*model.CreateProductResponse, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	price, err := validateCreateProductRequest(request)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	params := database2.CreateProductParams{
+		Name:       strings.TrimSpace(request.Name),
+		PriceMinor: price.Minor(),
+		Currency:   price.Currency(),
+		Stock:      request.Stock,
+	}
+
+	var created database2.Product
+	if err = s.write(ctx, func(ctx context.Context, db ProductDatabase) error {
+		created, err = db.CreateProduct(ctx, params)
+		return err
+	}); err != nil {
+		s.logger(ctx).Error(err)
+		return nil, databaseError(ctx, err, ErrProductCreateFailed)
+	}
+
+	return &model.CreateProductResponse{
+		Product: toProductModel(created),
+	}, nil
+}
+
+// UpdateProduct modifies the fields of an existing Product named by the
+// request's update mask, or every field when the mask is empty.
+func (s *ProductService) UpdateProduct(
+	ctx context.Context,
+	request *model.UpdateProductRequest,
+) (*model.UpdateProductResponse, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	fields, price, err := validateUpdateProductRequest(request)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	params := database2.UpdateProductParams{
+		ProductID: request.Id,
+		Version:   optionalVersion(request.Version),
+	}
+	if fields[productFieldName] {
+		params.Name = sql.NullString{
+			String: strings.TrimSpace(request.Name),
+			Valid:  true,
+		}
+	}
+	if fields[productFieldPrice] {
+		params.PriceMinor = sql.NullInt64{Int64: price.Minor(), Valid: true}
+		params.Currency = sql.NullString{String: price.Currency(), Valid: true}
+	}
+	if fields[productFieldStock] {
+		params.Stock = sql.NullInt32{Int32: request.Stock, Valid: true}
+	}
+
+	var updated database2.Product
+	if err = s.write(ctx, func(ctx context.Context, db ProductDatabase) error {
+		updated, err = db.UpdateProduct(ctx, params)
+		if errors.Is(err, sql.ErrNoRows) && params.Version.Valid {
+			return productVersionMismatch(ctx, db, params.ProductID)
+		}
+		return err
+	}); err != nil {
+		s.logger(ctx).
+			WithField(productLogField, request.Id).
+			Error(err)
+		return nil, databaseError(ctx, err, ErrProductUpdateFailed)
+	}
+
+	return &model.UpdateProductResponse{
+		Product: toProductModel(updated),
+	}, nil
+}
+
+// DeleteProduct removes an existing Product from the database.
+func (s *ProductService) DeleteProduct(
+	ctx context.Context,
+	request *model.DeleteProductRequest,
+) (*model.DeleteProductResponse, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if request.ProductId < 1 {
+		return nil, status.Error(codes.InvalidArgument, ErrProductIdInvalid.Error())
+	}
+
+	params := database2.DeleteProductParams{
+		ProductID: request.ProductId,
+		Version:   optionalVersion(request.Version),
+	}
+	if err := s.write(ctx, func(ctx context.Context, db ProductDatabase) error {
+		deleted, err := db.DeleteProduct(ctx, params)
+		if err != nil || deleted > 0 {
+			return err
+		}
+		if params.Version.Valid {
+			return productVersionMismatch(ctx, db, params.ProductID)
+		}
+		return sql.ErrNoRows
+	}); err != nil {
+		s.logger(ctx).
+			WithField(productLogField, request.ProductId).
+			Error(err)
+		return nil, databaseError(ctx, err, ErrProductDeletionFailed)
+	}
+
+	return &model.DeleteProductResponse{Deleted: true}, nil
+}
+
+// ListProducts retrieves a page of Products matching the request's filters.
+func (s *ProductService) ListProducts(
+	ctx context.Context,
+	request *model.ListProductsRequest,
+) (*model.ListProductsResponse, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	size, err := pageSize(request.PageSize)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	query := proto.Clone(request).(*model.ListProductsRequest)
+	query.PageToken, query.PageSize = "", 0
+	digest, err := queryDigest(query)
+	if err != nil {
+		return nil, status.Error(codes.Internal, ErrProductListFailed.Error())
+	}
+
+	token, err := decodePageToken(request.PageToken, digest)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Fetch one more than the page size to learn whether a next page exists.
+	params := database2.ListProductsParams{PageLimit: size + 1}
+	if prefix := strings.TrimSpace(request.NamePrefix); prefix != "" {
+		params.NamePrefix = sql.NullString{String: escapeLike(prefix), Valid: true}
+	}
+	if token != nil {
+		params.AfterID = sql.NullInt32{Int32: token.ID, Valid: true}
+	}
+
+	products, err := s.db.ListProducts(ctx, params)
+	if err != nil {
+		s.logger(ctx).Error(err)
+		return nil, databaseError(ctx, err, ErrProductListFailed)
+	}
+
+	response := &model.ListProductsResponse{}
+	if len(products) > int(size) {
+		products = products[:size]
+		last := products[len(products)-1]
+		if response.NextPageToken, err = encodePageToken(pageToken{
+			Query: digest,
+			ID:    last.ProductID,
+		}); err != nil {
+			return nil, status.Error(codes.Internal, ErrProductListFailed.Error())
+		}
+	}
+	for _, product := range products {
+		response.Products = append(response.Products, toProductModel(product))
+	}
+
+	return response, nil
+}
+
+// productVersionMismatch explains why a conditional write to a Product
+// matched no rows, returning ErrVersionMismatch if the Product exists and the
+// reason it could not be retrieved otherwise.
+func productVersionMismatch(
+	ctx context.Context,
+	db ProductDatabase,
+	id int32,
+) error {
+	if _, err := db.GetProduct(ctx, id); err != nil {
+		return err
+	}
+	return ErrVersionMismatch
+}
+
+// toProductModel converts a database Product into its API representation.
+func toProductModel(product database2.Product) *model.Product {
+	return &model.Product{
+		Id:        product.ProductID,
+		Name:      product.Name,
+		Price:     toMoneyModel(money.FromMinor(product.Currency, product.PriceMinor)),
+		CreatedAt: product.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt: product.ModifiedAt.UTC().Format(time.RFC3339),
+		Version:   product.Version,
+		Stock:     product.Stock,
+	}
+}
+
+func validateCreateProductRequest(
+	request *model.CreateProductRequest,
+) (money.Money, error) {
+	if err := validateProductName(request.Name); err != nil {
+		return money.Money{}, err
+	}
+	if request.Stock < 0 {
+		return money.Money{}, ErrProductStockNegative
+	}
+	return validatePrice(request.Price)
+}
+
+// validatePrice converts a requested price into an exact number of minor
+// units, rejecting unknown currencies, amounts more precise than the
+// currency allows and negative amounts.
+func validatePrice(price *model.Money) (money.Money, error) {
+	if price == nil {
+		return money.Money{}, ErrProductPriceMissing
+	}
+
+	m, err := money.FromUnits(price.CurrencyCode, price.Units, price.Nanos)
+	if err != nil {
+		return money.Money{}, fmt.Errorf("%w: %v", ErrProductPriceInvalid, err)
+	}
+	if m.IsNegative() {
+		return money.Money{}, ErrProductPriceNegative
+	}
+	return m, nil
+}
+
+func toMoneyModel(m money.Money) *model.Money {
+	units, nanos := m.Units()
+	return &model.Money{
+		CurrencyCode: m.Currency(),
+		Units:        units,
+		Nanos:        nanos,
+	}
+}
+
+func validateProductName(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ErrProductNameMissing
+	}
+	if len(name) > maxProductNameLength {
+		return ErrProductNameTooLong
+	}
+	return nil
+}
+
+// validateUpdateProductRequest validates the fields named by the request's
+// update mask, returning the set of fields to update and the new price.
+func validateUpdateProductRequest(
+	request *model.UpdateProductRequest,
+) (map[string]bool, money.Money, error) {
+	var price money.Money
+	if request.Id < 1 {
+		return nil, price, ErrProductIdInvalid
+	}
+
+	fields := map[string]bool{}
+	paths := request.GetUpdateMask().GetPaths()
+	// Stock is adjusted by reservations, so it is only replaced when named
+	// explicitly, never by an update which omits it from the mask.
+	if len(paths) == 0 {
+		paths = []string{productFieldName, productFieldPrice}
+	}
+	for _, path := range paths {
+		if path != productFieldName && path != productFieldPrice &&
+			path != productFieldStock {
+			return nil, price, fmt.Errorf("%w: %q", ErrProductUpdateMaskInvalid, path)
+		}
+		fields[path] = true
+	}
+
+	if fields[productFieldName] {
+		if err := validateProductName(request.Name); err != nil {
+			return nil, price, err
+		}
+	}
+	if fields[productFieldPrice] {
+		var err error
+		if price, err = validatePrice(request.Price); err != nil {
+			return nil, price, err
+		}
+	}
+	if fields[productFieldStock] && request.Stock < 0 {
+		return nil, price, ErrProductStockNegative
+	}
+	return fields, price, nil
+}
+
+// write runs fn within a read committed transaction when the service has a
+// Transactor, or directly against the service's database otherwise.
+func (s *ProductService) write(
+	ctx context.Context,
+	fn func(ctx context.Context, db ProductDatabase) error,
+) error {
+	if s.tx == nil {
+		return fn(ctx, s.db)
+	}
+
+	return s.tx.RunInTx(
+		ctx,
+		&postgres.TxOptions{Isolation: sql.LevelReadCommitted},
+		func(ctx context.Context, q *database2.Queries) error {
+			return fn(ctx, s.queries(q))
+		},
+	)
+}
+
+// lockingWrite runs fn within a read committed transaction, for write paths
+// which lock the rows they read until they are updated. It fails without a
+// Transactor, since each query would otherwise release its locks.
+func (s *ProductService) lockingWrite(
+	ctx context.Context,
+	fn func(ctx context.Context, db ProductDatabase) error,
+) error {
+	if s.tx == nil {
+		return ErrTransactorMissing
+	}
+	return s.write(ctx, fn)
+}
+
+// logger returns the request scoped logger, falling back to the service
+// logger when the request did not pass through the request log interceptor.
+func (s *ProductService) logger(ctx context.Context) *logrus.Entry {
+	return requestlog.FromContext(ctx, s.log)
+}
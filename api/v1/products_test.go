@@ -0,0 +1,230 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/clintrovert/go-playground/api/model"
+	"github.com/clintrovert/go-playground/internal/test/mocks"
+	"github.com/clintrovert/go-playground/internal/test/utils"
+	"github.com/clintrovert/go-playground/pkg/money"
+	"github.com/clintrovert/go-playground/pkg/postgres/database"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+type testProductService struct {
+	service  *ProductService
+	ctx      context.Context
+	database *mocks.MockProductDatabase
+}
+
+func newTestProductService(t *testing.T) *testProductService {
+	ctrl := gomock.NewController(t)
+	manager := mocks.NewMockProductDatabase(ctrl)
+	service, _ := NewProductService(manager, logrus.New())
+
+	return &testProductService{
+		database: manager,
+		service:  service,
+		ctx:      context.Background(),
+	}
+}
+
+func TestGetProduct_ValidRequest_ShouldSucceed(t *testing.T) {
+	tester := newTestProductService(t)
+	expected := utils.GenerateRandomProduct()
+	request := &model.GetProductRequest{
+		ProductId: expected.ProductID + 1,
+	}
+
+	tester.database.EXPECT().
+		GetProduct(tester.ctx, request.ProductId).
+		Return(expected, nil).
+		Times(1)
+
+	response, err := tester.service.GetProduct(tester.ctx, request)
+	assert.NoError(t, err)
+	assertProductEqual(t, expected, response.Product)
+}
+
+func TestGetProduct_NoRows_ShouldReturnNotFound(t *testing.T) {
+	tester := newTestProductService(t)
+	request := &model.GetProductRequest{
+		ProductId: 1,
+	}
+
+	tester.database.EXPECT().
+		GetProduct(tester.ctx, request.ProductId).
+		Return(database.Product{}, sql.ErrNoRows).
+		Times(1)
+
+	response, err := tester.service.GetProduct(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestCreateProduct_ValidRequest_ShouldSucceed(t *testing.T) {
+	tester := newTestProductService(t)
+	expected := utils.GenerateRandomProduct()
+	expected.PriceMinor = 1250
+	request := &model.CreateProductRequest{
+		Name: " " + expected.Name + " ",
+		Price: &model.Money{
+			CurrencyCode: "usd",
+			Units:        12,
+			Nanos:        500_000_000,
+		},
+	}
+
+	tester.database.EXPECT().
+		CreateProduct(tester.ctx, database.CreateProductParams{
+			Name:       expected.Name,
+			PriceMinor: 1250,
+			Currency:   "USD",
+		}).
+		Return(expected, nil).
+		Times(1)
+
+	response, err := tester.service.CreateProduct(tester.ctx, request)
+	assert.NoError(t, err)
+	assertProductEqual(t, expected, response.Product)
+}
+
+func TestCreateProduct_InvalidPrice_ShouldReturnInvalidArgument(t *testing.T) {
+	tester := newTestProductService(t)
+
+	for _, price := range []*model.Money{
+		nil,
+		{CurrencyCode: "USD", Units: -1},
+		{CurrencyCode: "ABC", Units: 1},
+		{CurrencyCode: "USD", Units: 1, Nanos: 1},
+	} {
+		request := &model.CreateProductRequest{
+			Name:  "product",
+			Price: price,
+		}
+
+		response, err := tester.service.CreateProduct(tester.ctx, request)
+		assert.Nil(t, response)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+}
+
+func TestUpdateProduct_PriceMask_ShouldOnlyUpdatePrice(t *testing.T) {
+	tester := newTestProductService(t)
+	expected := utils.GenerateRandomProduct()
+	expected.Currency, expected.PriceMinor = "JPY", 500
+	request := &model.UpdateProductRequest{
+		Id:         expected.ProductID + 1,
+		Price:      &model.Money{CurrencyCode: "JPY", Units: 500},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"price"}},
+	}
+
+	tester.database.EXPECT().
+		UpdateProduct(tester.ctx, database.UpdateProductParams{
+			ProductID:  request.Id,
+			PriceMinor: sql.NullInt64{Int64: 500, Valid: true},
+			Currency:   sql.NullString{String: "JPY", Valid: true},
+		}).
+		Return(expected, nil).
+		Times(1)
+
+	response, err := tester.service.UpdateProduct(tester.ctx, request)
+	assert.NoError(t, err)
+	assertProductEqual(t, expected, response.Product)
+}
+
+func TestUpdateProduct_EmptyMask_ShouldNotUpdateStock(t *testing.T) {
+	tester := newTestProductService(t)
+	expected := utils.GenerateRandomProduct()
+	request := &model.UpdateProductRequest{
+		Id:    expected.ProductID + 1,
+		Name:  "product",
+		Price: &model.Money{CurrencyCode: "JPY", Units: 500},
+		Stock: 10,
+	}
+
+	tester.database.EXPECT().
+		UpdateProduct(tester.ctx, database.UpdateProductParams{
+			ProductID:  request.Id,
+			Name:       sql.NullString{String: "product", Valid: true},
+			PriceMinor: sql.NullInt64{Int64: 500, Valid: true},
+			Currency:   sql.NullString{String: "JPY", Valid: true},
+		}).
+		Return(expected, nil).
+		Times(1)
+
+	response, err := tester.service.UpdateProduct(tester.ctx, request)
+	assert.NoError(t, err)
+	assertProductEqual(t, expected, response.Product)
+}
+
+func TestDeleteProduct_StaleVersion_ShouldReturnAborted(t *testing.T) {
+	tester := newTestProductService(t)
+	request := &model.DeleteProductRequest{ProductId: 1, Version: 3}
+
+	tester.database.EXPECT().
+		DeleteProduct(tester.ctx, gomock.Any()).
+		Return(int64(0), nil).
+		Times(1)
+	tester.database.EXPECT().
+		GetProduct(tester.ctx, request.ProductId).
+		Return(utils.GenerateRandomProduct(), nil).
+		Times(1)
+
+	response, err := tester.service.DeleteProduct(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.Aborted, status.Code(err))
+}
+
+func TestListProducts_MoreThanPageSize_ShouldReturnNextPageToken(t *testing.T) {
+	tester := newTestProductService(t)
+	products := []database.Product{
+		utils.GenerateRandomProduct(),
+		utils.GenerateRandomProduct(),
+	}
+	request := &model.ListProductsRequest{PageSize: 1}
+
+	tester.database.EXPECT().
+		ListProducts(tester.ctx, database.ListProductsParams{PageLimit: 2}).
+		Return(products, nil).
+		Times(1)
+
+	response, err := tester.service.ListProducts(tester.ctx, request)
+	assert.NoError(t, err)
+	assert.Len(t, response.Products, 1)
+
+	request.PageToken = response.NextPageToken
+	tester.database.EXPECT().
+		ListProducts(tester.ctx, database.ListProductsParams{
+			AfterID:   sql.NullInt32{Int32: products[0].ProductID, Valid: true},
+			PageLimit: 2,
+		}).
+		Return(products[1:], nil).
+		Times(1)
+
+	response, err = tester.service.ListProducts(tester.ctx, request)
+	assert.NoError(t, err)
+	assert.Len(t, response.Products, 1)
+	assert.Empty(t, response.NextPageToken)
+}
+
+func assertProductEqual(
+	t *testing.T,
+	expected database.Product,
+	actual *model.Product,
+) {
+	assert.Equal(t, expected.ProductID, actual.Id)
+	assert.Equal(t, expected.Name, actual.Name)
+	assert.Equal(t, expected.Currency, actual.Price.CurrencyCode)
+	units, nanos := money.FromMinor(expected.Currency, expected.PriceMinor).Units()
+	assert.Equal(t, units, actual.Price.Units)
+	assert.Equal(t, nanos, actual.Price.Nanos)
+	assert.Equal(t, expected.Stock, actual.Stock)
+}
@@ -0,0 +1,262 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/clintrovert/go-playground/api/model"
+	"github.com/clintrovert/go-playground/pkg/identity"
+	database2 "github.com/clintrovert/go-playground/pkg/postgres/database"
+	"github.com/clintrovert/go-playground/pkg/requestlog"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	maxSearchQueryLength = 256
+
+	highlightFieldName  = "name"
+	highlightFieldEmail = "email"
+)
+
+var (
+	ErrSearchQueryMissing  = errors.New("search query was not specified")
+	ErrSearchQueryTooLong  = errors.New("search query was too long")
+	ErrSearchTargetInvalid = errors.New("search target was invalid")
+	ErrSearchUsersDenied   = errors.New("searching users requires an administrator")
+	ErrSearchFailed        = errors.New("search failed")
+)
+
+// SearchDatabase provides full-text search over Products and Users.
+type SearchDatabase interface {
+	// SearchProducts lists the Products whose names match a query, most
+	// relevant first.
+	SearchProducts(
+		ctx context.Context,
+		params database2.SearchProductsParams,
+	) ([]database2.SearchProductsRow, error)
+	// SearchUsers lists the Users, excluding deleted Users, whose names or
+	// emails match a query, most relevant first.
+	SearchUsers(
+		ctx context.Context,
+		params database2.SearchUsersParams,
+	) ([]database2.SearchUsersRow, error)
+}
+
+// SearchService provides full-text search over Products and Users.
+type SearchService struct {
+	model.UnimplementedSearchServiceServer
+	db  SearchDatabase
+	log *logrus.Logger
+}
+
+// NewSearchService creates a new instance of a SearchService.
+func NewSearchService(
+	db SearchDatabase,
+	log *logrus.Logger,
+) (*SearchService, error) {
+	if db == nil {
+		return nil, errors.New("db is required")
+	}
+	if log == nil {
+		return nil, errors.New("log is required")
+	}
+	return &SearchService{
+		db:  db,
+		log: log,
+	}, nil
+}
+
+// searchCursor is the position after which a page of search results
+// continues, ordered by rank and then ID, both descending.
+type searchCursor struct {
+	afterRank sql.NullFloat64
+	afterID   sql.NullInt32
+}
+
+// Search retrieves a page of the Products, or for admins the Users, matching
+// the request's query, most relevant first.
+func (s *SearchService) Search(
+	ctx context.Context,
+	request *model.SearchRequest,
+) (*model.SearchResponse, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if err := validateSearchRequest(request); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if request.Target == model.SearchTarget_SEARCH_TARGET_USERS &&
+		!identity.IsAdmin(ctx) {
+		return nil, status.Error(codes.PermissionDenied, ErrSearchUsersDenied.Error())
+	}
+
+	size, err := pageSize(request.PageSize)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	query := proto.Clone(request).(*model.SearchRequest)
+	query.PageToken, query.PageSize = "", 0
+	digest, err := queryDigest(query)
+	if err != nil {
+		return nil, status.Error(codes.Internal, ErrSearchFailed.Error())
+	}
+
+	token, err := decodePageToken(request.PageToken, digest)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	cursor, err := newSearchCursor(token)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Fetch one more than the page size to learn whether a next page exists.
+	var results []*model.SearchResult
+	terms := strings.TrimSpace(request.Query)
+	if request.Target == model.SearchTarget_SEARCH_TARGET_USERS {
+		results, err = s.searchUsers(ctx, terms, cursor, size+1)
+	} else {
+		results, err = s.searchProducts(ctx, terms, cursor, size+1)
+	}
+	if err != nil {
+		s.logger(ctx).Error(err)
+		return nil, databaseError(ctx, err, ErrSearchFailed)
+	}
+
+	response := &model.SearchResponse{}
+	if len(results) > int(size) {
+		results = results[:size]
+		if response.NextPageToken, err = encodePageToken(
+			searchPageToken(digest, results[len(results)-1]),
+		); err != nil {
+			return nil, status.Error(codes.Internal, ErrSearchFailed.Error())
+		}
+	}
+	response.Results = results
+
+	return response, nil
+}
+
+func (s *SearchService) searchProducts(
+	ctx context.Context,
+	terms string,
+	cursor searchCursor,
+	limit int32,
+) ([]*model.SearchResult, error) {
+	rows, err := s.db.SearchProducts(ctx, database2.SearchProductsParams{
+		Query:     terms,
+		AfterRank: cursor.afterRank,
+		AfterID:   cursor.afterID,
+		PageLimit: limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*model.SearchResult, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, &model.SearchResult{
+			Item: &model.SearchResult_Product{
+				Product: toProductModel(row.Product),
+			},
+			Rank: row.Rank,
+			Highlights: map[string]string{
+				highlightFieldName: row.NameHighlight,
+			},
+		})
+	}
+	return results, nil
+}
+
+func (s *SearchService) searchUsers(
+	ctx context.Context,
+	terms string,
+	cursor searchCursor,
+	limit int32,
+) ([]*model.SearchResult, error) {
+	rows, err := s.db.SearchUsers(ctx, database2.SearchUsersParams{
+		Query:     terms,
+		AfterRank: cursor.afterRank,
+		AfterID:   cursor.afterID,
+		PageLimit: limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*model.SearchResult, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, &model.SearchResult{
+			Item: &model.SearchResult_User{
+				User: toUserModel(row.User),
+			},
+			Rank: row.Rank,
+			Highlights: map[string]string{
+				highlightFieldName:  row.NameHighlight,
+				highlightFieldEmail: row.EmailHighlight,
+			},
+		})
+	}
+	return results, nil
+}
+
+// newSearchCursor parses the position encoded in a page token, which is nil
+// for the first page.
+func newSearchCursor(token *pageToken) (searchCursor, error) {
+	if token == nil {
+		return searchCursor{}, nil
+	}
+
+	rank, err := strconv.ParseFloat(token.Key, 32)
+	if err != nil {
+		return searchCursor{}, ErrPageTokenInvalid
+	}
+	return searchCursor{
+		afterRank: sql.NullFloat64{Float64: rank, Valid: true},
+		afterID:   sql.NullInt32{Int32: token.ID, Valid: true},
+	}, nil
+}
+
+// searchPageToken creates the token of the page following result. Ranks are
+// encoded exactly so that the next page starts strictly after result.
+func searchPageToken(digest string, result *model.SearchResult) pageToken {
+	token := pageToken{
+		Query: digest,
+		Key:   strconv.FormatFloat(float64(result.Rank), 'g', -1, 32),
+	}
+	switch item := result.Item.(type) {
+	case *model.SearchResult_Product:
+		token.ID = item.Product.Id
+	case *model.SearchResult_User:
+		token.ID = item.User.Id
+	}
+	return token
+}
+
+func validateSearchRequest(request *model.SearchRequest) error {
+	query := strings.TrimSpace(request.Query)
+	if query == "" {
+		return ErrSearchQueryMissing
+	}
+	if len(query) > maxSearchQueryLength {
+		return ErrSearchQueryTooLong
+	}
+	if _, ok := model.SearchTarget_name[int32(request.Target)]; !ok {
+		return ErrSearchTargetInvalid
+	}
+	return nil
+}
+
+// logger returns the request scoped logger, falling back to the service
+// logger when the request did not pass through the request log interceptor.
+func (s *SearchService) logger(ctx context.Context) *logrus.Entry {
+	return requestlog.FromContext(ctx, s.log)
+}
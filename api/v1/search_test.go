@@ -0,0 +1,128 @@
+package v1
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clintrovert/go-playground/api/model"
+	"github.com/clintrovert/go-playground/internal/test/mocks"
+	"github.com/clintrovert/go-playground/internal/test/utils"
+	"github.com/clintrovert/go-playground/pkg/identity"
+	"github.com/clintrovert/go-playground/pkg/postgres/database"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type testSearchService struct {
+	service  *SearchService
+	ctx      context.Context
+	database *mocks.MockSearchDatabase
+}
+
+func newTestSearchService(t *testing.T) *testSearchService {
+	ctrl := gomock.NewController(t)
+	manager := mocks.NewMockSearchDatabase(ctrl)
+	service, _ := NewSearchService(manager, logrus.New())
+
+	return &testSearchService{
+		database: manager,
+		service:  service,
+		ctx:      context.Background(),
+	}
+}
+
+func TestSearch_MoreThanPageSize_ShouldResumeAfterLastResult(t *testing.T) {
+	tester := newTestSearchService(t)
+	first, second := utils.GenerateRandomProduct(), utils.GenerateRandomProduct()
+	request := &model.SearchRequest{Query: " widget ", PageSize: 1}
+
+	tester.database.EXPECT().
+		SearchProducts(tester.ctx, database.SearchProductsParams{
+			Query:     "widget",
+			PageLimit: 2,
+		}).
+		Return([]database.SearchProductsRow{
+			{Product: first, Rank: 0.0607927, NameHighlight: "<b>widget</b>"},
+			{Product: second, Rank: 0.0303964, NameHighlight: "<b>widget</b>"},
+		}, nil).
+		Times(1)
+
+	response, err := tester.service.Search(tester.ctx, request)
+	assert.NoError(t, err)
+	assert.Len(t, response.Results, 1)
+	assert.Equal(t, "<b>widget</b>", response.Results[0].Highlights["name"])
+	assertProductEqual(t, first, response.Results[0].GetProduct())
+
+	tester.database.EXPECT().
+		SearchProducts(tester.ctx, gomock.Any()).
+		DoAndReturn(func(
+			_ context.Context,
+			params database.SearchProductsParams,
+		) ([]database.SearchProductsRow, error) {
+			assert.Equal(t, float32(0.0607927), float32(params.AfterRank.Float64))
+			assert.Equal(t, first.ProductID, params.AfterID.Int32)
+			return nil, nil
+		}).
+		Times(1)
+
+	request.PageToken = response.NextPageToken
+	response, err = tester.service.Search(tester.ctx, request)
+	assert.NoError(t, err)
+	assert.Empty(t, response.NextPageToken)
+}
+
+func TestSearch_UsersAsNonAdmin_ShouldReturnPermissionDenied(t *testing.T) {
+	tester := newTestSearchService(t)
+	request := &model.SearchRequest{
+		Query:  "jane",
+		Target: model.SearchTarget_SEARCH_TARGET_USERS,
+	}
+
+	response, err := tester.service.Search(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestSearch_UsersAsAdmin_ShouldHighlightNameAndEmail(t *testing.T) {
+	tester := newTestSearchService(t)
+	ctx := identity.WithAdmin(tester.ctx)
+	user := utils.GenerateRandomUser()
+	request := &model.SearchRequest{
+		Query:  "jane",
+		Target: model.SearchTarget_SEARCH_TARGET_USERS,
+	}
+
+	tester.database.EXPECT().
+		SearchUsers(ctx, database.SearchUsersParams{
+			Query:     "jane",
+			PageLimit: defaultPageSize + 1,
+		}).
+		Return([]database.SearchUsersRow{{
+			User:           user,
+			Rank:           0.6,
+			NameHighlight:  "<b>Jane</b> Doe",
+			EmailHighlight: "jane@example.com",
+		}}, nil).
+		Times(1)
+
+	response, err := tester.service.Search(ctx, request)
+	assert.NoError(t, err)
+	assert.Len(t, response.Results, 1)
+	assert.Equal(t, user.UserID, response.Results[0].GetUser().Id)
+	assert.Equal(t, "<b>Jane</b> Doe", response.Results[0].Highlights["name"])
+	assert.Equal(t, "jane@example.com", response.Results[0].Highlights["email"])
+}
+
+func TestSearch_EmptyQuery_ShouldReturnInvalidArgument(t *testing.T) {
+	tester := newTestSearchService(t)
+
+	response, err := tester.service.Search(
+		tester.ctx,
+		&model.SearchRequest{Query: "  "},
+	)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
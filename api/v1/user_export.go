@@ -0,0 +1,213 @@
+package v1
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/clintrovert/go-playground/api/model"
+	"github.com/clintrovert/go-playground/pkg/identity"
+	"github.com/clintrovert/go-playground/pkg/postgres"
+	database2 "github.com/clintrovert/go-playground/pkg/postgres/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	exportPageSize  = 1000
+	exportChunkSize = 64 * 1024
+)
+
+var (
+	ErrExportFailed      = errors.New("user export failed")
+	ErrExportUsersDenied = errors.New("exporting users requires an administrator")
+)
+
+// exportHeader names the columns of a CSV export.
+var exportHeader = []string{
+	"id", "name", "email", "is_admin", "created_at", "updated_at", "version",
+}
+
+// exportRecord is a row of an NDJSON export.
+type exportRecord struct {
+	ID        int32  `json:"id"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	IsAdmin   bool   `json:"is_admin"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+	Version   int32  `json:"version"`
+}
+
+// exportWriter encodes Users in the format of an export.
+type exportWriter interface {
+	Write(user *model.User) error
+	Flush() error
+}
+
+// ExportUsers streams every User, excluding deleted Users, as a CSV or
+// NDJSON file. The Users are read from a single snapshot of the database so
+// that the export is consistent however long it takes. Exporting requires an
+// administrator, and password hashes are never exported. CSV names and emails
+// which a spreadsheet would evaluate as formulas are prefixed with a single
+// quote.
+func (s *UserService) ExportUsers(
+	request *model.ExportUsersRequest,
+	stream model.UserService_ExportUsersServer,
+) error {
+	ctx := stream.Context()
+	if err := validateContext(ctx); err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	if !identity.IsAdmin(ctx) {
+		return status.Error(codes.PermissionDenied, ErrExportUsersDenied.Error())
+	}
+
+	chunks := &exportChunkWriter{stream: stream}
+	buffered := bufio.NewWriterSize(chunks, exportChunkSize)
+
+	var writer exportWriter
+	switch request.Format {
+	case model.UserDataFormat_USER_DATA_FORMAT_CSV:
+		csvWriter := csv.NewWriter(buffered)
+		if err := csvWriter.Write(exportHeader); err != nil {
+			return status.Error(codes.Internal, ErrExportFailed.Error())
+		}
+		writer = &csvExportWriter{writer: csvWriter}
+	case model.UserDataFormat_USER_DATA_FORMAT_NDJSON:
+		writer = &ndjsonExportWriter{encoder: json.NewEncoder(buffered)}
+	default:
+		return status.Error(codes.InvalidArgument, ErrUserDataFormatInvalid.Error())
+	}
+
+	err := s.snapshot(ctx, func(ctx context.Context, db UserDatabase) error {
+		params := database2.ExportUsersParams{PageLimit: exportPageSize}
+		for {
+			users, err := db.ExportUsers(ctx, params)
+			if err != nil {
+				return err
+			}
+			for _, user := range users {
+				if err = writer.Write(toUserModel(user)); err != nil {
+					return err
+				}
+			}
+			if len(users) < exportPageSize {
+				return nil
+			}
+			params.AfterID = sql.NullInt32{Int32: users[len(users)-1].UserID, Valid: true}
+		}
+	})
+	if err == nil {
+		if err = writer.Flush(); err == nil {
+			err = buffered.Flush()
+		}
+	}
+	if err != nil {
+		if chunks.err != nil {
+			return chunks.err
+		}
+		s.logger(ctx).Error(err)
+		return databaseError(ctx, err, ErrExportFailed)
+	}
+
+	return nil
+}
+
+// snapshot runs fn within a read only repeatable read transaction, so that
+// every read sees the same snapshot, when the service has a Transactor, or
+// directly against the service's database otherwise. The transaction is not
+// retried, since fn streams its results as it reads them.
+func (s *UserService) snapshot(
+	ctx context.Context,
+	fn func(ctx context.Context, db UserDatabase) error,
+) error {
+	if s.tx == nil {
+		return fn(ctx, s.db)
+	}
+
+	return s.tx.RunInTx(
+		ctx,
+		&postgres.TxOptions{
+			Isolation:  sql.LevelRepeatableRead,
+			ReadOnly:   true,
+			MaxRetries: -1,
+		},
+		func(ctx context.Context, q *database2.Queries) error {
+			return fn(ctx, q)
+		},
+	)
+}
+
+// exportChunkWriter sends each write as a message on stream, remembering the
+// error which ended the stream.
+type exportChunkWriter struct {
+	stream model.UserService_ExportUsersServer
+	err    error
+}
+
+func (w *exportChunkWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	// Send marshals the message before returning, so p may be reused.
+	if w.err = w.stream.Send(&model.ExportUsersResponse{Data: p}); w.err != nil {
+		return 0, w.err
+	}
+	return len(p), nil
+}
+
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvExportWriter) Write(user *model.User) error {
+	return w.writer.Write([]string{
+		strconv.FormatInt(int64(user.Id), 10),
+		csvText(user.Name),
+		csvText(user.Email),
+		strconv.FormatBool(user.IsAdmin),
+		user.CreatedAt,
+		user.UpdatedAt,
+		strconv.FormatInt(int64(user.Version), 10),
+	})
+}
+
+func (w *csvExportWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// csvText prefixes text which a spreadsheet would evaluate as a formula with
+// a single quote, so that it is displayed as written.
+func csvText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+type ndjsonExportWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonExportWriter) Write(user *model.User) error {
+	return w.encoder.Encode(exportRecord{
+		ID:        user.Id,
+		Name:      user.Name,
+		Email:     user.Email,
+		IsAdmin:   user.IsAdmin,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		Version:   user.Version,
+	})
+}
+
+func (w *ndjsonExportWriter) Flush() error {
+	return nil
+}
@@ -0,0 +1,94 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/clintrovert/go-playground/api/model"
+	"github.com/clintrovert/go-playground/internal/test/utils"
+	"github.com/clintrovert/go-playground/pkg/identity"
+	"github.com/clintrovert/go-playground/pkg/postgres/database"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testExportStream collects the data streamed by an export.
+type testExportStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	data bytes.Buffer
+}
+
+func (s *testExportStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testExportStream) Send(response *model.ExportUsersResponse) error {
+	s.data.Write(response.Data)
+	return nil
+}
+
+func TestExportUsers_FullPage_ShouldExportEveryPage(t *testing.T) {
+	tester := newTestUserService(t)
+	ctx := identity.WithAdmin(tester.ctx)
+	stream := &testExportStream{ctx: ctx}
+	page := make([]database.User, exportPageSize)
+	for i := range page {
+		page[i] = utils.GenerateRandomUser()
+		page[i].UserID = int32(i + 1)
+	}
+	last := utils.GenerateRandomUser()
+	last.Name = `last, "quoted"`
+
+	gomock.InOrder(
+		tester.database.EXPECT().
+			ExportUsers(ctx, database.ExportUsersParams{
+				PageLimit: exportPageSize,
+			}).
+			Return(page, nil),
+		tester.database.EXPECT().
+			ExportUsers(ctx, database.ExportUsersParams{
+				AfterID:   sql.NullInt32{Int32: exportPageSize, Valid: true},
+				PageLimit: exportPageSize,
+			}).
+			Return([]database.User{last}, nil),
+	)
+
+	err := tester.service.ExportUsers(
+		&model.ExportUsersRequest{Format: model.UserDataFormat_USER_DATA_FORMAT_CSV},
+		stream,
+	)
+	assert.NoError(t, err)
+
+	lines := bytes.Split(bytes.TrimSpace(stream.data.Bytes()), []byte("\n"))
+	assert.Len(t, lines, exportPageSize+2)
+	assert.Equal(t, "id,name,email,is_admin,created_at,updated_at,version", string(lines[0]))
+	assert.Contains(t, string(lines[len(lines)-1]), `"last, ""quoted"""`)
+	assert.NotContains(t, stream.data.String(), last.Password)
+}
+
+func TestExportUsers_NonAdmin_ShouldReturnPermissionDenied(t *testing.T) {
+	tester := newTestUserService(t)
+	stream := &testExportStream{ctx: tester.ctx}
+
+	err := tester.service.ExportUsers(
+		&model.ExportUsersRequest{Format: model.UserDataFormat_USER_DATA_FORMAT_CSV},
+		stream,
+	)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Zero(t, stream.data.Len())
+}
+
+func TestCsvText_FormulaPrefix_ShouldBeQuoted(t *testing.T) {
+	for _, text := range []string{"=1+1", "+1", "-1", "@SUM(A1)", "\tx", "\rx"} {
+		assert.Equal(t, "'"+text, csvText(text))
+	}
+	for _, text := range []string{"", "jane", "jane@example.com", "1=1"} {
+		assert.Equal(t, text, csvText(text))
+	}
+}
@@ -0,0 +1,414 @@
+package v1
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/clintrovert/go-playground/api/model"
+	database2 "github.com/clintrovert/go-playground/pkg/postgres/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	importBatchSize     = 500
+	maxImportErrors     = 1000
+	maxImportLineLength = 1 << 20
+
+	importColumnName     = "name"
+	importColumnEmail    = "email"
+	importColumnPassword = "password"
+	importColumnIsAdmin  = "is_admin"
+)
+
+var (
+	ErrUserDataFormatInvalid = errors.New("user data format was invalid")
+	ErrImportEmpty           = errors.New("import contained no messages")
+	ErrImportHeaderInvalid   = errors.New("import header must name the columns name, email and password")
+	ErrImportRowInvalid      = errors.New("import row was malformed")
+	ErrImportRowTooLong      = errors.New("import row was longer than 1MiB")
+	ErrImportFailed          = errors.New("user import failed")
+)
+
+// importRecord is a row of an NDJSON import.
+type importRecord struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	IsAdmin  bool   `json:"is_admin"`
+}
+
+// importReader reads the rows of an import. Malformed rows are reported as
+// errors wrapping ErrImportRowInvalid, after which reading may continue.
+type importReader interface {
+	Read() (*model.CreateUserRequest, error)
+}
+
+// importedRow is a validated row waiting to be imported.
+type importedRow struct {
+	row  int64
+	user *model.CreateUserRequest
+}
+
+// ImportUsers creates Users from a streamed CSV or NDJSON file. Rows are
+// validated as CreateUser requests and failures are reported per row. Valid
+// rows are imported in batches as they arrive, so batches imported before
+// the import fails are kept.
+func (s *UserService) ImportUsers(stream model.UserService_ImportUsersServer) error {
+	ctx := stream.Context()
+	if err := validateContext(ctx); err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	first, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return status.Error(codes.InvalidArgument, ErrImportEmpty.Error())
+	}
+	if err != nil {
+		return err
+	}
+
+	data, writer := io.Pipe()
+	defer data.Close()
+	go receiveImport(stream, first.Data, writer)
+
+	var reader importReader
+	switch first.Format {
+	case model.UserDataFormat_USER_DATA_FORMAT_CSV:
+		if reader, err = newCSVImportReader(data); err != nil {
+			return importError(ctx, err)
+		}
+	case model.UserDataFormat_USER_DATA_FORMAT_NDJSON:
+		reader = newNDJSONImportReader(data)
+	default:
+		return status.Error(codes.InvalidArgument, ErrUserDataFormatInvalid.Error())
+	}
+
+	response := &model.ImportUsersResponse{}
+	fail := func(row int64, err error) {
+		response.Failed++
+		if len(response.Errors) < maxImportErrors {
+			st := status.Convert(err)
+			response.Errors = append(response.Errors, &model.ImportRowError{
+				Row:     row,
+				Code:    int32(st.Code()),
+				Message: st.Message(),
+			})
+		}
+	}
+
+	emails := map[string]bool{}
+	var batch []importedRow
+	for {
+		user, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		response.Rows++
+		if errors.Is(err, ErrImportRowInvalid) {
+			fail(response.Rows, status.Error(codes.InvalidArgument, err.Error()))
+			continue
+		}
+		if err != nil {
+			return importError(ctx, err)
+		}
+
+		if err = validateCreateUserRequest(user); err != nil {
+			fail(response.Rows, status.Error(codes.InvalidArgument, err.Error()))
+			continue
+		}
+		email := strings.ToLower(strings.TrimSpace(user.Email))
+		if emails[email] {
+			fail(response.Rows, status.Error(codes.AlreadyExists, ErrUserEmailDuplicated.Error()))
+			continue
+		}
+		emails[email] = true
+
+		batch = append(batch, importedRow{row: response.Rows, user: user})
+		if len(batch) == importBatchSize {
+			if err = s.flushImport(ctx, first.DryRun, batch, response, fail); err != nil {
+				return importError(ctx, err)
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		if err = s.flushImport(ctx, first.DryRun, batch, response, fail); err != nil {
+			return importError(ctx, err)
+		}
+	}
+
+	return stream.SendAndClose(response)
+}
+
+// flushImport imports a batch of validated rows or, for a dry run, only
+// checks that their emails are not taken.
+func (s *UserService) flushImport(
+	ctx context.Context,
+	dryRun bool,
+	batch []importedRow,
+	response *model.ImportUsersResponse,
+	fail func(row int64, err error),
+) error {
+	if dryRun {
+		return s.checkImportBatch(ctx, batch, fail)
+	}
+	return s.importBatch(ctx, batch, response, fail)
+}
+
+// checkImportBatch reports the rows of a batch whose email is already taken,
+// which importing would fail.
+func (s *UserService) checkImportBatch(
+	ctx context.Context,
+	batch []importedRow,
+	fail func(row int64, err error),
+) error {
+	emails := make([]string, len(batch))
+	for i, row := range batch {
+		emails[i] = strings.ToLower(strings.TrimSpace(row.user.Email))
+	}
+
+	taken, err := s.db.ListTakenEmails(ctx, emails)
+	if err != nil {
+		return err
+	}
+	isTaken := make(map[string]bool, len(taken))
+	for _, email := range taken {
+		isTaken[email] = true
+	}
+	for i, row := range batch {
+		if isTaken[emails[i]] {
+			fail(row.row, status.Error(codes.AlreadyExists, ErrUserEmailTaken.Error()))
+		}
+	}
+	return nil
+}
+
+// importBatch creates a batch of validated rows in a single statement,
+// reporting rows whose email is already taken.
+func (s *UserService) importBatch(
+	ctx context.Context,
+	batch []importedRow,
+	response *model.ImportUsersResponse,
+	fail func(row int64, err error),
+) error {
+	passwords := make([]string, len(batch))
+	for i, row := range batch {
+		passwords[i] = row.user.Password
+	}
+	hashes, errs := s.hashPasswords(ctx, passwords)
+
+	var params database2.CreateUsersParams
+	pending := map[string]int64{}
+	for i, row := range batch {
+		if errs[i] != nil {
+			if ctxErr := contextError(ctx); ctxErr != nil {
+				return ctxErr
+			}
+			s.logger(ctx).Error(errs[i])
+			fail(row.row, status.Error(codes.Internal, ErrUserCreateFailed.Error()))
+			continue
+		}
+		email := strings.TrimSpace(row.user.Email)
+		pending[strings.ToLower(email)] = row.row
+		params.Names = append(params.Names, strings.TrimSpace(row.user.Name))
+		params.Emails = append(params.Emails, email)
+		params.Passwords = append(params.Passwords, hashes[i])
+		params.IsAdmins = append(params.IsAdmins, row.user.IsAdmin)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	var created []database2.User
+	if err := s.write(ctx, func(ctx context.Context, db UserDatabase) error {
+		var err error
+		created, err = db.CreateUsers(ctx, params)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	for _, user := range created {
+		delete(pending, strings.ToLower(user.Email))
+	}
+	for _, row := range batch {
+		email := strings.ToLower(strings.TrimSpace(row.user.Email))
+		if pending[email] == row.row {
+			fail(row.row, status.Error(codes.AlreadyExists, ErrUserEmailTaken.Error()))
+		}
+	}
+	response.Imported += int64(len(created))
+	return nil
+}
+
+// receiveImport writes the data of each message received on stream to w,
+// closing w at the end of the stream or with the error ending it.
+func receiveImport(
+	stream model.UserService_ImportUsersServer,
+	first []byte,
+	w *io.PipeWriter,
+) {
+	if _, err := w.Write(first); err != nil {
+		return
+	}
+	for {
+		request, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			w.Close()
+			return
+		}
+		if err != nil {
+			w.CloseWithError(err)
+			return
+		}
+		if _, err = w.Write(request.Data); err != nil {
+			return
+		}
+	}
+}
+
+// importError translates an error which ended an import into the gRPC
+// status returned to the caller.
+func importError(ctx context.Context, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, ErrImportHeaderInvalid) || errors.Is(err, ErrImportRowTooLong) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return databaseError(ctx, err, ErrImportFailed)
+}
+
+type csvImportReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	fields  int
+}
+
+// newCSVImportReader reads the header of a CSV import, which must name the
+// name, email and password columns and may name is_admin.
+func newCSVImportReader(r io.Reader) (*csvImportReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, ErrImportHeaderInvalid
+	}
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, fmt.Errorf("%w: %v", ErrImportHeaderInvalid, err)
+		}
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if column == "isadmin" {
+			column = importColumnIsAdmin
+		}
+		columns[column] = i
+	}
+	for _, required := range []string{
+		importColumnName, importColumnEmail, importColumnPassword,
+	} {
+		if _, ok := columns[required]; !ok {
+			return nil, ErrImportHeaderInvalid
+		}
+	}
+
+	return &csvImportReader{
+		reader:  reader,
+		columns: columns,
+		fields:  len(header),
+	}, nil
+}
+
+func (r *csvImportReader) Read() (*model.CreateUserRequest, error) {
+	record, err := r.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, fmt.Errorf("%w: %v", ErrImportRowInvalid, parseErr.Err)
+		}
+		return nil, err
+	}
+
+	if len(record) != r.fields {
+		return nil, fmt.Errorf(
+			"%w: expected %d fields, found %d",
+			ErrImportRowInvalid, r.fields, len(record),
+		)
+	}
+	field := func(column string) string {
+		if i, ok := r.columns[column]; ok {
+			return record[i]
+		}
+		return ""
+	}
+
+	user := &model.CreateUserRequest{
+		Name:     field(importColumnName),
+		Email:    field(importColumnEmail),
+		Password: field(importColumnPassword),
+	}
+	if isAdmin := strings.TrimSpace(field(importColumnIsAdmin)); isAdmin != "" {
+		if user.IsAdmin, err = strconv.ParseBool(isAdmin); err != nil {
+			return nil, fmt.Errorf("%w: is_admin was not a boolean", ErrImportRowInvalid)
+		}
+	}
+	return user, nil
+}
+
+type ndjsonImportReader struct {
+	scanner *bufio.Scanner
+}
+
+func newNDJSONImportReader(r io.Reader) *ndjsonImportReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineLength)
+	return &ndjsonImportReader{scanner: scanner}
+}
+
+func (r *ndjsonImportReader) Read() (*model.CreateUserRequest, error) {
+	for r.scanner.Scan() {
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var record importRecord
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&record); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrImportRowInvalid, err)
+		}
+		return &model.CreateUserRequest{
+			Name:     record.Name,
+			Email:    record.Email,
+			Password: record.Password,
+			IsAdmin:  record.IsAdmin,
+		}, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, ErrImportRowTooLong
+		}
+		return nil, err
+	}
+	return nil, io.EOF
+}
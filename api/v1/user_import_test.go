@@ -0,0 +1,120 @@
+package v1
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/clintrovert/go-playground/api/model"
+	"github.com/clintrovert/go-playground/pkg/postgres/database"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// testImportStream replays requests as a client streaming an import.
+type testImportStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	requests []*model.ImportUsersRequest
+	response *model.ImportUsersResponse
+}
+
+func (s *testImportStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testImportStream) Recv() (*model.ImportUsersRequest, error) {
+	if len(s.requests) == 0 {
+		return nil, io.EOF
+	}
+	request := s.requests[0]
+	s.requests = s.requests[1:]
+	return request, nil
+}
+
+func (s *testImportStream) SendAndClose(response *model.ImportUsersResponse) error {
+	s.response = response
+	return nil
+}
+
+func TestImportUsers_CSVSplitAcrossChunks_ShouldReportRowErrors(t *testing.T) {
+	tester := newTestUserService(t)
+	stream := &testImportStream{
+		ctx: tester.ctx,
+		requests: []*model.ImportUsersRequest{
+			{
+				Format: model.UserDataFormat_USER_DATA_FORMAT_CSV,
+				Data:   []byte("name,email,password,is_admin\nann,ann@exa"),
+			},
+			{Data: []byte("mple.com,secret,true\nbob,not an email,secret,\n")},
+			{Data: []byte("cat,cat@example.com,secret,maybe\ndan,ann@example.com,secret,\n")},
+		},
+	}
+
+	tester.database.EXPECT().
+		CreateUsers(tester.ctx, gomock.Any()).
+		DoAndReturn(func(
+			_ context.Context,
+			params database.CreateUsersParams,
+		) ([]database.User, error) {
+			assert.Equal(t, []string{"ann@example.com"}, params.Emails)
+			assert.Equal(t, []bool{true}, params.IsAdmins)
+			return []database.User{{UserID: 1, Email: "ann@example.com"}}, nil
+		}).
+		Times(1)
+
+	assert.NoError(t, tester.service.ImportUsers(stream))
+	assert.Equal(t, int64(4), stream.response.Rows)
+	assert.Equal(t, int64(1), stream.response.Imported)
+	assert.Equal(t, int64(3), stream.response.Failed)
+	assert.Equal(t, int64(2), stream.response.Errors[0].Row)
+	assert.Equal(t, int64(3), stream.response.Errors[1].Row)
+	assert.Equal(t, int32(codes.AlreadyExists), stream.response.Errors[2].Code)
+}
+
+func TestImportUsers_NDJSONDryRun_ShouldNotCreateUsers(t *testing.T) {
+	tester := newTestUserService(t)
+	stream := &testImportStream{
+		ctx: tester.ctx,
+		requests: []*model.ImportUsersRequest{{
+			Format: model.UserDataFormat_USER_DATA_FORMAT_NDJSON,
+			DryRun: true,
+			Data: []byte(`{"name":"ann","email":"ann@example.com","password":"secret"}` +
+				"\n\n" + `{"name":"bob","email":"bob@example.com","unknown":1}` + "\n"),
+		}},
+	}
+	tester.database.EXPECT().
+		ListTakenEmails(gomock.Any(), []string{"ann@example.com"}).
+		Return(nil, nil)
+
+	assert.NoError(t, tester.service.ImportUsers(stream))
+	assert.Equal(t, int64(2), stream.response.Rows)
+	assert.Zero(t, stream.response.Imported)
+	assert.Equal(t, int64(1), stream.response.Failed)
+	assert.Equal(t, int32(codes.InvalidArgument), stream.response.Errors[0].Code)
+}
+
+func TestImportUsers_DryRunEmailTaken_ShouldReportRowError(t *testing.T) {
+	tester := newTestUserService(t)
+	stream := &testImportStream{
+		ctx: tester.ctx,
+		requests: []*model.ImportUsersRequest{{
+			Format: model.UserDataFormat_USER_DATA_FORMAT_NDJSON,
+			DryRun: true,
+			Data: []byte(`{"name":"ann","email":"Ann@example.com","password":"secret"}` +
+				"\n" + `{"name":"bob","email":"bob@example.com","password":"secret"}` + "\n"),
+		}},
+	}
+	tester.database.EXPECT().
+		ListTakenEmails(gomock.Any(), []string{"ann@example.com", "bob@example.com"}).
+		Return([]string{"ann@example.com"}, nil)
+
+	assert.NoError(t, tester.service.ImportUsers(stream))
+	assert.Equal(t, int64(2), stream.response.Rows)
+	assert.Zero(t, stream.response.Imported)
+	assert.Equal(t, int64(1), stream.response.Failed)
+	assert.Equal(t, int64(1), stream.response.Errors[0].Row)
+	assert.Equal(t, int32(codes.AlreadyExists), stream.response.Errors[0].Code)
+}
@@ -0,0 +1,300 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/clintrovert/go-playground/api/model"
+	database2 "github.com/clintrovert/go-playground/pkg/postgres/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrUserProductQuantityInvalid  = errors.New("product quantity must not be negative")
+	ErrUserProductQuantityExceeded = errors.New("product quantity exceeded the quantity owned")
+	ErrUserProductAssignFailed     = errors.New("product assignment failed")
+	ErrUserProductUnassignFailed   = errors.New("product unassignment failed")
+	ErrUserProductListFailed       = errors.New("user product listing failed")
+)
+
+// AssignProduct adds a quantity of a Product to those owned by a User, which
+// acquires the Product again. The User must not be deleted and the Product
+// must exist.
+func (s *UserService) AssignProduct(
+	ctx context.Context,
+	request *model.AssignProductRequest,
+) (*model.AssignProductResponse, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if err := validateOwnership(request.UserId, request.ProductId, request.Quantity); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	params := database2.AssignProductParams{
+		UserID:    request.UserId,
+		ProductID: request.ProductId,
+		Quantity:  request.Quantity,
+	}
+	if params.Quantity == 0 {
+		params.Quantity = 1
+	}
+
+	var owned database2.UserProduct
+	if err := s.write(ctx, func(ctx context.Context, db UserDatabase) error {
+		var err error
+		owned, err = db.AssignProduct(ctx, params)
+		return err
+	}); err != nil {
+		s.logger(ctx).
+			WithField(userLogField, request.UserId).
+			WithField(productLogField, request.ProductId).
+			Error(err)
+		return nil, databaseError(ctx, err, ErrUserProductAssignFailed)
+	}
+
+	return &model.AssignProductResponse{
+		UserProduct: &model.UserProduct{
+			UserId:     owned.UserID,
+			ProductId:  owned.ProductID,
+			Quantity:   owned.Quantity,
+			AcquiredAt: owned.AcquiredAt.UTC().Format(time.RFC3339),
+		},
+	}, nil
+}
+
+// UnassignProduct removes a quantity of a Product from those owned by a
+// User, or the Product entirely when no quantity is given.
+func (s *UserService) UnassignProduct(
+	ctx context.Context,
+	request *model.UnassignProductRequest,
+) (*model.UnassignProductResponse, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if err := validateOwnership(request.UserId, request.ProductId, request.Quantity); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.write(ctx, func(ctx context.Context, db UserDatabase) error {
+		return unassignProduct(ctx, db, request)
+	}); err != nil {
+		s.logger(ctx).
+			WithField(userLogField, request.UserId).
+			WithField(productLogField, request.ProductId).
+			Error(err)
+		if errors.Is(err, ErrUserProductQuantityExceeded) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, databaseError(ctx, err, ErrUserProductUnassignFailed)
+	}
+
+	return &model.UnassignProductResponse{Unassigned: true}, nil
+}
+
+func unassignProduct(
+	ctx context.Context,
+	db UserDatabase,
+	request *model.UnassignProductRequest,
+) error {
+	key := database2.DeleteUserProductParams{
+		UserID:    request.UserId,
+		ProductID: request.ProductId,
+	}
+
+	if request.Quantity > 0 {
+		owned, err := db.GetUserProductForUpdate(
+			ctx, database2.GetUserProductForUpdateParams(key),
+		)
+		if err != nil {
+			return err
+		}
+		if request.Quantity > owned.Quantity {
+			return ErrUserProductQuantityExceeded
+		}
+		if request.Quantity < owned.Quantity {
+			return db.SetUserProductQuantity(ctx, database2.SetUserProductQuantityParams{
+				UserID:    request.UserId,
+				ProductID: request.ProductId,
+				Quantity:  owned.Quantity - request.Quantity,
+			})
+		}
+	}
+
+	deleted, err := db.DeleteUserProduct(ctx, key)
+	if err == nil && deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// ListUserProducts retrieves a page of the Products owned by a User, most
+// recently acquired first. Deleted Users are not found, as with GetUser.
+func (s *UserService) ListUserProducts(
+	ctx context.Context,
+	request *model.ListUserProductsRequest,
+) (*model.ListUserProductsResponse, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if request.UserId < 1 {
+		return nil, status.Error(codes.InvalidArgument, ErrUserIdInvalid.Error())
+	}
+
+	size, token, digest, err := ownershipPage(
+		request.PageSize, request.PageToken, request.UserId, 0,
+	)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Fetch one more than the page size to learn whether a next page exists.
+	params := database2.ListUserProductsParams{
+		UserID:    request.UserId,
+		PageLimit: size + 1,
+	}
+	if token != nil {
+		acquiredAt, err := time.Parse(time.RFC3339Nano, token.Key)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, ErrPageTokenInvalid.Error())
+		}
+		params.AfterAcquiredAt = sql.NullTime{Time: acquiredAt, Valid: true}
+		params.AfterID = sql.NullInt32{Int32: token.ID, Valid: true}
+	}
+
+	rows, err := s.db.ListUserProducts(ctx, params)
+	if err != nil {
+		s.logger(ctx).WithField(userLogField, request.UserId).Error(err)
+		return nil, databaseError(ctx, err, ErrUserProductListFailed)
+	}
+	// Deleted Users own nothing which is listed, so only an empty page needs
+	// to learn whether the User exists.
+	if len(rows) == 0 {
+		if _, err = s.db.GetUser(ctx, request.UserId); err != nil {
+			s.logger(ctx).WithField(userLogField, request.UserId).Error(err)
+			return nil, databaseError(ctx, err, ErrUserProductListFailed)
+		}
+	}
+
+	response := &model.ListUserProductsResponse{}
+	if len(rows) > int(size) {
+		rows = rows[:size]
+		last := rows[len(rows)-1]
+		if response.NextPageToken, err = encodePageToken(pageToken{
+			Query: digest,
+			Key:   last.AcquiredAt.UTC().Format(time.RFC3339Nano),
+			ID:    last.Product.ProductID,
+		}); err != nil {
+			return nil, status.Error(codes.Internal, ErrUserProductListFailed.Error())
+		}
+	}
+	for _, row := range rows {
+		response.Products = append(response.Products, &model.OwnedProduct{
+			Product:    toProductModel(row.Product),
+			Quantity:   row.Quantity,
+			AcquiredAt: row.AcquiredAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	return response, nil
+}
+
+// ListProductOwners retrieves a page of the Users owning a Product.
+func (s *UserService) ListProductOwners(
+	ctx context.Context,
+	request *model.ListProductOwnersRequest,
+) (*model.ListProductOwnersResponse, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if request.ProductId < 1 {
+		return nil, status.Error(codes.InvalidArgument, ErrProductIdInvalid.Error())
+	}
+
+	size, token, digest, err := ownershipPage(
+		request.PageSize, request.PageToken, 0, request.ProductId,
+	)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Fetch one more than the page size to learn whether a next page exists.
+	params := database2.ListProductOwnersParams{
+		ProductID: request.ProductId,
+		PageLimit: size + 1,
+	}
+	if token != nil {
+		params.AfterID = sql.NullInt32{Int32: token.ID, Valid: true}
+	}
+
+	rows, err := s.db.ListProductOwners(ctx, params)
+	if err != nil {
+		s.logger(ctx).WithField(productLogField, request.ProductId).Error(err)
+		return nil, databaseError(ctx, err, ErrUserProductListFailed)
+	}
+
+	response := &model.ListProductOwnersResponse{}
+	if len(rows) > int(size) {
+		rows = rows[:size]
+		if response.NextPageToken, err = encodePageToken(pageToken{
+			Query: digest,
+			ID:    rows[len(rows)-1].User.UserID,
+		}); err != nil {
+			return nil, status.Error(codes.Internal, ErrUserProductListFailed.Error())
+		}
+	}
+	for _, row := range rows {
+		response.Owners = append(response.Owners, &model.ProductOwner{
+			User:       toUserModel(row.User),
+			Quantity:   row.Quantity,
+			AcquiredAt: row.AcquiredAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	return response, nil
+}
+
+// ownershipPage resolves the page size and token of an ownership listing,
+// which is scoped to either a User or a Product.
+func ownershipPage(
+	requestedSize int32,
+	encoded string,
+	userID int32,
+	productID int32,
+) (int32, *pageToken, string, error) {
+	size, err := pageSize(requestedSize)
+	if err != nil {
+		return 0, nil, "", err
+	}
+
+	digest, err := queryDigest(&model.UserProduct{
+		UserId:    userID,
+		ProductId: productID,
+	})
+	if err != nil {
+		return 0, nil, "", err
+	}
+
+	token, err := decodePageToken(encoded, digest)
+	return size, token, digest, err
+}
+
+func validateOwnership(userID int32, productID int32, quantity int32) error {
+	if userID < 1 {
+		return ErrUserIdInvalid
+	}
+	if productID < 1 {
+		return ErrProductIdInvalid
+	}
+	if quantity < 0 {
+		return ErrUserProductQuantityInvalid
+	}
+	return nil
+}
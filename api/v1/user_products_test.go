@@ -0,0 +1,164 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/clintrovert/go-playground/api/model"
+	"github.com/clintrovert/go-playground/internal/test/utils"
+	"github.com/clintrovert/go-playground/pkg/postgres/database"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAssignProduct_NoQuantity_ShouldAssignOne(t *testing.T) {
+	tester := newTestUserService(t)
+	request := &model.AssignProductRequest{UserId: 1, ProductId: 2}
+
+	tester.database.EXPECT().
+		AssignProduct(tester.ctx, database.AssignProductParams{
+			UserID:    1,
+			ProductID: 2,
+			Quantity:  1,
+		}).
+		Return(database.UserProduct{UserID: 1, ProductID: 2, Quantity: 1}, nil).
+		Times(1)
+
+	response, err := tester.service.AssignProduct(tester.ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), response.UserProduct.Quantity)
+}
+
+func TestAssignProduct_DeletedUser_ShouldReturnNotFound(t *testing.T) {
+	tester := newTestUserService(t)
+	request := &model.AssignProductRequest{UserId: 1, ProductId: 2}
+
+	tester.database.EXPECT().
+		AssignProduct(tester.ctx, gomock.Any()).
+		Return(database.UserProduct{}, sql.ErrNoRows).
+		Times(1)
+
+	response, err := tester.service.AssignProduct(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestUnassignProduct_PartialQuantity_ShouldDecrement(t *testing.T) {
+	tester := newTestUserService(t)
+	request := &model.UnassignProductRequest{UserId: 1, ProductId: 2, Quantity: 2}
+
+	tester.database.EXPECT().
+		GetUserProductForUpdate(tester.ctx, database.GetUserProductForUpdateParams{
+			UserID:    1,
+			ProductID: 2,
+		}).
+		Return(database.UserProduct{UserID: 1, ProductID: 2, Quantity: 5}, nil).
+		Times(1)
+	tester.database.EXPECT().
+		SetUserProductQuantity(tester.ctx, database.SetUserProductQuantityParams{
+			UserID:    1,
+			ProductID: 2,
+			Quantity:  3,
+		}).
+		Return(nil).
+		Times(1)
+
+	response, err := tester.service.UnassignProduct(tester.ctx, request)
+	assert.NoError(t, err)
+	assert.True(t, response.Unassigned)
+}
+
+func TestUnassignProduct_ExcessQuantity_ShouldReturnFailedPrecondition(t *testing.T) {
+	tester := newTestUserService(t)
+	request := &model.UnassignProductRequest{UserId: 1, ProductId: 2, Quantity: 6}
+
+	tester.database.EXPECT().
+		GetUserProductForUpdate(tester.ctx, gomock.Any()).
+		Return(database.UserProduct{UserID: 1, ProductID: 2, Quantity: 5}, nil).
+		Times(1)
+
+	response, err := tester.service.UnassignProduct(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestListUserProducts_MoreThanPageSize_ShouldPageByAcquisition(t *testing.T) {
+	tester := newTestUserService(t)
+	acquiredAt := time.Now()
+	rows := []database.ListUserProductsRow{
+		{Product: utils.GenerateRandomProduct(), Quantity: 1, AcquiredAt: acquiredAt},
+		{Product: utils.GenerateRandomProduct(), Quantity: 2, AcquiredAt: acquiredAt},
+	}
+	request := &model.ListUserProductsRequest{UserId: 1, PageSize: 1}
+
+	tester.database.EXPECT().
+		ListUserProducts(tester.ctx, database.ListUserProductsParams{
+			UserID:    1,
+			PageLimit: 2,
+		}).
+		Return(rows, nil).
+		Times(1)
+
+	response, err := tester.service.ListUserProducts(tester.ctx, request)
+	assert.NoError(t, err)
+	assert.Len(t, response.Products, 1)
+
+	request.PageToken = response.NextPageToken
+	tester.database.EXPECT().
+		ListUserProducts(tester.ctx, gomock.Any()).
+		DoAndReturn(func(
+			_ context.Context,
+			params database.ListUserProductsParams,
+		) ([]database.ListUserProductsRow, error) {
+			assert.Equal(t, rows[0].Product.ProductID, params.AfterID.Int32)
+			assert.True(t, params.AfterAcquiredAt.Time.Equal(acquiredAt))
+			return rows[1:], nil
+		}).
+		Times(1)
+
+	response, err = tester.service.ListUserProducts(tester.ctx, request)
+	assert.NoError(t, err)
+	assert.Len(t, response.Products, 1)
+	assert.Empty(t, response.NextPageToken)
+}
+
+func TestListUserProducts_DeletedUser_ShouldReturnNotFound(t *testing.T) {
+	tester := newTestUserService(t)
+	request := &model.ListUserProductsRequest{UserId: 1}
+
+	tester.database.EXPECT().
+		ListUserProducts(tester.ctx, gomock.Any()).
+		Return(nil, nil).
+		Times(1)
+	tester.database.EXPECT().
+		GetUser(tester.ctx, int32(1)).
+		Return(database.User{}, sql.ErrNoRows).
+		Times(1)
+
+	response, err := tester.service.ListUserProducts(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestListUserProducts_NoProducts_ShouldReturnEmptyPage(t *testing.T) {
+	tester := newTestUserService(t)
+	request := &model.ListUserProductsRequest{UserId: 1}
+
+	tester.database.EXPECT().
+		ListUserProducts(tester.ctx, gomock.Any()).
+		Return(nil, nil).
+		Times(1)
+	tester.database.EXPECT().
+		GetUser(tester.ctx, int32(1)).
+		Return(database.User{UserID: 1}, nil).
+		Times(1)
+
+	response, err := tester.service.ListUserProducts(tester.ctx, request)
+	assert.NoError(t, err)
+	assert.Empty(t, response.Products)
+	assert.Empty(t, response.NextPageToken)
+}
@@ -0,0 +1,283 @@
+package v1
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/clintrovert/go-playground/api/model"
+	database2 "github.com/clintrovert/go-playground/pkg/postgres/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	maxWatchedUsers = 1000
+	watchPageSize   = 500
+	// watchPollInterval bounds how long an event may wait to be sent when
+	// no notification wakes the watch, such as when it was recorded by a
+	// transaction which committed while an older one was still running.
+	watchPollInterval = time.Second * 5
+)
+
+var (
+	ErrWatchTooManyUsers      = errors.New("watch named more than 1000 users")
+	ErrUserEventKindInvalid   = errors.New("user event kind was invalid")
+	ErrResumeTokenInvalid     = errors.New("resume token was invalid")
+	ErrResumeTokenExpired     = errors.New("resume token has expired, the events following it were pruned")
+	ErrUserWatchFailed        = errors.New("user watch failed")
+	errUserEventKindUnmatched = errors.New("user event kind has no model equivalent")
+)
+
+var (
+	userEventKinds = map[model.UserEventKind]database2.UserEventKind{
+		model.UserEventKind_USER_EVENT_KIND_CREATED:  database2.UserEventKindCreated,
+		model.UserEventKind_USER_EVENT_KIND_UPDATED:  database2.UserEventKindUpdated,
+		model.UserEventKind_USER_EVENT_KIND_DELETED:  database2.UserEventKindDeleted,
+		model.UserEventKind_USER_EVENT_KIND_RESTORED: database2.UserEventKindRestored,
+	}
+	userEventKindModels = map[database2.UserEventKind]model.UserEventKind{
+		database2.UserEventKindCreated:  model.UserEventKind_USER_EVENT_KIND_CREATED,
+		database2.UserEventKindUpdated:  model.UserEventKind_USER_EVENT_KIND_UPDATED,
+		database2.UserEventKindDeleted:  model.UserEventKind_USER_EVENT_KIND_DELETED,
+		database2.UserEventKindRestored: model.UserEventKind_USER_EVENT_KIND_RESTORED,
+	}
+)
+
+// UserEventNotifier wakes watches when User events are recorded.
+type UserEventNotifier interface {
+	// Subscribe returns a channel which receives a value after events are
+	// recorded, and a function which ends the subscription.
+	Subscribe() (<-chan struct{}, func())
+}
+
+// resumeToken is the position of an event in the order events are sent.
+type resumeToken struct {
+	// Txid is the ID of the transaction which recorded the event.
+	Txid int64 `json:"x"`
+	// EventID orders the events recorded by a transaction.
+	EventID int64 `json:"i"`
+}
+
+// WatchUsers streams the events of Users as they are created, updated,
+// deleted and restored, in the order their changes committed. A watch
+// resuming from the token of the last event it received is sent every event
+// following it, provided the events have not been pruned.
+func (s *UserService) WatchUsers(
+	request *model.WatchUsersRequest,
+	stream model.UserService_WatchUsersServer,
+) error {
+	ctx := stream.Context()
+	if err := validateContext(ctx); err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	params, err := validateWatchUsersRequest(request)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Subscribing before the first read ensures no notification is missed
+	// between reading and waiting.
+	var wake <-chan struct{}
+	if s.events != nil {
+		var unsubscribe func()
+		wake, unsubscribe = s.events.Subscribe()
+		defer unsubscribe()
+	}
+
+	if err = s.watchPosition(ctx, request.ResumeToken, &params); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			events, err := s.db.ListUserEvents(ctx, params)
+			if err != nil {
+				s.logger(ctx).Error(err)
+				return databaseError(ctx, err, ErrUserWatchFailed)
+			}
+			for _, event := range events {
+				response, err := toUserEventModel(event)
+				if err != nil {
+					s.logger(ctx).WithField(userLogField, event.UserID).Error(err)
+					return status.Error(codes.Internal, ErrUserWatchFailed.Error())
+				}
+				if err = stream.Send(&model.WatchUsersResponse{Event: response}); err != nil {
+					return err
+				}
+				// The next read follows the last event sent, so that no
+				// event is sent twice.
+				params.AfterTxid, params.AfterID = event.Txid, event.EventID
+			}
+			if len(events) < watchPageSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return contextError(ctx)
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchPosition sets the position params list events after, which follows
+// the event of the resume token or, without one, every event visible when
+// the watch starts.
+func (s *UserService) watchPosition(
+	ctx context.Context,
+	encoded string,
+	params *database2.ListUserEventsParams,
+) error {
+	if encoded == "" {
+		head, err := s.db.GetUserEventsHead(ctx)
+		if err != nil {
+			s.logger(ctx).Error(err)
+			return databaseError(ctx, err, ErrUserWatchFailed)
+		}
+		params.AfterTxid = head
+		return nil
+	}
+
+	token, err := decodeResumeToken(encoded)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	exists, err := s.db.UserEventExists(ctx, token.EventID)
+	if err != nil {
+		s.logger(ctx).Error(err)
+		return databaseError(ctx, err, ErrUserWatchFailed)
+	}
+	if !exists {
+		return status.Error(codes.FailedPrecondition, ErrResumeTokenExpired.Error())
+	}
+
+	params.AfterTxid, params.AfterID = token.Txid, token.EventID
+	return nil
+}
+
+// EventPruneWorker returns a background worker which deletes User events
+// older than retention every interval, after which watches can no longer
+// resume from them.
+func (s *UserService) EventPruneWorker(
+	retention time.Duration,
+	interval time.Duration,
+) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if pruned, err := s.pruneUserEvents(ctx, retention); err != nil {
+				s.log.WithError(err).Error("failed to prune user events")
+			} else if pruned > 0 {
+				s.log.WithField("pruned", pruned).Info("pruned user events")
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// pruneUserEvents deletes expired events in batches, so that a large prune
+// does not hold locks on the user_events table for long.
+func (s *UserService) pruneUserEvents(
+	ctx context.Context,
+	retention time.Duration,
+) (int64, error) {
+	params := database2.PruneUserEventsParams{
+		OccurredBefore: time.Now().Add(-retention),
+		BatchSize:      purgeBatchSize,
+	}
+
+	var total int64
+	for {
+		pruned, err := s.db.PruneUserEvents(ctx, params)
+		total += pruned
+		if err != nil || pruned < int64(params.BatchSize) {
+			return total, err
+		}
+	}
+}
+
+func validateWatchUsersRequest(
+	request *model.WatchUsersRequest,
+) (database2.ListUserEventsParams, error) {
+	params := database2.ListUserEventsParams{PageLimit: watchPageSize}
+
+	if len(request.UserIds) > maxWatchedUsers {
+		return params, ErrWatchTooManyUsers
+	}
+	for _, id := range request.UserIds {
+		if id <= 0 {
+			return params, ErrUserIdInvalid
+		}
+	}
+	if len(request.UserIds) > 0 {
+		params.UserIds = request.UserIds
+	}
+
+	for _, kind := range request.Kinds {
+		dbKind, ok := userEventKinds[kind]
+		if !ok {
+			return params, ErrUserEventKindInvalid
+		}
+		params.Kinds = append(params.Kinds, dbKind)
+	}
+
+	return params, nil
+}
+
+func encodeResumeToken(token resumeToken) (string, error) {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeResumeToken(encoded string) (resumeToken, error) {
+	var token resumeToken
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return token, ErrResumeTokenInvalid
+	}
+	if err = json.Unmarshal(b, &token); err != nil || token.EventID <= 0 {
+		return token, ErrResumeTokenInvalid
+	}
+	return token, nil
+}
+
+func toUserEventModel(event database2.UserEvent) (*model.UserEvent, error) {
+	kind, ok := userEventKindModels[event.Kind]
+	if !ok {
+		return nil, errUserEventKindUnmatched
+	}
+	token, err := encodeResumeToken(resumeToken{
+		Txid:    event.Txid,
+		EventID: event.EventID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.UserEvent{
+		ResumeToken: token,
+		Kind:        kind,
+		UserId:      event.UserID,
+		Version:     event.Version,
+		OccurredAt:  event.OccurredAt.UTC().Format(time.RFC3339),
+	}, nil
+}
@@ -0,0 +1,163 @@
+package v1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/clintrovert/go-playground/api/model"
+	"github.com/clintrovert/go-playground/pkg/postgres/database"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testWatchStream collects the events streamed by a watch, cancelling it
+// once it has received limit events.
+type testWatchStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	cancel context.CancelFunc
+	limit  int
+	events []*model.UserEvent
+}
+
+func (s *testWatchStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testWatchStream) Send(response *model.WatchUsersResponse) error {
+	s.events = append(s.events, response.Event)
+	if len(s.events) == s.limit {
+		s.cancel()
+	}
+	return nil
+}
+
+func TestWatchUsers_ResumeToken_ShouldSendFollowingEvents(t *testing.T) {
+	tester := newTestUserService(t)
+	ctx, cancel := context.WithCancel(tester.ctx)
+	defer cancel()
+	stream := &testWatchStream{ctx: ctx, cancel: cancel, limit: 2}
+
+	token, err := encodeResumeToken(resumeToken{Txid: 700, EventID: 41})
+	assert.NoError(t, err)
+	events := []database.UserEvent{
+		{EventID: 43, Txid: 701, UserID: 7, Kind: database.UserEventKindUpdated, Version: 3, OccurredAt: time.Now()},
+		{EventID: 42, Txid: 702, UserID: 7, Kind: database.UserEventKindDeleted, Version: 4, OccurredAt: time.Now()},
+	}
+
+	tester.database.EXPECT().
+		UserEventExists(gomock.Any(), int64(41)).
+		Return(true, nil)
+	tester.database.EXPECT().
+		ListUserEvents(gomock.Any(), database.ListUserEventsParams{
+			AfterTxid: 700,
+			AfterID:   41,
+			UserIds:   []int32{7},
+			Kinds: []database.UserEventKind{
+				database.UserEventKindUpdated, database.UserEventKindDeleted,
+			},
+			PageLimit: watchPageSize,
+		}).
+		Return(events, nil)
+
+	err = tester.service.WatchUsers(&model.WatchUsersRequest{
+		UserIds: []int32{7},
+		Kinds: []model.UserEventKind{
+			model.UserEventKind_USER_EVENT_KIND_UPDATED,
+			model.UserEventKind_USER_EVENT_KIND_DELETED,
+		},
+		ResumeToken: token,
+	}, stream)
+	assert.Equal(t, codes.Canceled, status.Code(err))
+
+	assert.Len(t, stream.events, 2)
+	assert.Equal(t, model.UserEventKind_USER_EVENT_KIND_UPDATED, stream.events[0].Kind)
+	assert.Equal(t, model.UserEventKind_USER_EVENT_KIND_DELETED, stream.events[1].Kind)
+	assert.Equal(t, int32(4), stream.events[1].Version)
+
+	resumed, err := decodeResumeToken(stream.events[1].ResumeToken)
+	assert.NoError(t, err)
+	assert.Equal(t, resumeToken{Txid: 702, EventID: 42}, resumed)
+}
+
+// testEventNotifier wakes watches continually.
+type testEventNotifier struct {
+	wake chan struct{}
+}
+
+func (n *testEventNotifier) Subscribe() (<-chan struct{}, func()) {
+	return n.wake, func() {}
+}
+
+func TestWatchUsers_PartialPages_ShouldNotResendEvents(t *testing.T) {
+	tester := newTestUserService(t)
+	notifier := &testEventNotifier{wake: make(chan struct{})}
+	close(notifier.wake)
+	tester.service.WithEventNotifier(notifier)
+	ctx, cancel := context.WithCancel(tester.ctx)
+	defer cancel()
+	stream := &testWatchStream{ctx: ctx, cancel: cancel, limit: 2}
+
+	first := database.UserEvent{EventID: 11, Txid: 10, UserID: 7, Kind: database.UserEventKindCreated, OccurredAt: time.Now()}
+	second := database.UserEvent{EventID: 12, Txid: 12, UserID: 7, Kind: database.UserEventKindUpdated, OccurredAt: time.Now()}
+	after := func(txid int64, id int64) database.ListUserEventsParams {
+		return database.ListUserEventsParams{AfterTxid: txid, AfterID: id, PageLimit: watchPageSize}
+	}
+
+	tester.database.EXPECT().GetUserEventsHead(gomock.Any()).Return(int64(10), nil)
+	gomock.InOrder(
+		tester.database.EXPECT().
+			ListUserEvents(gomock.Any(), after(10, 0)).
+			Return([]database.UserEvent{first}, nil),
+		tester.database.EXPECT().
+			ListUserEvents(gomock.Any(), after(10, 11)).
+			Return(nil, nil),
+		tester.database.EXPECT().
+			ListUserEvents(gomock.Any(), after(10, 11)).
+			Return([]database.UserEvent{second}, nil),
+	)
+	// The watch may read once more before noticing it was cancelled.
+	tester.database.EXPECT().
+		ListUserEvents(gomock.Any(), after(12, 12)).
+		Return(nil, nil).
+		AnyTimes()
+
+	err := tester.service.WatchUsers(&model.WatchUsersRequest{}, stream)
+	assert.Equal(t, codes.Canceled, status.Code(err))
+
+	assert.Len(t, stream.events, 2)
+	assert.Equal(t, model.UserEventKind_USER_EVENT_KIND_CREATED, stream.events[0].Kind)
+	assert.Equal(t, model.UserEventKind_USER_EVENT_KIND_UPDATED, stream.events[1].Kind)
+}
+
+func TestWatchUsers_PrunedResumeToken_ShouldFailPrecondition(t *testing.T) {
+	tester := newTestUserService(t)
+	stream := &testWatchStream{ctx: tester.ctx}
+
+	token, err := encodeResumeToken(resumeToken{Txid: 700, EventID: 41})
+	assert.NoError(t, err)
+	tester.database.EXPECT().
+		UserEventExists(gomock.Any(), int64(41)).
+		Return(false, nil)
+
+	err = tester.service.WatchUsers(
+		&model.WatchUsersRequest{ResumeToken: token},
+		stream,
+	)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Empty(t, stream.events)
+}
+
+func TestWatchUsers_InvalidKind_ShouldReturnInvalidArgument(t *testing.T) {
+	tester := newTestUserService(t)
+	stream := &testWatchStream{ctx: tester.ctx}
+
+	err := tester.service.WatchUsers(&model.WatchUsersRequest{
+		Kinds: []model.UserEventKind{model.UserEventKind_USER_EVENT_KIND_UNSPECIFIED},
+	}, stream)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -6,52 +6,210 @@ import (
 	"errors"
 	"fmt"
 	"net/mail"
+	"runtime"
 	"strings"
+	"time"
 
 	"github.com/clintrovert/go-playground/api/model"
 	"github.com/clintrovert/go-playground/pkg/cache"
+	"github.com/clintrovert/go-playground/pkg/identity"
+	"github.com/clintrovert/go-playground/pkg/postgres"
 	database2 "github.com/clintrovert/go-playground/pkg/postgres/database"
+	"github.com/clintrovert/go-playground/pkg/requestlog"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 const (
-	notFound     = "not found"
-	userLogField = "user_id"
+	userLogField   = "user_id"
+	purgeBatchSize = 500
+
+	userFieldName     = "name"
+	userFieldEmail    = "email"
+	userFieldPassword = "password"
+	userFieldIsAdmin  = "isAdmin"
 )
 
+// updatableUserFields maps the update mask paths accepted by UpdateUser to
+// the field they update. The snake case is_admin is accepted as an alias of
+// the proto field isAdmin.
+var updatableUserFields = map[string]string{
+	userFieldName:     userFieldName,
+	userFieldEmail:    userFieldEmail,
+	userFieldPassword: userFieldPassword,
+	userFieldIsAdmin:  userFieldIsAdmin,
+	"is_admin":        userFieldIsAdmin,
+}
+
 var (
-	ErrUserAuthFailed      = errors.New("user authentication failed")
-	ErrUserIdInvalid       = errors.New("user id was not specified")
-	ErrUserEmailMissing    = errors.New("user email was not specified")
-	ErrUserEmailInvalid    = errors.New("user email was invalid")
-	ErrUserNameMissing     = errors.New("user name was not specified")
-	ErrUserCreateFailed    = errors.New("user creation failed")
-	ErrUserUpdateFailed    = errors.New("user update failed")
-	ErrUserDeletionFailed  = errors.New("user deletion failed")
-	ErrUserPasswordMissing = errors.New("user password was not specified")
+	ErrUserAuthFailed        = errors.New("user authentication failed")
+	ErrUserIdInvalid         = errors.New("user id was not specified")
+	ErrUserEmailMissing      = errors.New("user email was not specified")
+	ErrUserEmailInvalid      = errors.New("user email was invalid")
+	ErrUserNameMissing       = errors.New("user name was not specified")
+	ErrUserRetrievalFailed   = errors.New("user retrieval failed")
+	ErrUserCreateFailed      = errors.New("user creation failed")
+	ErrUserUpdateFailed      = errors.New("user update failed")
+	ErrUserDeletionFailed    = errors.New("user deletion failed")
+	ErrUserPasswordMissing   = errors.New("user password was not specified")
+	ErrUserListFailed        = errors.New("user listing failed")
+	ErrUserRestoreFailed     = errors.New("user restoration failed")
+	ErrUserHardDeleteDenied  = errors.New("user hard deletion requires an administrator")
+	ErrUserUpdateMaskInvalid = errors.New("user update mask contained an unknown field")
+	ErrUserSortInvalid       = errors.New("user sort order was invalid")
+	ErrUserCreatedInvalid    = errors.New("user created range was invalid")
 )
 
 // UserDatabase provides database operations for Users.
 type UserDatabase interface {
 	// GetUser retrieves a User by their ID from the database.
 	GetUser(ctx context.Context, id int32) (database2.User, error)
-	// CreateUser creates a new User in the database.
-	CreateUser(ctx context.Context, params database2.CreateUserParams) error
-	// UpdateUser updates an existing User in the database.
-	UpdateUser(ctx context.Context, params database2.UpdateUserParams) error
-	// DeleteUser deletes a User from the database.
-	DeleteUser(ctx context.Context, id int32) error
+	// CreateUser creates a new User in the database, returning the created
+	// User.
+	CreateUser(
+		ctx context.Context,
+		params database2.CreateUserParams,
+	) (database2.User, error)
+	// CreateUsers creates a batch of Users in one statement, returning the
+	// created Users. Users whose email is already taken are skipped.
+	CreateUsers(
+		ctx context.Context,
+		params database2.CreateUsersParams,
+	) ([]database2.User, error)
+	// ListTakenEmails returns which of a batch of lowercased emails are
+	// taken by Users which are not deleted.
+	ListTakenEmails(ctx context.Context, emails []string) ([]string, error)
+	// UpdateUser updates the non-null fields of an existing User in the
+	// database, returning the updated User.
+	UpdateUser(
+		ctx context.Context,
+		params database2.UpdateUserParams,
+	) (database2.User, error)
+	// DeleteUser permanently deletes a User from the database, returning the
+	// number of rows deleted.
+	DeleteUser(
+		ctx context.Context,
+		params database2.DeleteUserParams,
+	) (int64, error)
+	// SoftDeleteUser marks a User as deleted, hiding it from reads, returning
+	// the number of rows deleted.
+	SoftDeleteUser(
+		ctx context.Context,
+		params database2.SoftDeleteUserParams,
+	) (int64, error)
+	// RestoreUser reverses the soft deletion of a User, returning the
+	// restored User.
+	RestoreUser(ctx context.Context, id int32) (database2.User, error)
+	// PurgeDeletedUsers permanently deletes up to a batch of Users soft
+	// deleted before a given time, returning the number of rows deleted.
+	PurgeDeletedUsers(
+		ctx context.Context,
+		params database2.PurgeDeletedUsersParams,
+	) (int64, error)
+	// ExportUsers lists a page of Users in order of their ID.
+	ExportUsers(
+		ctx context.Context,
+		params database2.ExportUsersParams,
+	) ([]database2.User, error)
+	// GetUserEventsHead returns the position before every event which is
+	// yet to be listed by ListUserEvents.
+	GetUserEventsHead(ctx context.Context) (int64, error)
+	// UserEventExists reports whether an event has not been pruned.
+	UserEventExists(ctx context.Context, eventID int64) (bool, error)
+	// ListUserEvents lists a page of events after a position, in the order
+	// their transactions committed.
+	ListUserEvents(
+		ctx context.Context,
+		params database2.ListUserEventsParams,
+	) ([]database2.UserEvent, error)
+	// PruneUserEvents deletes up to a batch of events which occurred before
+	// a given time, returning the number of rows deleted.
+	PruneUserEvents(
+		ctx context.Context,
+		params database2.PruneUserEventsParams,
+	) (int64, error)
+	// ListUsersByCreatedAsc lists Users, oldest first.
+	ListUsersByCreatedAsc(
+		ctx context.Context,
+		params database2.ListUsersByCreatedAscParams,
+	) ([]database2.User, error)
+	// ListUsersByCreatedDesc lists Users, newest first.
+	ListUsersByCreatedDesc(
+		ctx context.Context,
+		params database2.ListUsersByCreatedDescParams,
+	) ([]database2.User, error)
+	// ListUsersByNameAsc lists Users alphabetically by name.
+	ListUsersByNameAsc(
+		ctx context.Context,
+		params database2.ListUsersByNameAscParams,
+	) ([]database2.ListUsersByNameAscRow, error)
+	// ListUsersByNameDesc lists Users reverse alphabetically by name.
+	ListUsersByNameDesc(
+		ctx context.Context,
+		params database2.ListUsersByNameDescParams,
+	) ([]database2.ListUsersByNameDescRow, error)
+	// AssignProduct adds a quantity of a Product to those owned by a User,
+	// returning the resulting ownership.
+	AssignProduct(
+		ctx context.Context,
+		params database2.AssignProductParams,
+	) (database2.UserProduct, error)
+	// GetUserProductForUpdate retrieves and locks a User's ownership of a
+	// Product.
+	GetUserProductForUpdate(
+		ctx context.Context,
+		params database2.GetUserProductForUpdateParams,
+	) (database2.UserProduct, error)
+	// SetUserProductQuantity sets the quantity of a Product owned by a User.
+	SetUserProductQuantity(
+		ctx context.Context,
+		params database2.SetUserProductQuantityParams,
+	) error
+	// DeleteUserProduct removes a User's ownership of a Product, returning
+	// the number of rows deleted.
+	DeleteUserProduct(
+		ctx context.Context,
+		params database2.DeleteUserProductParams,
+	) (int64, error)
+	// ListUserProducts lists the Products owned by a User which is not
+	// deleted, most recently acquired first.
+	ListUserProducts(
+		ctx context.Context,
+		params database2.ListUserProductsParams,
+	) ([]database2.ListUserProductsRow, error)
+	// ListProductOwners lists the Users owning a Product in order of their
+	// ID.
+	ListProductOwners(
+		ctx context.Context,
+		params database2.ListProductOwnersParams,
+	) ([]database2.ListProductOwnersRow, error)
+}
+
+// Transactor runs units of work within database transactions.
+type Transactor interface {
+	// RunInTx runs fn within a transaction, rolling back if it fails.
+	RunInTx(
+		ctx context.Context,
+		opts *postgres.TxOptions,
+		fn postgres.TxFunc,
+	) error
 }
 
 // UserService provides functionality to manage Users.
 type UserService struct {
 	model.UnimplementedUserServiceServer
 	db  UserDatabase
+	tx  Transactor
 	log *logrus.Logger
 	kvc cache.KeyValCache
+	// hashConcurrency bounds the passwords hashed in parallel by batches.
+	hashConcurrency int
+	// events wakes watches when events are recorded. Without it watches
+	// poll for events.
+	events UserEventNotifier
 }
 
 // NewUserService creates a new instance of a UserService.
@@ -63,11 +221,35 @@ func NewUserService(db UserDatabase, log *logrus.Logger) (*UserService, error) {
 		return nil, errors.New("log is required")
 	}
 	return &UserService{
-		db:  db,
-		log: log,
+		db:              db,
+		log:             log,
+		hashConcurrency: runtime.GOMAXPROCS(0),
 	}, nil
 }
 
+// WithTransactor runs the service's write paths within transactions started
+// by tx. Without a Transactor writes are issued directly against the db.
+func (s *UserService) WithTransactor(tx Transactor) *UserService {
+	s.tx = tx
+	return s
+}
+
+// WithHashConcurrency sets the maximum number of passwords a batch hashes in
+// parallel, which defaults to GOMAXPROCS.
+func (s *UserService) WithHashConcurrency(n int) *UserService {
+	if n > 0 {
+		s.hashConcurrency = n
+	}
+	return s
+}
+
+// WithEventNotifier wakes WatchUsers streams through events as soon as User
+// events are recorded, rather than when they next poll.
+func (s *UserService) WithEventNotifier(events UserEventNotifier) *UserService {
+	s.events = events
+	return s
+}
+
 // GetUser retrieves a User by their ID from the database.
 func (s *UserService) GetUser(
 	ctx context.Context,
@@ -83,23 +265,15 @@ func (s *UserService) GetUser(
 
 	user, err := s.db.GetUser(ctx, request.UserId)
 	if err != nil {
-		s.log.
+		s.logger(ctx).
 			WithField(userLogField, request.UserId).
 			Error(err)
 
-		if strings.Contains(err.Error(), notFound) {
-			return nil, status.Error(codes.NotFound, "")
-		}
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, databaseError(ctx, err, ErrUserRetrievalFailed)
 	}
 
 	return &model.GetUserResponse{
-		User: &model.User{
-			Id:      user.UserID,
-			Name:    user.Name.String,
-			Email:   user.Email.String,
-			IsAdmin: user.IsAdmin.Bool,
-		},
+		User: toUserModel(user),
 	}, nil
 }
 
@@ -116,45 +290,37 @@ func (s *UserService) CreateUser(
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	encrypted, err := bcrypt.GenerateFromPassword(
-		[]byte(strings.TrimSpace(request.Password)),
-		bcrypt.DefaultCost,
-	)
+	encrypted, err := hashPassword(request.Password)
 	if err != nil {
-		s.log.Error(err)
+		s.logger(ctx).Error(err)
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
 	user := database2.CreateUserParams{
-		Name: sql.NullString{
-			String: strings.TrimSpace(request.Name),
-			Valid:  true,
-		},
-		Email: sql.NullString{
-			String: strings.TrimSpace(request.Email),
-			Valid:  true,
-		},
-		Password: sql.NullString{
-			String: string(encrypted),
-			Valid:  true,
-		},
-		IsAdmin: sql.NullBool{
-			Bool:  request.IsAdmin,
-			Valid: true,
-		},
+		Name:     strings.TrimSpace(request.Name),
+		Email:    strings.TrimSpace(request.Email),
+		Password: encrypted,
+		IsAdmin:  request.IsAdmin,
 	}
 
-	if err = s.db.CreateUser(ctx, user); err != nil {
-		//s.log.
-		//	WithField(userLogField, user.Id).
-		//	Error(err)
-		return nil, status.Error(codes.Internal, ErrUserCreateFailed.Error())
+	var created database2.User
+	if err = s.write(ctx, func(ctx context.Context, db UserDatabase) error {
+		created, err = db.CreateUser(ctx, user)
+		return err
+	}); err != nil {
+		s.logger(ctx).Error(err)
+		return nil, databaseError(ctx, err, ErrUserCreateFailed)
 	}
 
-	return &model.CreateUserResponse{Success: true}, nil
+	return &model.CreateUserResponse{
+		Success: true,
+		Id:      created.UserID,
+		User:    toUserModel(created),
+	}, nil
 }
 
-// UpdateUser modifies an existing User in the database.
+// UpdateUser modifies the fields of an existing User named by the request's
+// update mask, or every field when the mask is empty.
 func (s *UserService) UpdateUser(
 	ctx context.Context,
 	request *model.UpdateUserRequest,
@@ -163,52 +329,83 @@ func (s *UserService) UpdateUser(
 		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
 
-	if err := validateUpdateUserRequest(request); err != nil {
+	fields, err := validateUpdateUserRequest(request)
+	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	encrypted, err := bcrypt.GenerateFromPassword(
-		[]byte(strings.TrimSpace(request.Password)),
-		bcrypt.DefaultCost,
-	)
-	if err != nil {
-		s.log.Error(err)
-		return nil, status.Error(codes.Internal, err.Error())
+	user := updateUserParams(request, fields)
+	if fields[userFieldPassword] {
+		encrypted, err := hashPassword(request.Password)
+		if err != nil {
+			s.logger(ctx).Error(err)
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		user.Password = sql.NullString{String: encrypted, Valid: true}
+	}
+
+	var updated database2.User
+	if err = s.write(ctx, func(ctx context.Context, db UserDatabase) error {
+		updated, err = updateUser(ctx, db, user)
+		return err
+	}); err != nil {
+		s.logger(ctx).
+			WithField(userLogField, fmt.Sprintf("%v", user.UserID)).
+			Error(err)
+		return nil, databaseError(ctx, err, ErrUserUpdateFailed)
 	}
 
+	return &model.UpdateUserResponse{
+		Updated: true,
+		User:    toUserModel(updated),
+	}, nil
+}
+
+// updateUserParams converts the fields of a validated request named by
+// fields into the parameters of an update, leaving the password to be hashed
+// by the caller.
+func updateUserParams(
+	request *model.UpdateUserRequest,
+	fields map[string]bool,
+) database2.UpdateUserParams {
 	user := database2.UpdateUserParams{
-		UserID: request.Id,
-		Name: sql.NullString{
+		UserID:  request.Id,
+		Version: optionalVersion(request.Version),
+	}
+	if fields[userFieldName] {
+		user.Name = sql.NullString{
 			String: strings.TrimSpace(request.Name),
 			Valid:  true,
-		},
-		Email: sql.NullString{
+		}
+	}
+	if fields[userFieldEmail] {
+		user.Email = sql.NullString{
 			String: strings.TrimSpace(request.Email),
 			Valid:  true,
-		},
-		Password: sql.NullString{
-			String: string(encrypted),
-			Valid:  true,
-		},
-		IsAdmin: sql.NullBool{
-			Bool:  request.IsAdmin,
-			Valid: true,
-		},
+		}
 	}
-
-	if err = s.db.UpdateUser(ctx, user); err != nil {
-		s.log.
-			WithField(userLogField, fmt.Sprintf("%v", user.UserID)).
-			Error(err)
-		return nil, status.Error(codes.Internal, ErrUserUpdateFailed.Error())
+	if fields[userFieldIsAdmin] {
+		user.IsAdmin = sql.NullBool{Bool: request.IsAdmin, Valid: true}
 	}
+	return user
+}
 
-	return &model.UpdateUserResponse{
-		Updated: true,
-	}, nil
+// updateUser applies an update, explaining a conditional update which
+// matched no rows.
+func updateUser(
+	ctx context.Context,
+	db UserDatabase,
+	user database2.UpdateUserParams,
+) (database2.User, error) {
+	updated, err := db.UpdateUser(ctx, user)
+	if errors.Is(err, sql.ErrNoRows) && user.Version.Valid {
+		return updated, userVersionMismatch(ctx, db, user.UserID)
+	}
+	return updated, err
 }
 
-// DeleteUser removes an existing User from the database.
+// DeleteUser soft deletes an existing User, so that it may be restored
+// until purged. Administrators may instead delete the User permanently.
 func (s *UserService) DeleteUser(
 	ctx context.Context,
 	request *model.DeleteUserRequest,
@@ -221,16 +418,351 @@ func (s *UserService) DeleteUser(
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	if err := s.db.DeleteUser(ctx, request.UserId); err != nil {
-		s.log.
+	if request.Hard && !identity.IsAdmin(ctx) {
+		return nil, status.Error(codes.PermissionDenied, ErrUserHardDeleteDenied.Error())
+	}
+
+	if err := s.write(ctx, func(ctx context.Context, db UserDatabase) error {
+		return deleteUser(ctx, db, request)
+	}); err != nil {
+		s.logger(ctx).
 			WithField(userLogField, request.UserId).
 			Error(err)
-		return nil, status.Error(codes.Internal, ErrUserDeletionFailed.Error())
+		return nil, databaseError(ctx, err, ErrUserDeletionFailed)
 	}
 
 	return &model.DeleteUserResponse{Deleted: true}, nil
 }
 
+// deleteUser soft or hard deletes a User, explaining a delete which matched
+// no rows.
+func deleteUser(
+	ctx context.Context,
+	db UserDatabase,
+	request *model.DeleteUserRequest,
+) error {
+	version := optionalVersion(request.Version)
+
+	var deleted int64
+	var err error
+	if request.Hard {
+		deleted, err = db.DeleteUser(ctx, database2.DeleteUserParams{
+			UserID:  request.UserId,
+			Version: version,
+		})
+	} else {
+		deleted, err = db.SoftDeleteUser(ctx, database2.SoftDeleteUserParams{
+			UserID:  request.UserId,
+			Version: version,
+		})
+	}
+	if err != nil || deleted > 0 {
+		return err
+	}
+	if version.Valid {
+		return userVersionMismatch(ctx, db, request.UserId)
+	}
+	return sql.ErrNoRows
+}
+
+// RestoreUser reverses the soft deletion of a User which has not yet been
+// purged. A User whose email was taken while it was deleted cannot be
+// restored.
+func (s *UserService) RestoreUser(
+	ctx context.Context,
+	request *model.RestoreUserRequest,
+) (*model.RestoreUserResponse, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if request.UserId < 1 {
+		return nil, status.Error(codes.InvalidArgument, ErrUserIdInvalid.Error())
+	}
+
+	var restored database2.User
+	if err := s.write(ctx, func(ctx context.Context, db UserDatabase) error {
+		var err error
+		restored, err = db.RestoreUser(ctx, request.UserId)
+		return err
+	}); err != nil {
+		s.logger(ctx).
+			WithField(userLogField, request.UserId).
+			Error(err)
+		if errors.Is(postgres.Classify(err), postgres.ErrUniqueViolation) {
+			return nil, status.Error(codes.AlreadyExists, ErrUserEmailTaken.Error())
+		}
+		return nil, databaseError(ctx, err, ErrUserRestoreFailed)
+	}
+
+	return &model.RestoreUserResponse{User: toUserModel(restored)}, nil
+}
+
+// PurgeWorker returns a function which, every interval until ctx is
+// cancelled, permanently deletes Users soft deleted longer than retention
+// ago, for use as a background worker.
+func (s *UserService) PurgeWorker(
+	retention time.Duration,
+	interval time.Duration,
+) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if purged, err := s.purgeDeletedUsers(ctx, retention); err != nil {
+				s.log.WithError(err).Error("failed to purge deleted users")
+			} else if purged > 0 {
+				s.log.WithField("purged", purged).Info("purged deleted users")
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// purgeDeletedUsers deletes expired Users in batches, so that a large purge
+// does not hold locks on the users table for long.
+func (s *UserService) purgeDeletedUsers(
+	ctx context.Context,
+	retention time.Duration,
+) (int64, error) {
+	params := database2.PurgeDeletedUsersParams{
+		DeletedBefore: time.Now().Add(-retention),
+		BatchSize:     purgeBatchSize,
+	}
+
+	var total int64
+	for {
+		purged, err := s.db.PurgeDeletedUsers(ctx, params)
+		total += purged
+		if err != nil || purged < int64(params.BatchSize) {
+			return total, err
+		}
+	}
+}
+
+// ListUsers retrieves a page of Users matching the request's filters.
+func (s *UserService) ListUsers(
+	ctx context.Context,
+	request *model.ListUsersRequest,
+) (*model.ListUsersResponse, error) {
+	if err := validateContext(ctx); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	filter, err := validateListUsersRequest(request)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	size, err := pageSize(request.PageSize)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	query := proto.Clone(request).(*model.ListUsersRequest)
+	query.PageToken, query.PageSize = "", 0
+	digest, err := queryDigest(query)
+	if err != nil {
+		return nil, status.Error(codes.Internal, ErrUserListFailed.Error())
+	}
+
+	token, err := decodePageToken(request.PageToken, digest)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	after, err := newUserCursor(request.SortOrder, token)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Fetch one more than the page size to learn whether a next page exists.
+	users, err := s.listUsers(ctx, request.SortOrder, filter, after, size+1)
+	if err != nil {
+		s.logger(ctx).Error(err)
+		return nil, databaseError(ctx, err, ErrUserListFailed)
+	}
+
+	response := &model.ListUsersResponse{}
+	if len(users) > int(size) {
+		users = users[:size]
+		last := users[len(users)-1]
+		if response.NextPageToken, err = encodePageToken(pageToken{
+			Query: digest,
+			Key:   last.key,
+			ID:    last.user.UserID,
+		}); err != nil {
+			return nil, status.Error(codes.Internal, ErrUserListFailed.Error())
+		}
+	}
+	for _, listed := range users {
+		response.Users = append(response.Users, toUserModel(listed.user))
+	}
+
+	return response, nil
+}
+
+// userFilter holds the ListUsers filters shared by every sort order.
+type userFilter struct {
+	namePrefix    sql.NullString
+	emailPrefix   sql.NullString
+	isAdmin       sql.NullBool
+	createdAfter  sql.NullTime
+	createdBefore sql.NullTime
+}
+
+// userCursor is the position after which a page of Users continues.
+type userCursor struct {
+	createdAt sql.NullTime
+	name      sql.NullString
+	id        sql.NullInt32
+}
+
+// newUserCursor returns the position identified by a page token, which is
+// empty for the first page.
+func newUserCursor(order model.UserSortOrder, token *pageToken) (userCursor, error) {
+	var cursor userCursor
+	if token == nil {
+		return cursor, nil
+	}
+
+	cursor.id = sql.NullInt32{Int32: token.ID, Valid: true}
+	switch order {
+	case model.UserSortOrder_USER_SORT_ORDER_NAME_ASC,
+		model.UserSortOrder_USER_SORT_ORDER_NAME_DESC:
+		cursor.name = sql.NullString{String: token.Key, Valid: true}
+	default:
+		t, err := time.Parse(time.RFC3339Nano, token.Key)
+		if err != nil {
+			return cursor, ErrPageTokenInvalid
+		}
+		cursor.createdAt = sql.NullTime{Time: t, Valid: true}
+	}
+	return cursor, nil
+}
+
+// listedUser is a User and the value it was ordered by, as compared by the
+// list query, from which the position of the next page is built.
+type listedUser struct {
+	user database2.User
+	key  string
+}
+
+// listUsers runs the query for the requested sort order.
+func (s *UserService) listUsers(
+	ctx context.Context,
+	order model.UserSortOrder,
+	f userFilter,
+	after userCursor,
+	limit int32,
+) ([]listedUser, error) {
+	var users []database2.User
+	var err error
+	switch order {
+	case model.UserSortOrder_USER_SORT_ORDER_NAME_ASC:
+		rows, err := s.db.ListUsersByNameAsc(ctx, database2.ListUsersByNameAscParams{
+			NamePrefix:    f.namePrefix,
+			EmailPrefix:   f.emailPrefix,
+			IsAdmin:       f.isAdmin,
+			CreatedAfter:  f.createdAfter,
+			CreatedBefore: f.createdBefore,
+			AfterName:     after.name,
+			AfterID:       after.id,
+			PageLimit:     limit,
+		})
+		listed := make([]listedUser, len(rows))
+		for i, row := range rows {
+			listed[i] = listedUser{user: row.User, key: row.SortName}
+		}
+		return listed, err
+	case model.UserSortOrder_USER_SORT_ORDER_NAME_DESC:
+		rows, err := s.db.ListUsersByNameDesc(ctx, database2.ListUsersByNameDescParams{
+			NamePrefix:    f.namePrefix,
+			EmailPrefix:   f.emailPrefix,
+			IsAdmin:       f.isAdmin,
+			CreatedAfter:  f.createdAfter,
+			CreatedBefore: f.createdBefore,
+			AfterName:     after.name,
+			AfterID:       after.id,
+			PageLimit:     limit,
+		})
+		listed := make([]listedUser, len(rows))
+		for i, row := range rows {
+			listed[i] = listedUser{user: row.User, key: row.SortName}
+		}
+		return listed, err
+	case model.UserSortOrder_USER_SORT_ORDER_CREATED_DESC:
+		users, err = s.db.ListUsersByCreatedDesc(ctx, database2.ListUsersByCreatedDescParams{
+			NamePrefix:     f.namePrefix,
+			EmailPrefix:    f.emailPrefix,
+			IsAdmin:        f.isAdmin,
+			CreatedAfter:   f.createdAfter,
+			CreatedBefore:  f.createdBefore,
+			AfterCreatedAt: after.createdAt,
+			AfterID:        after.id,
+			PageLimit:      limit,
+		})
+	default:
+		users, err = s.db.ListUsersByCreatedAsc(ctx, database2.ListUsersByCreatedAscParams{
+			NamePrefix:     f.namePrefix,
+			EmailPrefix:    f.emailPrefix,
+			IsAdmin:        f.isAdmin,
+			CreatedAfter:   f.createdAfter,
+			CreatedBefore:  f.createdBefore,
+			AfterCreatedAt: after.createdAt,
+			AfterID:        after.id,
+			PageLimit:      limit,
+		})
+	}
+
+	listed := make([]listedUser, len(users))
+	for i, user := range users {
+		listed[i] = listedUser{
+			user: user,
+			key:  user.CreatedAt.UTC().Format(time.RFC3339Nano),
+		}
+	}
+	return listed, err
+}
+
+// userVersionMismatch explains why a conditional write to a User matched no
+// rows, returning ErrVersionMismatch if the User exists and the reason it
+// could not be retrieved otherwise.
+func userVersionMismatch(ctx context.Context, db UserDatabase, id int32) error {
+	if _, err := db.GetUser(ctx, id); err != nil {
+		return err
+	}
+	return ErrVersionMismatch
+}
+
+// toUserModel converts a database User into its API representation. The
+// password hash is never returned to callers.
+func toUserModel(user database2.User) *model.User {
+	return &model.User{
+		Id:        user.UserID,
+		Name:      user.Name,
+		Email:     user.Email,
+		IsAdmin:   user.IsAdmin,
+		CreatedAt: user.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt: user.ModifiedAt.UTC().Format(time.RFC3339),
+		Version:   user.Version,
+	}
+}
+
+// hashPassword bcrypt hashes a requested password.
+func hashPassword(password string) (string, error) {
+	encrypted, err := bcrypt.GenerateFromPassword(
+		[]byte(strings.TrimSpace(password)),
+		bcrypt.DefaultCost,
+	)
+	return string(encrypted), err
+}
+
 func validateGetUserRequest(request *model.GetUserRequest) error {
 	if request.UserId < 1 {
 		return ErrUserIdInvalid
@@ -258,26 +790,80 @@ func validateCreateUserRequest(request *model.CreateUserRequest) error {
 	return nil
 }
 
-func validateUpdateUserRequest(request *model.UpdateUserRequest) error {
-	if strings.TrimSpace(request.Name) == "" {
-		return ErrUserNameMissing
+// validateUpdateUserRequest validates the fields named by the request's
+// update mask, returning the set of fields to update.
+func validateUpdateUserRequest(request *model.UpdateUserRequest) (map[string]bool, error) {
+	if request.Id < 1 {
+		return nil, ErrUserIdInvalid
 	}
-	if strings.TrimSpace(request.Email) == "" {
-		return ErrUserEmailMissing
+
+	fields := map[string]bool{}
+	paths := request.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		paths = []string{
+			userFieldName, userFieldEmail, userFieldPassword, userFieldIsAdmin,
+		}
 	}
-	if _, err := mail.ParseAddress(
-		strings.TrimSpace(request.Email),
-	); err != nil {
-		return ErrUserEmailInvalid
+	for _, path := range paths {
+		field, ok := updatableUserFields[path]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUserUpdateMaskInvalid, path)
+		}
+		fields[field] = true
 	}
-	if strings.TrimSpace(request.Password) == "" {
-		return ErrUserPasswordMissing
+
+	if fields[userFieldName] && strings.TrimSpace(request.Name) == "" {
+		return nil, ErrUserNameMissing
 	}
-	return nil
+	if fields[userFieldEmail] {
+		if strings.TrimSpace(request.Email) == "" {
+			return nil, ErrUserEmailMissing
+		}
+		if _, err := mail.ParseAddress(
+			strings.TrimSpace(request.Email),
+		); err != nil {
+			return nil, ErrUserEmailInvalid
+		}
+	}
+	if fields[userFieldPassword] && strings.TrimSpace(request.Password) == "" {
+		return nil, ErrUserPasswordMissing
+	}
+	return fields, nil
+}
+
+func validateListUsersRequest(request *model.ListUsersRequest) (userFilter, error) {
+	var filter userFilter
+	if _, ok := model.UserSortOrder_name[int32(request.SortOrder)]; !ok {
+		return filter, ErrUserSortInvalid
+	}
+
+	if prefix := strings.TrimSpace(request.NamePrefix); prefix != "" {
+		filter.namePrefix = sql.NullString{String: escapeLike(prefix), Valid: true}
+	}
+	if prefix := strings.TrimSpace(request.EmailPrefix); prefix != "" {
+		filter.emailPrefix = sql.NullString{String: escapeLike(prefix), Valid: true}
+	}
+	if request.IsAdmin != nil {
+		filter.isAdmin = sql.NullBool{Bool: *request.IsAdmin, Valid: true}
+	}
+
+	var err error
+	if filter.createdAfter, err = parseTimestamp(request.CreatedAfter); err != nil {
+		return filter, ErrUserCreatedInvalid
+	}
+	if filter.createdBefore, err = parseTimestamp(request.CreatedBefore); err != nil {
+		return filter, ErrUserCreatedInvalid
+	}
+	if filter.createdAfter.Valid && filter.createdBefore.Valid &&
+		!filter.createdAfter.Time.Before(filter.createdBefore.Time) {
+		return filter, ErrUserCreatedInvalid
+	}
+
+	return filter, nil
 }
 
 func validateDeleteUserRequest(request *model.DeleteUserRequest) error {
-	if request.UserId > 0 {
+	if request.UserId < 1 {
 		return ErrUserIdInvalid
 	}
 
@@ -290,3 +876,46 @@ func validateContext(ctx context.Context) error {
 	}
 	return nil
 }
+
+// parseTimestamp parses an optional RFC 3339 timestamp.
+func parseTimestamp(value string) (sql.NullTime, error) {
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	return sql.NullTime{Time: t, Valid: true}, nil
+}
+
+// escapeLike escapes the LIKE wildcards in a prefix so that it matches
+// literally.
+func escapeLike(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix)
+}
+
+// write runs fn within a read committed transaction when the service has a
+// Transactor, or directly against the service's database otherwise.
+func (s *UserService) write(
+	ctx context.Context,
+	fn func(ctx context.Context, db UserDatabase) error,
+) error {
+	if s.tx == nil {
+		return fn(ctx, s.db)
+	}
+
+	return s.tx.RunInTx(
+		ctx,
+		&postgres.TxOptions{Isolation: sql.LevelReadCommitted},
+		func(ctx context.Context, q *database2.Queries) error {
+			return fn(ctx, q)
+		},
+	)
+}
+
+// logger returns the request scoped logger, falling back to the service
+// logger when the request did not pass through the request log interceptor.
+func (s *UserService) logger(ctx context.Context) *logrus.Entry {
+	return requestlog.FromContext(ctx, s.log)
+}
@@ -2,16 +2,23 @@ package v1
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/clintrovert/go-playground/api/model"
 	"github.com/clintrovert/go-playground/internal/test/mocks"
 	"github.com/clintrovert/go-playground/internal/test/utils"
+	"github.com/clintrovert/go-playground/pkg/identity"
 	"github.com/clintrovert/go-playground/pkg/postgres/database"
 	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 type testUserService struct {
@@ -83,22 +90,385 @@ func TestCreateUser_ValidRequest_ShouldSucceed(t *testing.T) {
 	expected.UserID = 1
 
 	request := &model.CreateUserRequest{
-		Name:     expected.Name.String,
-		Email:    expected.Email.String,
-		Password: expected.Password.String,
+		Name:     expected.Name,
+		Email:    expected.Email,
+		Password: expected.Password,
 	}
 
 	tester.database.EXPECT().
 		CreateUser(tester.ctx, gomock.Any()).
-		Return(nil).
+		Return(expected, nil).
+		Times(1)
+
+	response, err := tester.service.CreateUser(tester.ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, expected.UserID, response.Id)
+	assertUserEqual(t, expected, response.User)
+	assert.Empty(t, response.User.Password)
+}
+
+func TestCreateUser_WithTransactor_ShouldWriteInTx(t *testing.T) {
+	tester := newTestUserService(t)
+	transactor := mocks.NewMockTransactor(gomock.NewController(t))
+	tester.service.WithTransactor(transactor)
+	expected := utils.GenerateRandomUser()
+
+	request := &model.CreateUserRequest{
+		Name:     expected.Name,
+		Email:    expected.Email,
+		Password: expected.Password,
+	}
+
+	transactor.EXPECT().
+		RunInTx(tester.ctx, gomock.Any(), gomock.Any()).
+		Return(errors.New("test-error")).
+		Times(1)
+
+	response, err := tester.service.CreateUser(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestGetUser_DeadlineExceeded_ShouldReturnDeadlineExceeded(t *testing.T) {
+	tester := newTestUserService(t)
+	ctx, cancel := context.WithTimeout(tester.ctx, -time.Second)
+	defer cancel()
+	request := &model.GetUserRequest{
+		UserId: 1,
+	}
+
+	tester.database.EXPECT().
+		GetUser(ctx, request.UserId).
+		Return(database.User{}, ctx.Err()).
+		Times(1)
+
+	response, err := tester.service.GetUser(ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestGetUser_NoRows_ShouldReturnNotFound(t *testing.T) {
+	tester := newTestUserService(t)
+	request := &model.GetUserRequest{
+		UserId: 1,
+	}
+
+	tester.database.EXPECT().
+		GetUser(tester.ctx, request.UserId).
+		Return(database.User{}, sql.ErrNoRows).
 		Times(1)
 
-	_, err := tester.service.CreateUser(tester.ctx, request)
+	response, err := tester.service.GetUser(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestCreateUser_UniqueViolation_ShouldReturnAlreadyExists(t *testing.T) {
+	tester := newTestUserService(t)
+	expected := utils.GenerateRandomUser()
+
+	request := &model.CreateUserRequest{
+		Name:     expected.Name,
+		Email:    expected.Email,
+		Password: expected.Password,
+	}
+
+	tester.database.EXPECT().
+		CreateUser(tester.ctx, gomock.Any()).
+		Return(
+			database.User{},
+			&pq.Error{Code: "23505", Constraint: "users_email_lower_key"},
+		).
+		Times(1)
+
+	response, err := tester.service.CreateUser(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+}
+
+func TestListUsers_MoreThanPageSize_ShouldReturnNextPageToken(t *testing.T) {
+	tester := newTestUserService(t)
+	users := []database.User{
+		utils.GenerateRandomUser(),
+		utils.GenerateRandomUser(),
+		utils.GenerateRandomUser(),
+	}
+	request := &model.ListUsersRequest{PageSize: 2, NamePrefix: "name_"}
+
+	tester.database.EXPECT().
+		ListUsersByCreatedAsc(tester.ctx, database.ListUsersByCreatedAscParams{
+			NamePrefix: sql.NullString{String: `name\_`, Valid: true},
+			PageLimit:  3,
+		}).
+		Return(users, nil).
+		Times(1)
+
+	response, err := tester.service.ListUsers(tester.ctx, request)
+	assert.NoError(t, err)
+	assert.Len(t, response.Users, 2)
+	assert.NotEmpty(t, response.NextPageToken)
+
+	request.PageToken = response.NextPageToken
+	tester.database.EXPECT().
+		ListUsersByCreatedAsc(tester.ctx, gomock.Any()).
+		DoAndReturn(func(
+			_ context.Context,
+			params database.ListUsersByCreatedAscParams,
+		) ([]database.User, error) {
+			assert.Equal(t, users[1].UserID, params.AfterID.Int32)
+			assert.True(t, params.AfterCreatedAt.Time.Equal(users[1].CreatedAt))
+			return users[2:], nil
+		}).
+		Times(1)
+
+	response, err = tester.service.ListUsers(tester.ctx, request)
+	assert.NoError(t, err)
+	assert.Len(t, response.Users, 1)
+	assert.Empty(t, response.NextPageToken)
+}
+
+func TestListUsers_ByName_ShouldResumeAfterDatabaseSortName(t *testing.T) {
+	tester := newTestUserService(t)
+	first, second := utils.GenerateRandomUser(), utils.GenerateRandomUser()
+	first.Name = "ÉMILE"
+	request := &model.ListUsersRequest{
+		PageSize:  1,
+		SortOrder: model.UserSortOrder_USER_SORT_ORDER_NAME_ASC,
+	}
+
+	// Under the C locale the database lowercases only ASCII letters, unlike
+	// Go.
+	tester.database.EXPECT().
+		ListUsersByNameAsc(tester.ctx, gomock.Any()).
+		Return([]database.ListUsersByNameAscRow{
+			{User: first, SortName: "Émile"},
+			{User: second, SortName: "jane"},
+		}, nil).
+		Times(1)
+
+	response, err := tester.service.ListUsers(tester.ctx, request)
+	assert.NoError(t, err)
+	assert.Len(t, response.Users, 1)
+
+	request.PageToken = response.NextPageToken
+	tester.database.EXPECT().
+		ListUsersByNameAsc(tester.ctx, gomock.Any()).
+		DoAndReturn(func(
+			_ context.Context,
+			params database.ListUsersByNameAscParams,
+		) ([]database.ListUsersByNameAscRow, error) {
+			assert.Equal(t, "Émile", params.AfterName.String)
+			assert.Equal(t, first.UserID, params.AfterID.Int32)
+			return nil, nil
+		}).
+		Times(1)
+
+	response, err = tester.service.ListUsers(tester.ctx, request)
+	assert.NoError(t, err)
+	assert.Empty(t, response.Users)
+}
+
+func TestListUsers_TokenFromDifferentQuery_ShouldReturnInvalidArgument(t *testing.T) {
+	tester := newTestUserService(t)
+	request := &model.ListUsersRequest{PageSize: 1}
+
+	tester.database.EXPECT().
+		ListUsersByCreatedAsc(tester.ctx, gomock.Any()).
+		Return([]database.User{
+			utils.GenerateRandomUser(),
+			utils.GenerateRandomUser(),
+		}, nil).
+		Times(1)
+
+	response, err := tester.service.ListUsers(tester.ctx, request)
+	assert.NoError(t, err)
+
+	response, err = tester.service.ListUsers(tester.ctx, &model.ListUsersRequest{
+		PageSize:   1,
+		PageToken:  response.NextPageToken,
+		NamePrefix: "other",
+	})
+	assert.Nil(t, response)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestListUsers_InvalidCreatedRange_ShouldReturnInvalidArgument(t *testing.T) {
+	tester := newTestUserService(t)
+	request := &model.ListUsersRequest{
+		CreatedAfter:  "2023-06-02T00:00:00Z",
+		CreatedBefore: "2023-06-01T00:00:00Z",
+	}
+
+	response, err := tester.service.ListUsers(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestUpdateUser_NameMask_ShouldOnlyUpdateName(t *testing.T) {
+	tester := newTestUserService(t)
+	expected := utils.GenerateRandomUser()
+	request := &model.UpdateUserRequest{
+		Id:         expected.UserID + 1,
+		Name:       expected.Name,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name"}},
+	}
+
+	tester.database.EXPECT().
+		UpdateUser(tester.ctx, database.UpdateUserParams{
+			UserID: request.Id,
+			Name:   sql.NullString{String: expected.Name, Valid: true},
+		}).
+		Return(expected, nil).
+		Times(1)
+
+	response, err := tester.service.UpdateUser(tester.ctx, request)
+	assert.NoError(t, err)
+	assert.True(t, response.Updated)
+	assertUserEqual(t, expected, response.User)
+}
+
+func TestUpdateUser_UnknownMaskPath_ShouldReturnInvalidArgument(t *testing.T) {
+	tester := newTestUserService(t)
+	request := &model.UpdateUserRequest{
+		Id:         1,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"created_at"}},
+	}
+
+	response, err := tester.service.UpdateUser(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestUpdateUser_StaleVersion_ShouldReturnAborted(t *testing.T) {
+	tester := newTestUserService(t)
+	expected := utils.GenerateRandomUser()
+	request := &model.UpdateUserRequest{
+		Id:         expected.UserID + 1,
+		Name:       expected.Name,
+		Version:    1,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name"}},
+	}
+
+	tester.database.EXPECT().
+		UpdateUser(tester.ctx, gomock.Any()).
+		Return(database.User{}, sql.ErrNoRows).
+		Times(1)
+	tester.database.EXPECT().
+		GetUser(tester.ctx, request.Id).
+		Return(expected, nil).
+		Times(1)
+
+	response, err := tester.service.UpdateUser(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.Aborted, status.Code(err))
+}
+
+func TestDeleteUser_ValidRequest_ShouldSucceed(t *testing.T) {
+	tester := newTestUserService(t)
+	request := &model.DeleteUserRequest{UserId: 1, Version: 2}
+
+	tester.database.EXPECT().
+		SoftDeleteUser(tester.ctx, database.SoftDeleteUserParams{
+			UserID:  1,
+			Version: sql.NullInt32{Int32: 2, Valid: true},
+		}).
+		Return(int64(1), nil).
+		Times(1)
+
+	response, err := tester.service.DeleteUser(tester.ctx, request)
+	assert.NoError(t, err)
+	assert.True(t, response.Deleted)
+}
+
+func TestDeleteUser_NoRows_ShouldReturnNotFound(t *testing.T) {
+	tester := newTestUserService(t)
+	request := &model.DeleteUserRequest{UserId: 1}
+
+	tester.database.EXPECT().
+		SoftDeleteUser(tester.ctx, gomock.Any()).
+		Return(int64(0), nil).
+		Times(1)
+
+	response, err := tester.service.DeleteUser(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestDeleteUser_HardAsAdmin_ShouldDeletePermanently(t *testing.T) {
+	tester := newTestUserService(t)
+	ctx := identity.WithAdmin(tester.ctx)
+	request := &model.DeleteUserRequest{UserId: 1, Hard: true}
+
+	tester.database.EXPECT().
+		DeleteUser(ctx, database.DeleteUserParams{UserID: 1}).
+		Return(int64(1), nil).
+		Times(1)
+
+	response, err := tester.service.DeleteUser(ctx, request)
+	assert.NoError(t, err)
+	assert.True(t, response.Deleted)
+}
+
+func TestDeleteUser_HardAsNonAdmin_ShouldReturnPermissionDenied(t *testing.T) {
+	tester := newTestUserService(t)
+	request := &model.DeleteUserRequest{UserId: 1, Hard: true}
+
+	response, err := tester.service.DeleteUser(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestRestoreUser_NotDeleted_ShouldReturnNotFound(t *testing.T) {
+	tester := newTestUserService(t)
+	request := &model.RestoreUserRequest{UserId: 1}
+
+	tester.database.EXPECT().
+		RestoreUser(tester.ctx, request.UserId).
+		Return(database.User{}, sql.ErrNoRows).
+		Times(1)
+
+	response, err := tester.service.RestoreUser(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestRestoreUser_EmailTaken_ShouldReturnAlreadyExists(t *testing.T) {
+	tester := newTestUserService(t)
+	request := &model.RestoreUserRequest{UserId: 1}
+
+	tester.database.EXPECT().
+		RestoreUser(tester.ctx, request.UserId).
+		Return(
+			database.User{},
+			&pq.Error{Code: "23505", Constraint: "users_email_lower_key"},
+		).
+		Times(1)
+
+	response, err := tester.service.RestoreUser(tester.ctx, request)
+	assert.Nil(t, response)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	assert.Equal(t, ErrUserEmailTaken.Error(), status.Convert(err).Message())
+}
+
+func TestPurgeDeletedUsers_FullBatch_ShouldPurgeUntilExhausted(t *testing.T) {
+	tester := newTestUserService(t)
+
+	gomock.InOrder(
+		tester.database.EXPECT().
+			PurgeDeletedUsers(tester.ctx, gomock.Any()).
+			Return(int64(purgeBatchSize), nil),
+		tester.database.EXPECT().
+			PurgeDeletedUsers(tester.ctx, gomock.Any()).
+			Return(int64(3), nil),
+	)
+
+	purged, err := tester.service.purgeDeletedUsers(tester.ctx, time.Hour)
 	assert.NoError(t, err)
+	assert.Equal(t, int64(purgeBatchSize+3), purged)
 }
 
 func assertUserEqual(t *testing.T, expected database.User, actual *model.User) {
 	assert.Equal(t, expected.UserID, actual.Id)
-	assert.Equal(t, expected.Name.String, actual.Name)
-	assert.Equal(t, expected.Email.String, actual.Email)
+	assert.Equal(t, expected.Name, actual.Name)
+	assert.Equal(t, expected.Email, actual.Email)
 }
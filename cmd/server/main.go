@@ -1,66 +1,221 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"os"
 	"time"
 
 	"github.com/clintrovert/go-playground/internal/playground"
+	"github.com/clintrovert/go-playground/pkg/cache"
+	"github.com/clintrovert/go-playground/pkg/outbox"
+	"github.com/clintrovert/go-playground/pkg/postgres"
 	"github.com/clintrovert/go-playground/pkg/postgres/database"
 	"github.com/clintrovert/go-playground/pkg/redis"
 	"github.com/clintrovert/go-playground/pkg/server"
+	"github.com/clintrovert/go-playground/pkg/tracing"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
-	driver     = "postgres"
-	connEnvVar = "POSTGRES_CONN_STR"
-	grpcAddr   = ":9099"
-	httpAddr   = ":8088"
+	traceEnvVar  = "TRACING_EXPORTER"
+	outboxEnvVar = "OUTBOX_FILE"
+	serviceName  = "go-playground"
+	grpcAddr     = ":9099"
+	httpAddr     = ":8088"
+
+	userEventsChannel = "user_events"
+	outboxChannel     = "outbox"
 )
 
-var cacheTtl = time.Hour
+var (
+	cacheTtl       = time.Hour
+	idempotencyTtl = time.Hour * 24
+	// Idempotency records which are never replayed are swept this often.
+	cacheSweepInterval = time.Minute * 10
+	defaultTimeout     = time.Second * 5
+	// Batches hash up to a thousand passwords, so are given longer by
+	// default, which is also their maximum.
+	defaultTimeouts = map[string]time.Duration{
+		"/playground.UserService/BatchCreateUsers": time.Second * 60,
+		"/playground.UserService/BatchUpdateUsers": time.Second * 60,
+		"/playground.UserService/BatchDeleteUsers": time.Second * 30,
+	}
+	maxTimeouts = map[string]time.Duration{
+		"/playground.UserService/CreateUser": time.Second * 10,
+		"/playground.UserService/UpdateUser": time.Second * 10,
+	}
+
+	replicaCheckInterval = time.Second * 5
+
+	// Soft deleted users can be restored until they are purged.
+	deletedUserRetention = time.Hour * 24 * 30
+	purgeInterval        = time.Hour
+
+	// Watches can resume from events until they are pruned.
+	userEventRetention     = time.Hour * 24 * 7
+	userEventPruneInterval = time.Hour
+
+	// Stock held by reservations which were not committed in time is
+	// returned to products this often.
+	reservationExpiryInterval = time.Minute
+)
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == migrateCmd {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	limiter := playground.NewRateLimiter()
 	recoveryOpts := []recovery.Option{
 		recovery.WithRecoveryHandler(playground.Recover),
 	}
 	rdb := redis.NewRedisCache()
+	log := logrus.New()
+	log.SetFormatter(&logrus.JSONFormatter{})
+
+	tp, shutdown := getTracerProvider()
+	defer func() {
+		if err := shutdown(context.Background()); err != nil {
+			log.Error(err)
+		}
+	}()
+
+	metrics := prometheus.NewRegistry()
+	idempotencyCache := cache.NewMemoryCache()
 
 	srv, err := server.NewBuilder(grpcAddr, httpAddr).
-		WithMetrics(prometheus.DefaultRegisterer).
+		WithTracing(tp).
+		WithRequestLogging(log).
+		WithMetrics(metrics).
+		WithDeadlines(defaultTimeout, defaultTimeouts, maxTimeouts).
 		WithCache(rdb, redis.GenerateKeyFromRpc, cacheTtl).
 		WithAuth(playground.Authorize).
 		WithRecovery(recoveryOpts).
 		WithRateLimiter(limiter).
 		WithGrpcReflection().
 		WithGrpcValidation().
+		WithIdempotency(
+			idempotencyCache,
+			idempotencyTtl,
+			"/playground.UserService/CreateUser",
+			"/playground.UserService/BatchCreateUsers",
+		).
 		Build()
 
 	if err != nil {
 		panic(err)
 	}
 
-	db := getDatabase()
+	srv.RunInBackground(idempotencyCache.SweepWorker(cacheSweepInterval))
+
+	cfg, pool, replicas := openDatabase(metrics)
+	srv.CloseOnShutdown(pool)
+	for _, replica := range replicas {
+		srv.CloseOnShutdown(replica)
+	}
+
+	router := postgres.NewRouter(pool, replicas)
+	srv.RunInBackground(router.HealthCheckWorker(replicaCheckInterval))
+
+	db := database.New(tracing.WrapDB(router))
+	tx := postgres.NewTransactor(pool, func(d database.DBTX) database.DBTX {
+		return tracing.WrapDB(router.TrackWrites(d))
+	})
+
+	// Watches are woken by the notifications of the user events trigger.
+	listener := postgres.NewListener(cfg.ConnStr, userEventsChannel)
+	srv.CloseOnShutdown(listener)
+	srv.RunInBackground(listener.Run)
 
 	// Register service RPCs on playground
-	playground.RegisterUserService(srv.GrpcServer, db)
-	playground.RegisterProductService(srv.GrpcServer, db)
+	users := playground.RegisterUserService(srv.GrpcServer, db, tx, log)
+	users.WithEventNotifier(listener)
+	srv.RunInBackground(
+		users.PurgeWorker(deletedUserRetention, purgeInterval),
+		users.EventPruneWorker(userEventRetention, userEventPruneInterval),
+	)
+	products := playground.RegisterProductService(srv.GrpcServer, db, tx, log)
+	srv.RunInBackground(products.ReservationExpiryWorker(reservationExpiryInterval))
+	playground.RegisterSearchService(srv.GrpcServer, db, log)
+
+	// Domain events written to the outbox by changes to users and products
+	// are relayed to the publisher.
+	publisher := getOutboxPublisher()
+	srv.CloseOnShutdown(publisher)
+	outboxListener := postgres.NewListener(cfg.ConnStr, outboxChannel)
+	srv.CloseOnShutdown(outboxListener)
+	relay := outbox.NewRelay(db, tx, publisher, log).WithNotifier(outboxListener)
+	srv.RunInBackground(outboxListener.Run, relay.Run)
 
 	srv.HttpServer.ReadHeaderTimeout = time.Second * 2
 
 	srv.Serve()
 }
 
-func getDatabase() *database.Queries {
-	// Open a connection to the database cluster.
-	connStr := os.Getenv(connEnvVar)
-	postgres, err := sql.Open(driver, connStr)
+// openDatabase connects to the primary and any read replicas configured
+// through the POSTGRES_* environment variables, registering pool metrics
+// with registerer when it is not nil.
+func openDatabase(
+	registerer prometheus.Registerer,
+) (postgres.Config, *sql.DB, []*sql.DB) {
+	cfg, err := postgres.ConfigFromEnv()
+	if err != nil {
+		panic(err)
+	}
+
+	pool, err := postgres.Open(context.Background(), cfg, registerer)
+	if err != nil {
+		panic(err)
+	}
+
+	replicas, err := postgres.OpenReplicas(context.Background(), cfg, registerer)
+	if err != nil {
+		panic(err)
+	}
+
+	return cfg, pool, replicas
+}
+
+// getOutboxPublisher creates a publisher appending events to the file named
+// by OUTBOX_FILE, or writing them to stdout when it is unset.
+func getOutboxPublisher() *outbox.WriterPublisher {
+	path := os.Getenv(outboxEnvVar)
+	if path == "" {
+		return outbox.NewWriterPublisher(os.Stdout)
+	}
+
+	publisher, err := outbox.NewFilePublisher(path)
+	if err != nil {
+		panic(err)
+	}
+	return publisher
+}
+
+// getTracerProvider creates a provider exporting spans through the exporter
+// named by TRACING_EXPORTER ("otlp" or "stdout"), or disables tracing when
+// it is unset.
+func getTracerProvider() (trace.TracerProvider, func(context.Context) error) {
+	kind := os.Getenv(traceEnvVar)
+	if kind == "" {
+		return trace.NewNoopTracerProvider(), func(context.Context) error {
+			return nil
+		}
+	}
+
+	exporter, err := tracing.NewExporter(context.Background(), kind)
 	if err != nil {
 		panic(err)
 	}
 
-	return database.New(postgres)
+	tp := tracing.NewTracerProvider(exporter, serviceName)
+	return tp, tp.Shutdown
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/clintrovert/go-playground/pkg/postgres"
+)
+
+const (
+	migrateCmd    = "migrate"
+	migrateUp     = "up"
+	migrateDown   = "down"
+	migrateStatus = "status"
+	migrateUsage  = "usage: server migrate up|down|status"
+)
+
+var errMigrateUsage = errors.New(migrateUsage)
+
+// runMigrate applies, reverts or reports on the schema migrations embedded
+// in the postgres package.
+func runMigrate(args []string) error {
+	if len(args) != 1 {
+		return errMigrateUsage
+	}
+
+	ctx := context.Background()
+	cfg, err := postgres.ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+
+	db, err := postgres.Open(ctx, cfg, nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := postgres.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case migrateUp:
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return err
+	case migrateDown:
+		reverted, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("reverted %d_%s\n", reverted.Version, reverted.Name)
+		return nil
+	case migrateStatus:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return errMigrateUsage
+	}
+}
@@ -3,6 +3,7 @@ package playground
 import (
 	"context"
 
+	"github.com/clintrovert/go-playground/pkg/identity"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -14,9 +15,12 @@ func Authorize(ctx context.Context) (context.Context, error) {
 		return nil, err
 	}
 	// TODO: This is example only, perform proper Oauth/OIDC verification!
-	if token != "test" {
+	switch token {
+	case "test":
+		return ctx, nil
+	case "test-admin":
+		return identity.WithAdmin(ctx), nil
+	default:
 		return nil, status.Errorf(codes.Unauthenticated, "invalid auth token")
 	}
-	
-	return ctx, nil
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/clintrovert/go-playground/api/model"
 	v1 "github.com/clintrovert/go-playground/api/v1"
+	"github.com/clintrovert/go-playground/pkg/postgres"
 	"github.com/clintrovert/go-playground/pkg/postgres/database"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
@@ -13,15 +14,43 @@ import (
 func RegisterUserService(
 	server *grpc.Server,
 	queries *database.Queries,
-) {
-	svc, err := v1.NewUserService(queries, logrus.New())
+	tx *postgres.Transactor,
+	log *logrus.Logger,
+) *v1.UserService {
+	svc, err := v1.NewUserService(queries, log)
 	if err != nil {
 		panic(fmt.Sprintf("user service failed initialization - " + err.Error()))
 	}
-	model.RegisterUserServiceServer(server, svc)
+	model.RegisterUserServiceServer(server, svc.WithTransactor(tx))
 	logrus.Info("user service registered")
+	return svc
 }
 
-func RegisterProductService(server *grpc.Server, queries *database.Queries) {
+func RegisterProductService(
+	server *grpc.Server,
+	queries *database.Queries,
+	tx *postgres.Transactor,
+	log *logrus.Logger,
+) *v1.ProductService {
+	svc, err := v1.NewProductService(queries, log)
+	if err != nil {
+		panic(fmt.Sprintf("product service failed initialization - " + err.Error()))
+	}
+	model.RegisterProductServiceServer(server, svc.WithTransactor(tx))
 	logrus.Info("product service registered")
+	return svc
+}
+
+func RegisterSearchService(
+	server *grpc.Server,
+	queries *database.Queries,
+	log *logrus.Logger,
+) *v1.SearchService {
+	svc, err := v1.NewSearchService(queries, log)
+	if err != nil {
+		panic(fmt.Sprintf("search service failed initialization - " + err.Error()))
+	}
+	model.RegisterSearchServiceServer(server, svc)
+	logrus.Info("search service registered")
+	return svc
 }
@@ -0,0 +1,200 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: api/v1/products.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	database "github.com/clintrovert/go-playground/pkg/postgres/database"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockProductDatabase is a mock of ProductDatabase interface.
+type MockProductDatabase struct {
+	ctrl     *gomock.Controller
+	recorder *MockProductDatabaseMockRecorder
+}
+
+// MockProductDatabaseMockRecorder is the mock recorder for MockProductDatabase.
+type MockProductDatabaseMockRecorder struct {
+	mock *MockProductDatabase
+}
+
+// NewMockProductDatabase creates a new mock instance.
+func NewMockProductDatabase(ctrl *gomock.Controller) *MockProductDatabase {
+	mock := &MockProductDatabase{ctrl: ctrl}
+	mock.recorder = &MockProductDatabaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProductDatabase) EXPECT() *MockProductDatabaseMockRecorder {
+	return m.recorder
+}
+
+// AdjustProductStock mocks base method.
+func (m *MockProductDatabase) AdjustProductStock(ctx context.Context, params database.AdjustProductStockParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdjustProductStock", ctx, params)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AdjustProductStock indicates an expected call of AdjustProductStock.
+func (mr *MockProductDatabaseMockRecorder) AdjustProductStock(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdjustProductStock", reflect.TypeOf((*MockProductDatabase)(nil).AdjustProductStock), ctx, params)
+}
+
+// CreateProduct mocks base method.
+func (m *MockProductDatabase) CreateProduct(ctx context.Context, params database.CreateProductParams) (database.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateProduct", ctx, params)
+	ret0, _ := ret[0].(database.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateProduct indicates an expected call of CreateProduct.
+func (mr *MockProductDatabaseMockRecorder) CreateProduct(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateProduct", reflect.TypeOf((*MockProductDatabase)(nil).CreateProduct), ctx, params)
+}
+
+// CreateReservation mocks base method.
+func (m *MockProductDatabase) CreateReservation(ctx context.Context, params database.CreateReservationParams) (database.StockReservation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateReservation", ctx, params)
+	ret0, _ := ret[0].(database.StockReservation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateReservation indicates an expected call of CreateReservation.
+func (mr *MockProductDatabaseMockRecorder) CreateReservation(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateReservation", reflect.TypeOf((*MockProductDatabase)(nil).CreateReservation), ctx, params)
+}
+
+// DeleteProduct mocks base method.
+func (m *MockProductDatabase) DeleteProduct(ctx context.Context, params database.DeleteProductParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteProduct", ctx, params)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteProduct indicates an expected call of DeleteProduct.
+func (mr *MockProductDatabaseMockRecorder) DeleteProduct(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteProduct", reflect.TypeOf((*MockProductDatabase)(nil).DeleteProduct), ctx, params)
+}
+
+// GetProduct mocks base method.
+func (m *MockProductDatabase) GetProduct(ctx context.Context, id int32) (database.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProduct", ctx, id)
+	ret0, _ := ret[0].(database.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProduct indicates an expected call of GetProduct.
+func (mr *MockProductDatabaseMockRecorder) GetProduct(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProduct", reflect.TypeOf((*MockProductDatabase)(nil).GetProduct), ctx, id)
+}
+
+// GetProductForUpdate mocks base method.
+func (m *MockProductDatabase) GetProductForUpdate(ctx context.Context, id int32) (database.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProductForUpdate", ctx, id)
+	ret0, _ := ret[0].(database.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProductForUpdate indicates an expected call of GetProductForUpdate.
+func (mr *MockProductDatabaseMockRecorder) GetProductForUpdate(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProductForUpdate", reflect.TypeOf((*MockProductDatabase)(nil).GetProductForUpdate), ctx, id)
+}
+
+// GetReservationForUpdate mocks base method.
+func (m *MockProductDatabase) GetReservationForUpdate(ctx context.Context, id int32) (database.StockReservation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReservationForUpdate", ctx, id)
+	ret0, _ := ret[0].(database.StockReservation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReservationForUpdate indicates an expected call of GetReservationForUpdate.
+func (mr *MockProductDatabaseMockRecorder) GetReservationForUpdate(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReservationForUpdate", reflect.TypeOf((*MockProductDatabase)(nil).GetReservationForUpdate), ctx, id)
+}
+
+// ListExpiredReservations mocks base method.
+func (m *MockProductDatabase) ListExpiredReservations(ctx context.Context, params database.ListExpiredReservationsParams) ([]database.StockReservation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListExpiredReservations", ctx, params)
+	ret0, _ := ret[0].([]database.StockReservation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListExpiredReservations indicates an expected call of ListExpiredReservations.
+func (mr *MockProductDatabaseMockRecorder) ListExpiredReservations(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListExpiredReservations", reflect.TypeOf((*MockProductDatabase)(nil).ListExpiredReservations), ctx, params)
+}
+
+// ListProducts mocks base method.
+func (m *MockProductDatabase) ListProducts(ctx context.Context, params database.ListProductsParams) ([]database.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListProducts", ctx, params)
+	ret0, _ := ret[0].([]database.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListProducts indicates an expected call of ListProducts.
+func (mr *MockProductDatabaseMockRecorder) ListProducts(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListProducts", reflect.TypeOf((*MockProductDatabase)(nil).ListProducts), ctx, params)
+}
+
+// ResolveReservation mocks base method.
+func (m *MockProductDatabase) ResolveReservation(ctx context.Context, params database.ResolveReservationParams) (database.StockReservation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResolveReservation", ctx, params)
+	ret0, _ := ret[0].(database.StockReservation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResolveReservation indicates an expected call of ResolveReservation.
+func (mr *MockProductDatabaseMockRecorder) ResolveReservation(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolveReservation", reflect.TypeOf((*MockProductDatabase)(nil).ResolveReservation), ctx, params)
+}
+
+// UpdateProduct mocks base method.
+func (m *MockProductDatabase) UpdateProduct(ctx context.Context, params database.UpdateProductParams) (database.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateProduct", ctx, params)
+	ret0, _ := ret[0].(database.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateProduct indicates an expected call of UpdateProduct.
+func (mr *MockProductDatabaseMockRecorder) UpdateProduct(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProduct", reflect.TypeOf((*MockProductDatabase)(nil).UpdateProduct), ctx, params)
+}
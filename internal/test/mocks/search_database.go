@@ -0,0 +1,66 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: api/v1/search.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	database "github.com/clintrovert/go-playground/pkg/postgres/database"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockSearchDatabase is a mock of SearchDatabase interface.
+type MockSearchDatabase struct {
+	ctrl     *gomock.Controller
+	recorder *MockSearchDatabaseMockRecorder
+}
+
+// MockSearchDatabaseMockRecorder is the mock recorder for MockSearchDatabase.
+type MockSearchDatabaseMockRecorder struct {
+	mock *MockSearchDatabase
+}
+
+// NewMockSearchDatabase creates a new mock instance.
+func NewMockSearchDatabase(ctrl *gomock.Controller) *MockSearchDatabase {
+	mock := &MockSearchDatabase{ctrl: ctrl}
+	mock.recorder = &MockSearchDatabaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSearchDatabase) EXPECT() *MockSearchDatabaseMockRecorder {
+	return m.recorder
+}
+
+// SearchProducts mocks base method.
+func (m *MockSearchDatabase) SearchProducts(ctx context.Context, params database.SearchProductsParams) ([]database.SearchProductsRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchProducts", ctx, params)
+	ret0, _ := ret[0].([]database.SearchProductsRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchProducts indicates an expected call of SearchProducts.
+func (mr *MockSearchDatabaseMockRecorder) SearchProducts(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchProducts", reflect.TypeOf((*MockSearchDatabase)(nil).SearchProducts), ctx, params)
+}
+
+// SearchUsers mocks base method.
+func (m *MockSearchDatabase) SearchUsers(ctx context.Context, params database.SearchUsersParams) ([]database.SearchUsersRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchUsers", ctx, params)
+	ret0, _ := ret[0].([]database.SearchUsersRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchUsers indicates an expected call of SearchUsers.
+func (mr *MockSearchDatabaseMockRecorder) SearchUsers(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsers", reflect.TypeOf((*MockSearchDatabase)(nil).SearchUsers), ctx, params)
+}
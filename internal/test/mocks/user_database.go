@@ -8,7 +8,8 @@ import (
 	context "context"
 	reflect "reflect"
 
-	database2 "github.com/clintrovert/go-playground/pkg/postgres/database"
+	postgres "github.com/clintrovert/go-playground/pkg/postgres"
+	database "github.com/clintrovert/go-playground/pkg/postgres/database"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -35,12 +36,28 @@ func (m *MockUserDatabase) EXPECT() *MockUserDatabaseMockRecorder {
 	return m.recorder
 }
 
+// AssignProduct mocks base method.
+func (m *MockUserDatabase) AssignProduct(ctx context.Context, params database.AssignProductParams) (database.UserProduct, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AssignProduct", ctx, params)
+	ret0, _ := ret[0].(database.UserProduct)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AssignProduct indicates an expected call of AssignProduct.
+func (mr *MockUserDatabaseMockRecorder) AssignProduct(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AssignProduct", reflect.TypeOf((*MockUserDatabase)(nil).AssignProduct), ctx, params)
+}
+
 // CreateUser mocks base method.
-func (m *MockUserDatabase) CreateUser(ctx context.Context, params database2.CreateUserParams) error {
+func (m *MockUserDatabase) CreateUser(ctx context.Context, params database.CreateUserParams) (database.User, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "CreateUser", ctx, params)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(database.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // CreateUser indicates an expected call of CreateUser.
@@ -49,25 +66,71 @@ func (mr *MockUserDatabaseMockRecorder) CreateUser(ctx, params interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockUserDatabase)(nil).CreateUser), ctx, params)
 }
 
+// CreateUsers mocks base method.
+func (m *MockUserDatabase) CreateUsers(ctx context.Context, params database.CreateUsersParams) ([]database.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUsers", ctx, params)
+	ret0, _ := ret[0].([]database.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUsers indicates an expected call of CreateUsers.
+func (mr *MockUserDatabaseMockRecorder) CreateUsers(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUsers", reflect.TypeOf((*MockUserDatabase)(nil).CreateUsers), ctx, params)
+}
+
 // DeleteUser mocks base method.
-func (m *MockUserDatabase) DeleteUser(ctx context.Context, id int32) error {
+func (m *MockUserDatabase) DeleteUser(ctx context.Context, params database.DeleteUserParams) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteUser", ctx, id)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, params)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // DeleteUser indicates an expected call of DeleteUser.
-func (mr *MockUserDatabaseMockRecorder) DeleteUser(ctx, id interface{}) *gomock.Call {
+func (mr *MockUserDatabaseMockRecorder) DeleteUser(ctx, params interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockUserDatabase)(nil).DeleteUser), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockUserDatabase)(nil).DeleteUser), ctx, params)
+}
+
+// DeleteUserProduct mocks base method.
+func (m *MockUserDatabase) DeleteUserProduct(ctx context.Context, params database.DeleteUserProductParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserProduct", ctx, params)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteUserProduct indicates an expected call of DeleteUserProduct.
+func (mr *MockUserDatabaseMockRecorder) DeleteUserProduct(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserProduct", reflect.TypeOf((*MockUserDatabase)(nil).DeleteUserProduct), ctx, params)
+}
+
+// ExportUsers mocks base method.
+func (m *MockUserDatabase) ExportUsers(ctx context.Context, params database.ExportUsersParams) ([]database.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportUsers", ctx, params)
+	ret0, _ := ret[0].([]database.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExportUsers indicates an expected call of ExportUsers.
+func (mr *MockUserDatabaseMockRecorder) ExportUsers(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportUsers", reflect.TypeOf((*MockUserDatabase)(nil).ExportUsers), ctx, params)
 }
 
 // GetUser mocks base method.
-func (m *MockUserDatabase) GetUser(ctx context.Context, id int32) (database2.User, error) {
+func (m *MockUserDatabase) GetUser(ctx context.Context, id int32) (database.User, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetUser", ctx, id)
-	ret0, _ := ret[0].(database2.User)
+	ret0, _ := ret[0].(database.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -78,16 +141,293 @@ func (mr *MockUserDatabaseMockRecorder) GetUser(ctx, id interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockUserDatabase)(nil).GetUser), ctx, id)
 }
 
-// UpdateUser mocks base method.
-func (m *MockUserDatabase) UpdateUser(ctx context.Context, params database2.UpdateUserParams) error {
+// GetUserEventsHead mocks base method.
+func (m *MockUserDatabase) GetUserEventsHead(ctx context.Context) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateUser", ctx, params)
+	ret := m.ctrl.Call(m, "GetUserEventsHead", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserEventsHead indicates an expected call of GetUserEventsHead.
+func (mr *MockUserDatabaseMockRecorder) GetUserEventsHead(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserEventsHead", reflect.TypeOf((*MockUserDatabase)(nil).GetUserEventsHead), ctx)
+}
+
+// GetUserProductForUpdate mocks base method.
+func (m *MockUserDatabase) GetUserProductForUpdate(ctx context.Context, params database.GetUserProductForUpdateParams) (database.UserProduct, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserProductForUpdate", ctx, params)
+	ret0, _ := ret[0].(database.UserProduct)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserProductForUpdate indicates an expected call of GetUserProductForUpdate.
+func (mr *MockUserDatabaseMockRecorder) GetUserProductForUpdate(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserProductForUpdate", reflect.TypeOf((*MockUserDatabase)(nil).GetUserProductForUpdate), ctx, params)
+}
+
+// ListProductOwners mocks base method.
+func (m *MockUserDatabase) ListProductOwners(ctx context.Context, params database.ListProductOwnersParams) ([]database.ListProductOwnersRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListProductOwners", ctx, params)
+	ret0, _ := ret[0].([]database.ListProductOwnersRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListProductOwners indicates an expected call of ListProductOwners.
+func (mr *MockUserDatabaseMockRecorder) ListProductOwners(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListProductOwners", reflect.TypeOf((*MockUserDatabase)(nil).ListProductOwners), ctx, params)
+}
+
+// ListTakenEmails mocks base method.
+func (m *MockUserDatabase) ListTakenEmails(ctx context.Context, emails []string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTakenEmails", ctx, emails)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTakenEmails indicates an expected call of ListTakenEmails.
+func (mr *MockUserDatabaseMockRecorder) ListTakenEmails(ctx, emails interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTakenEmails", reflect.TypeOf((*MockUserDatabase)(nil).ListTakenEmails), ctx, emails)
+}
+
+// ListUserEvents mocks base method.
+func (m *MockUserDatabase) ListUserEvents(ctx context.Context, params database.ListUserEventsParams) ([]database.UserEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUserEvents", ctx, params)
+	ret0, _ := ret[0].([]database.UserEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUserEvents indicates an expected call of ListUserEvents.
+func (mr *MockUserDatabaseMockRecorder) ListUserEvents(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUserEvents", reflect.TypeOf((*MockUserDatabase)(nil).ListUserEvents), ctx, params)
+}
+
+// ListUserProducts mocks base method.
+func (m *MockUserDatabase) ListUserProducts(ctx context.Context, params database.ListUserProductsParams) ([]database.ListUserProductsRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUserProducts", ctx, params)
+	ret0, _ := ret[0].([]database.ListUserProductsRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUserProducts indicates an expected call of ListUserProducts.
+func (mr *MockUserDatabaseMockRecorder) ListUserProducts(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUserProducts", reflect.TypeOf((*MockUserDatabase)(nil).ListUserProducts), ctx, params)
+}
+
+// ListUsersByCreatedAsc mocks base method.
+func (m *MockUserDatabase) ListUsersByCreatedAsc(ctx context.Context, params database.ListUsersByCreatedAscParams) ([]database.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsersByCreatedAsc", ctx, params)
+	ret0, _ := ret[0].([]database.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsersByCreatedAsc indicates an expected call of ListUsersByCreatedAsc.
+func (mr *MockUserDatabaseMockRecorder) ListUsersByCreatedAsc(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsersByCreatedAsc", reflect.TypeOf((*MockUserDatabase)(nil).ListUsersByCreatedAsc), ctx, params)
+}
+
+// ListUsersByCreatedDesc mocks base method.
+func (m *MockUserDatabase) ListUsersByCreatedDesc(ctx context.Context, params database.ListUsersByCreatedDescParams) ([]database.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsersByCreatedDesc", ctx, params)
+	ret0, _ := ret[0].([]database.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsersByCreatedDesc indicates an expected call of ListUsersByCreatedDesc.
+func (mr *MockUserDatabaseMockRecorder) ListUsersByCreatedDesc(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsersByCreatedDesc", reflect.TypeOf((*MockUserDatabase)(nil).ListUsersByCreatedDesc), ctx, params)
+}
+
+// ListUsersByNameAsc mocks base method.
+func (m *MockUserDatabase) ListUsersByNameAsc(ctx context.Context, params database.ListUsersByNameAscParams) ([]database.ListUsersByNameAscRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsersByNameAsc", ctx, params)
+	ret0, _ := ret[0].([]database.ListUsersByNameAscRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsersByNameAsc indicates an expected call of ListUsersByNameAsc.
+func (mr *MockUserDatabaseMockRecorder) ListUsersByNameAsc(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsersByNameAsc", reflect.TypeOf((*MockUserDatabase)(nil).ListUsersByNameAsc), ctx, params)
+}
+
+// ListUsersByNameDesc mocks base method.
+func (m *MockUserDatabase) ListUsersByNameDesc(ctx context.Context, params database.ListUsersByNameDescParams) ([]database.ListUsersByNameDescRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsersByNameDesc", ctx, params)
+	ret0, _ := ret[0].([]database.ListUsersByNameDescRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsersByNameDesc indicates an expected call of ListUsersByNameDesc.
+func (mr *MockUserDatabaseMockRecorder) ListUsersByNameDesc(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsersByNameDesc", reflect.TypeOf((*MockUserDatabase)(nil).ListUsersByNameDesc), ctx, params)
+}
+
+// PruneUserEvents mocks base method.
+func (m *MockUserDatabase) PruneUserEvents(ctx context.Context, params database.PruneUserEventsParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PruneUserEvents", ctx, params)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PruneUserEvents indicates an expected call of PruneUserEvents.
+func (mr *MockUserDatabaseMockRecorder) PruneUserEvents(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PruneUserEvents", reflect.TypeOf((*MockUserDatabase)(nil).PruneUserEvents), ctx, params)
+}
+
+// PurgeDeletedUsers mocks base method.
+func (m *MockUserDatabase) PurgeDeletedUsers(ctx context.Context, params database.PurgeDeletedUsersParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeletedUsers", ctx, params)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeletedUsers indicates an expected call of PurgeDeletedUsers.
+func (mr *MockUserDatabaseMockRecorder) PurgeDeletedUsers(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeletedUsers", reflect.TypeOf((*MockUserDatabase)(nil).PurgeDeletedUsers), ctx, params)
+}
+
+// RestoreUser mocks base method.
+func (m *MockUserDatabase) RestoreUser(ctx context.Context, id int32) (database.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreUser", ctx, id)
+	ret0, _ := ret[0].(database.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreUser indicates an expected call of RestoreUser.
+func (mr *MockUserDatabaseMockRecorder) RestoreUser(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreUser", reflect.TypeOf((*MockUserDatabase)(nil).RestoreUser), ctx, id)
+}
+
+// SetUserProductQuantity mocks base method.
+func (m *MockUserDatabase) SetUserProductQuantity(ctx context.Context, params database.SetUserProductQuantityParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserProductQuantity", ctx, params)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// SetUserProductQuantity indicates an expected call of SetUserProductQuantity.
+func (mr *MockUserDatabaseMockRecorder) SetUserProductQuantity(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserProductQuantity", reflect.TypeOf((*MockUserDatabase)(nil).SetUserProductQuantity), ctx, params)
+}
+
+// SoftDeleteUser mocks base method.
+func (m *MockUserDatabase) SoftDeleteUser(ctx context.Context, params database.SoftDeleteUserParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SoftDeleteUser", ctx, params)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SoftDeleteUser indicates an expected call of SoftDeleteUser.
+func (mr *MockUserDatabaseMockRecorder) SoftDeleteUser(ctx, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SoftDeleteUser", reflect.TypeOf((*MockUserDatabase)(nil).SoftDeleteUser), ctx, params)
+}
+
+// UpdateUser mocks base method.
+func (m *MockUserDatabase) UpdateUser(ctx context.Context, params database.UpdateUserParams) (database.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, params)
+	ret0, _ := ret[0].(database.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // UpdateUser indicates an expected call of UpdateUser.
 func (mr *MockUserDatabaseMockRecorder) UpdateUser(ctx, params interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockUserDatabase)(nil).UpdateUser), ctx, params)
 }
+
+// UserEventExists mocks base method.
+func (m *MockUserDatabase) UserEventExists(ctx context.Context, eventID int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserEventExists", ctx, eventID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UserEventExists indicates an expected call of UserEventExists.
+func (mr *MockUserDatabaseMockRecorder) UserEventExists(ctx, eventID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserEventExists", reflect.TypeOf((*MockUserDatabase)(nil).UserEventExists), ctx, eventID)
+}
+
+// MockTransactor is a mock of Transactor interface.
+type MockTransactor struct {
+	ctrl     *gomock.Controller
+	recorder *MockTransactorMockRecorder
+}
+
+// MockTransactorMockRecorder is the mock recorder for MockTransactor.
+type MockTransactorMockRecorder struct {
+	mock *MockTransactor
+}
+
+// NewMockTransactor creates a new mock instance.
+func NewMockTransactor(ctrl *gomock.Controller) *MockTransactor {
+	mock := &MockTransactor{ctrl: ctrl}
+	mock.recorder = &MockTransactorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTransactor) EXPECT() *MockTransactorMockRecorder {
+	return m.recorder
+}
+
+// RunInTx mocks base method.
+func (m *MockTransactor) RunInTx(ctx context.Context, opts *postgres.TxOptions, fn postgres.TxFunc) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RunInTx", ctx, opts, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RunInTx indicates an expected call of RunInTx.
+func (mr *MockTransactorMockRecorder) RunInTx(ctx, opts, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunInTx", reflect.TypeOf((*MockTransactor)(nil).RunInTx), ctx, opts, fn)
+}
@@ -1,7 +1,6 @@
 package utils
 
 import (
-	"database/sql"
 	"math/rand"
 
 	"github.com/clintrovert/go-playground/pkg/postgres/database"
@@ -24,18 +23,19 @@ func RandomPrefixedString(prefix string, n int) string {
 func GenerateRandomUser() database.User {
 	return database.User{
 		UserID: int32(rand.Intn(1000)),
-		Name: sql.NullString{
-			String: RandomPrefixedString("name", 10),
-			Valid:  true,
-		},
-		Email: sql.NullString{
-			String: RandomPrefixedString("email", 5) +
-				"@" + RandomPrefixedString("domain", 5) + ".com",
-			Valid: true,
-		},
-		Password: sql.NullString{
-			String: RandomPrefixedString("pwd", 10),
-			Valid:  true,
-		},
+		Name:   RandomPrefixedString("name", 10),
+		Email: RandomPrefixedString("email", 5) +
+			"@" + RandomPrefixedString("domain", 5) + ".com",
+		Password: RandomPrefixedString("pwd", 10),
+	}
+}
+
+func GenerateRandomProduct() database.Product {
+	return database.Product{
+		ProductID:  int32(rand.Intn(1000)),
+		Name:       RandomPrefixedString("product", 10),
+		PriceMinor: int64(rand.Intn(10000)),
+		Currency:   "USD",
+		Stock:      int32(rand.Intn(100)),
 	}
 }
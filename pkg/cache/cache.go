@@ -4,7 +4,9 @@ import (
 	"context"
 	"time"
 
+	"github.com/clintrovert/go-playground/pkg/tracing"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/proto"
@@ -12,6 +14,8 @@ import (
 
 var cacheHit = metadata.Pairs("x-cache", "hit")
 
+const cacheHitAttribute = "cache.hit"
+
 type KeyGenerationFunc func(
 	ctx context.Context,
 	req proto.Message,
@@ -48,23 +52,39 @@ func (c *CacheInterceptor) UnaryServerInterceptor(
 		handler grpc.UnaryHandler,
 	) (any, error) {
 
-		key, err := keyFunc(ctx, request.(proto.Message), info)
+		message, ok := request.(proto.Message)
+		if !ok {
+			return handler(ctx, request)
+		}
+		key, err := keyFunc(ctx, message, info)
 		if err != nil {
 			return nil, err
 		}
+		// An empty key marks a request whose response must not be cached.
+		if key == "" {
+			return handler(ctx, request)
+		}
 
-		if val, found := c.cache.Get(ctx, key); found {
+		lookupCtx, span := tracing.StartSpan(ctx, "cache.Get")
+		val, found := c.cache.Get(lookupCtx, key)
+		span.SetAttributes(attribute.Bool(cacheHitAttribute, found))
+		span.End()
+
+		if found {
 			if err = grpc.SendHeader(ctx, cacheHit); err != nil {
-				return val, nil
+				c.log.WithError(err).Debug("cache hit header was not sent")
 			}
+			return val, nil
 		}
 
 		resp, err := handler(ctx, request)
 		if err != nil {
 			return nil, err
 		}
-		if err = c.cache.Set(ctx, key, resp, ttl); err != nil {
-
+		storeCtx, span := tracing.StartSpan(ctx, "cache.Set")
+		defer span.End()
+		if err = c.cache.Set(storeCtx, key, resp, ttl); err != nil {
+			c.log.WithError(err).Warn("response was not cached")
 		}
 		return resp, nil
 	}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clintrovert/go-playground/api/model"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestInterceptor_TracedRequest_ShouldRecordCacheSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+
+	interceptor := NewKeyValCacheInterceptor(
+		NewMemoryCache(), logrus.NewEntry(logrus.New()),
+	).UnaryServerInterceptor(
+		func(context.Context, proto.Message, *grpc.UnaryServerInfo) (string, error) {
+			return "key", nil
+		},
+		0,
+	)
+	info := &grpc.UnaryServerInfo{FullMethod: "/playground.UserService/GetUser"}
+	handler := func(context.Context, any) (any, error) {
+		return &model.GetUserResponse{}, nil
+	}
+
+	for n := 0; n < 2; n++ {
+		_, err := interceptor(ctx, &model.GetUserRequest{UserId: 1}, info, handler)
+		assert.NoError(t, err)
+	}
+	parent.End()
+
+	// The first call misses and stores the response, the second hits.
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 4)
+	names := make([]string, 0, len(spans))
+	for _, span := range spans[:3] {
+		names = append(names, span.Name)
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+	}
+	assert.Equal(t, []string{"cache.Get", "cache.Set", "cache.Get"}, names)
+	assert.Contains(t, spans[0].Attributes, attribute.Bool(cacheHitAttribute, false))
+	assert.Contains(t, spans[2].Attributes, attribute.Bool(cacheHitAttribute, true))
+}
+
+func TestInterceptor_EmptyKey_ShouldNotCache(t *testing.T) {
+	interceptor := NewKeyValCacheInterceptor(
+		NewMemoryCache(), logrus.NewEntry(logrus.New()),
+	).UnaryServerInterceptor(
+		func(context.Context, proto.Message, *grpc.UnaryServerInfo) (string, error) {
+			return "", nil
+		},
+		0,
+	)
+	info := &grpc.UnaryServerInfo{FullMethod: "/playground.UserService/CreateUser"}
+	calls := 0
+	handler := func(context.Context, any) (any, error) {
+		calls++
+		return &model.CreateUserResponse{}, nil
+	}
+
+	for n := 0; n < 2; n++ {
+		_, err := interceptor(context.Background(), &model.CreateUserRequest{}, info, handler)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, calls)
+}
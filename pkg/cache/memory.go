@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	val     any
+	expires time.Time
+}
+
+// MemoryCache is a process local KeyValCache, suitable for single instance
+// deployments and tests.
+type MemoryCache struct {
+	mu      sync.RWMutex
+	entries map[string]entry
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		entries: map[string]entry{},
+	}
+}
+
+func (m *MemoryCache) Get(ctx context.Context, key string) (any, bool) {
+	m.mu.RLock()
+	e, ok := m.entries[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		m.mu.Lock()
+		if current, ok := m.entries[key]; ok && current.expires == e.expires {
+			delete(m.entries, key)
+		}
+		m.mu.Unlock()
+		return nil, false
+	}
+	return e.val, true
+}
+
+func (m *MemoryCache) Set(
+	ctx context.Context,
+	key string,
+	val any,
+	ttl time.Duration,
+) error {
+	e := entry{val: val}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+
+	m.mu.Lock()
+	m.entries[key] = e
+	m.mu.Unlock()
+	return nil
+}
+
+// Sweep deletes every expired entry. Entries are otherwise only evicted when
+// read, so keys which are never read again would be held forever.
+func (m *MemoryCache) Sweep() {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, e := range m.entries {
+		if !e.expires.IsZero() && now.After(e.expires) {
+			delete(m.entries, key)
+		}
+	}
+}
+
+// SweepWorker returns a function which sweeps the cache every interval until
+// ctx is cancelled, for use as a background worker.
+func (m *MemoryCache) SweepWorker(
+	interval time.Duration,
+) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				m.Sweep()
+			}
+		}
+	}
+}
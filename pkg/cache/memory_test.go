@@ -0,0 +1,26 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache_Sweep_ShouldDeleteOnlyExpiredEntries(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryCache()
+	assert.NoError(t, m.Set(ctx, "expired", 1, time.Millisecond))
+	assert.NoError(t, m.Set(ctx, "live", 2, time.Hour))
+	assert.NoError(t, m.Set(ctx, "forever", 3, 0))
+
+	time.Sleep(time.Millisecond * 5)
+	m.Sweep()
+
+	assert.Len(t, m.entries, 2)
+	_, found := m.Get(ctx, "live")
+	assert.True(t, found)
+	_, found = m.Get(ctx, "forever")
+	assert.True(t, found)
+}
@@ -0,0 +1,154 @@
+package deadline
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var ErrDeadlineExceeded = errors.New("request deadline exceeded")
+
+// Enforcer applies a default deadline to requests which arrive without one
+// and caps client supplied deadlines at a per-method maximum.
+type Enforcer struct {
+	defaultTimeout  time.Duration
+	defaultTimeouts map[string]time.Duration
+	maxTimeouts     map[string]time.Duration
+}
+
+// NewEnforcer creates a new instance of an Enforcer. The defaultTimeout is
+// applied to unary requests without a deadline and also acts as the maximum
+// for any method not present in maxTimeouts, which is keyed by full method
+// name (e.g. /playground.UserService/GetUser), unless the method is given
+// its own default by WithDefaultTimeouts.
+func NewEnforcer(
+	defaultTimeout time.Duration,
+	maxTimeouts map[string]time.Duration,
+) *Enforcer {
+	if maxTimeouts == nil {
+		maxTimeouts = map[string]time.Duration{}
+	}
+	return &Enforcer{
+		defaultTimeout:  defaultTimeout,
+		defaultTimeouts: map[string]time.Duration{},
+		maxTimeouts:     maxTimeouts,
+	}
+}
+
+// WithDefaultTimeouts overrides the default applied to requests without a
+// deadline for the methods in defaultTimeouts, keyed by full method name. A
+// method's default also acts as its maximum unless it has one in
+// maxTimeouts.
+func (e *Enforcer) WithDefaultTimeouts(
+	defaultTimeouts map[string]time.Duration,
+) *Enforcer {
+	if defaultTimeouts == nil {
+		defaultTimeouts = map[string]time.Duration{}
+	}
+	e.defaultTimeouts = defaultTimeouts
+	return e
+}
+
+// UnaryServerInterceptor bounds the lifetime of every unary request.
+func (e *Enforcer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		request any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		ctx, cancel := e.bound(ctx, info.FullMethod, true)
+		defer cancel()
+
+		resp, err := handler(ctx, request)
+		return resp, e.translate(ctx, err)
+	}
+}
+
+// StreamServerInterceptor bounds the lifetime of streams for methods which
+// have an explicit maximum configured. Streams are long-lived by nature, so
+// the default timeout is never applied to them.
+func (e *Enforcer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		_, hasMax := e.maxTimeouts[info.FullMethod]
+		_, hasDefault := e.defaultTimeouts[info.FullMethod]
+		if !hasMax && !hasDefault {
+			return handler(srv, stream)
+		}
+
+		ctx, cancel := e.bound(stream.Context(), info.FullMethod, false)
+		defer cancel()
+
+		wrapped := middleware.WrapServerStream(stream)
+		wrapped.WrappedContext = ctx
+		return e.translate(ctx, handler(srv, wrapped))
+	}
+}
+
+// bound derives a context whose deadline is never later than the maximum
+// allowed for the method.
+func (e *Enforcer) bound(
+	ctx context.Context,
+	method string,
+	applyDefault bool,
+) (context.Context, context.CancelFunc) {
+	limit := e.limit(method)
+	if limit <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	if d, ok := ctx.Deadline(); ok {
+		if time.Until(d) <= limit {
+			return context.WithCancel(ctx)
+		}
+		return context.WithTimeout(ctx, limit)
+	}
+
+	if !applyDefault {
+		return context.WithTimeout(ctx, limit)
+	}
+
+	timeout := e.defaultTimeout
+	if d, ok := e.defaultTimeouts[method]; ok {
+		timeout = d
+	}
+	if timeout <= 0 || timeout > limit {
+		timeout = limit
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (e *Enforcer) limit(method string) time.Duration {
+	if max, ok := e.maxTimeouts[method]; ok {
+		return max
+	}
+	if d, ok := e.defaultTimeouts[method]; ok {
+		return d
+	}
+	return e.defaultTimeout
+}
+
+// translate ensures that a handler which ran out of time reports
+// DeadlineExceeded regardless of how the underlying failure surfaced.
+func (e *Enforcer) translate(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	if s, ok := status.FromError(err); ok && s.Code() == codes.DeadlineExceeded {
+		return err
+	}
+	return status.Error(codes.DeadlineExceeded, ErrDeadlineExceeded.Error())
+}
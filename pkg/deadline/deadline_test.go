@@ -0,0 +1,192 @@
+package deadline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	getUser    = "/playground.UserService/GetUser"
+	createUser = "/playground.UserService/CreateUser"
+	watchUsers = "/playground.UserService/WatchUsers"
+	importData = "/playground.UserService/ImportUsers"
+	batchUsers = "/playground.UserService/BatchCreateUsers"
+)
+
+// testStream is a server stream carrying only a context.
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testStream) Context() context.Context {
+	return s.ctx
+}
+
+func newTestEnforcer() *Enforcer {
+	return NewEnforcer(time.Second*5, map[string]time.Duration{
+		createUser: time.Second * 10,
+		importData: time.Minute,
+	})
+}
+
+// unaryRemaining returns the time left until the deadline seen by a unary
+// handler for method.
+func unaryRemaining(
+	t *testing.T,
+	e *Enforcer,
+	ctx context.Context,
+	method string,
+) time.Duration {
+	var remaining time.Duration
+	_, err := e.UnaryServerInterceptor()(
+		ctx,
+		nil,
+		&grpc.UnaryServerInfo{FullMethod: method},
+		func(ctx context.Context, _ any) (any, error) {
+			d, ok := ctx.Deadline()
+			assert.True(t, ok)
+			remaining = time.Until(d)
+			return nil, nil
+		},
+	)
+	assert.NoError(t, err)
+	return remaining
+}
+
+func TestUnaryInterceptor_NoClientDeadline_ShouldApplyDefault(t *testing.T) {
+	remaining := unaryRemaining(t, newTestEnforcer(), context.Background(), getUser)
+	assert.InDelta(t, time.Second*5, remaining, float64(time.Second))
+}
+
+func TestUnaryInterceptor_MethodDefault_ShouldApplyWithoutClientDeadline(t *testing.T) {
+	e := newTestEnforcer().WithDefaultTimeouts(map[string]time.Duration{
+		batchUsers: time.Minute,
+	})
+
+	remaining := unaryRemaining(t, e, context.Background(), batchUsers)
+	assert.InDelta(t, time.Minute, remaining, float64(time.Second))
+}
+
+func TestUnaryInterceptor_MethodDefault_ShouldCapClientDeadline(t *testing.T) {
+	e := newTestEnforcer().WithDefaultTimeouts(map[string]time.Duration{
+		batchUsers: time.Minute,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	remaining := unaryRemaining(t, e, ctx, batchUsers)
+	assert.InDelta(t, time.Minute, remaining, float64(time.Second))
+}
+
+func TestUnaryInterceptor_MethodMaximum_ShouldOverrideDefault(t *testing.T) {
+	e := NewEnforcer(time.Second*5, map[string]time.Duration{
+		createUser: time.Second * 2,
+	})
+
+	remaining := unaryRemaining(t, e, context.Background(), createUser)
+	assert.InDelta(t, time.Second*2, remaining, float64(time.Second))
+}
+
+func TestUnaryInterceptor_LongClientDeadline_ShouldBeCapped(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	remaining := unaryRemaining(t, newTestEnforcer(), ctx, createUser)
+	assert.InDelta(t, time.Second*10, remaining, float64(time.Second))
+}
+
+func TestUnaryInterceptor_ShortClientDeadline_ShouldBeKept(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	remaining := unaryRemaining(t, newTestEnforcer(), ctx, createUser)
+	assert.LessOrEqual(t, remaining, time.Second)
+}
+
+func TestStreamInterceptor_ConfiguredMethod_ShouldBoundStream(t *testing.T) {
+	e := newTestEnforcer()
+
+	var remaining time.Duration
+	err := e.StreamServerInterceptor()(
+		nil,
+		&testStream{ctx: context.Background()},
+		&grpc.StreamServerInfo{FullMethod: importData},
+		func(_ any, stream grpc.ServerStream) error {
+			d, ok := stream.Context().Deadline()
+			assert.True(t, ok)
+			remaining = time.Until(d)
+			return nil
+		},
+	)
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Minute, remaining, float64(time.Second))
+}
+
+func TestStreamInterceptor_UnconfiguredMethod_ShouldNotBoundStream(t *testing.T) {
+	e := newTestEnforcer()
+
+	err := e.StreamServerInterceptor()(
+		nil,
+		&testStream{ctx: context.Background()},
+		&grpc.StreamServerInfo{FullMethod: watchUsers},
+		func(_ any, stream grpc.ServerStream) error {
+			_, ok := stream.Context().Deadline()
+			assert.False(t, ok)
+			return nil
+		},
+	)
+	assert.NoError(t, err)
+}
+
+func TestUnaryInterceptor_DeadlineElapsed_ShouldReturnDeadlineExceeded(t *testing.T) {
+	e := NewEnforcer(time.Millisecond*10, nil)
+
+	_, err := e.UnaryServerInterceptor()(
+		context.Background(),
+		nil,
+		&grpc.UnaryServerInfo{FullMethod: getUser},
+		func(ctx context.Context, _ any) (any, error) {
+			<-ctx.Done()
+			// Handlers often surface the context error unwrapped.
+			return nil, errors.New("query cancelled: " + ctx.Err().Error())
+		},
+	)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestStreamInterceptor_DeadlineElapsed_ShouldReturnDeadlineExceeded(t *testing.T) {
+	e := NewEnforcer(time.Second, map[string]time.Duration{
+		importData: time.Millisecond * 10,
+	})
+
+	err := e.StreamServerInterceptor()(
+		nil,
+		&testStream{ctx: context.Background()},
+		&grpc.StreamServerInfo{FullMethod: importData},
+		func(_ any, stream grpc.ServerStream) error {
+			<-stream.Context().Done()
+			return stream.Context().Err()
+		},
+	)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestUnaryInterceptor_OtherError_ShouldBePassedThrough(t *testing.T) {
+	_, err := newTestEnforcer().UnaryServerInterceptor()(
+		context.Background(),
+		nil,
+		&grpc.UnaryServerInfo{FullMethod: getUser},
+		func(context.Context, any) (any, error) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		},
+	)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
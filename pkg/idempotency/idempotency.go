@@ -0,0 +1,237 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/clintrovert/go-playground/pkg/cache"
+	"github.com/clintrovert/go-playground/pkg/identity"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	// MetadataKey is the request header carrying the client's idempotency key.
+	MetadataKey = "idempotency-key"
+	keyPrefix   = "idempotency:"
+)
+
+var (
+	ErrKeyReused          = errors.New("idempotency key was reused with a different request")
+	ErrRequestNotMessage  = errors.New("request is not a protobuf message")
+	ErrResponseNotMessage = errors.New("response is not a protobuf message")
+	ErrRecordInvalid      = errors.New("stored idempotency record was invalid")
+)
+
+var replayed = metadata.Pairs("x-idempotent-replay", "true")
+
+// record is the value stored in the cache for a completed request, encoded
+// as JSON. Response is the response packed in an Any, so that it can be
+// decoded without knowing its type.
+type record struct {
+	Fingerprint string `json:"f"`
+	Response    []byte `json:"r"`
+}
+
+// call tracks a request which is still being handled so that concurrent
+// duplicates can wait for its outcome.
+type call struct {
+	done        chan struct{}
+	fingerprint string
+	response    any
+	err         error
+}
+
+// Interceptor replays the stored response of a previously completed request
+// when a client retries a configured method with the same idempotency key.
+type Interceptor struct {
+	cache    cache.KeyValCache
+	caller   identity.CallerFunc
+	ttl      time.Duration
+	methods  map[string]struct{}
+	mu       sync.Mutex
+	inflight map[string]*call
+}
+
+// NewInterceptor creates a new instance of an Interceptor which honours
+// idempotency keys for the supplied full method names. Keys are scoped per
+// caller so that they cannot collide across clients.
+func NewInterceptor(
+	kvc cache.KeyValCache,
+	caller identity.CallerFunc,
+	ttl time.Duration,
+	methods ...string,
+) *Interceptor {
+	if caller == nil {
+		caller = identity.FromAuthorization
+	}
+
+	configured := make(map[string]struct{}, len(methods))
+	for _, m := range methods {
+		configured[m] = struct{}{}
+	}
+
+	return &Interceptor{
+		cache:    kvc,
+		caller:   caller,
+		ttl:      ttl,
+		methods:  configured,
+		inflight: map[string]*call{},
+	}
+}
+
+func (i *Interceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		request any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if _, ok := i.methods[info.FullMethod]; !ok {
+			return handler(ctx, request)
+		}
+
+		values := metadata.ValueFromIncomingContext(ctx, MetadataKey)
+		if len(values) == 0 || values[0] == "" {
+			return handler(ctx, request)
+		}
+
+		caller, err := i.caller(ctx)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+
+		fingerprint, err := fingerprintOf(request)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		key := keyPrefix + info.FullMethod + ":" + caller + ":" + values[0]
+		return i.handle(ctx, key, fingerprint, request, handler)
+	}
+}
+
+func (i *Interceptor) handle(
+	ctx context.Context,
+	key, fingerprint string,
+	request any,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	// The mutex guards only inflight, since the cache may be remote.
+	i.mu.Lock()
+	if c, ok := i.inflight[key]; ok {
+		i.mu.Unlock()
+		if c.fingerprint != fingerprint {
+			return nil, status.Error(codes.FailedPrecondition, ErrKeyReused.Error())
+		}
+
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		if c.err != nil {
+			return nil, c.err
+		}
+		_ = grpc.SetHeader(ctx, replayed)
+		return c.response, nil
+	}
+
+	c := &call{done: make(chan struct{}), fingerprint: fingerprint}
+	i.inflight[key] = c
+	i.mu.Unlock()
+
+	defer func() {
+		i.mu.Lock()
+		delete(i.inflight, key)
+		i.mu.Unlock()
+		close(c.done)
+	}()
+
+	if val, found := i.cache.Get(ctx, key); found {
+		c.response, c.err = replay(ctx, val, fingerprint)
+		return c.response, c.err
+	}
+
+	c.response, c.err = handler(ctx, request)
+
+	// Failed requests are not stored so that the client is free to retry
+	// them with the same key.
+	if c.err == nil {
+		if encoded, err := encodeRecord(fingerprint, c.response); err == nil {
+			_ = i.cache.Set(ctx, key, encoded, i.ttl)
+		}
+	}
+
+	return c.response, c.err
+}
+
+// encodeRecord serializes a completed request, so that it can be stored by
+// caches which do not hold values in process.
+func encodeRecord(fingerprint string, response any) ([]byte, error) {
+	msg, ok := response.(proto.Message)
+	if !ok {
+		return nil, ErrResponseNotMessage
+	}
+	packed, err := anypb.New(msg)
+	if err != nil {
+		return nil, err
+	}
+	b, err := proto.Marshal(packed)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(record{Fingerprint: fingerprint, Response: b})
+}
+
+func replay(ctx context.Context, val any, fingerprint string) (any, error) {
+	var encoded []byte
+	switch v := val.(type) {
+	case []byte:
+		encoded = v
+	case string:
+		encoded = []byte(v)
+	default:
+		return nil, status.Error(codes.Internal, ErrRecordInvalid.Error())
+	}
+
+	var rec record
+	if err := json.Unmarshal(encoded, &rec); err != nil {
+		return nil, status.Error(codes.Internal, ErrRecordInvalid.Error())
+	}
+	if rec.Fingerprint != fingerprint {
+		return nil, status.Error(codes.FailedPrecondition, ErrKeyReused.Error())
+	}
+
+	var packed anypb.Any
+	if err := proto.Unmarshal(rec.Response, &packed); err != nil {
+		return nil, status.Error(codes.Internal, ErrRecordInvalid.Error())
+	}
+	response, err := packed.UnmarshalNew()
+	if err != nil {
+		return nil, status.Error(codes.Internal, ErrRecordInvalid.Error())
+	}
+
+	_ = grpc.SetHeader(ctx, replayed)
+	return response, nil
+}
+
+func fingerprintOf(request any) (string, error) {
+	msg, ok := request.(proto.Message)
+	if !ok {
+		return "", ErrRequestNotMessage
+	}
+
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	return identity.Digest(b), nil
+}
@@ -0,0 +1,175 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/clintrovert/go-playground/api/model"
+	"github.com/clintrovert/go-playground/pkg/cache"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const createUser = "/playground.UserService/CreateUser"
+
+type testInterceptor struct {
+	interceptor grpc.UnaryServerInterceptor
+	info        *grpc.UnaryServerInfo
+	calls       int32
+}
+
+func newTestInterceptor() *testInterceptor {
+	i := NewInterceptor(cache.NewMemoryCache(), nil, time.Minute, createUser)
+	return &testInterceptor{
+		interceptor: i.UnaryServerInterceptor(),
+		info:        &grpc.UnaryServerInfo{FullMethod: createUser},
+	}
+}
+
+func (ti *testInterceptor) handler(ctx context.Context, req any) (any, error) {
+	atomic.AddInt32(&ti.calls, 1)
+	return &model.CreateUserResponse{Success: true}, nil
+}
+
+func newContext(key string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"authorization", "Bearer test",
+		MetadataKey, key,
+	))
+}
+
+func TestInterceptor_RepeatedKey_ShouldReplayResponse(t *testing.T) {
+	tester := newTestInterceptor()
+	request := &model.CreateUserRequest{Name: "name", Email: "a@b.com"}
+
+	first, err := tester.interceptor(
+		newContext("key"), request, tester.info, tester.handler,
+	)
+	assert.NoError(t, err)
+
+	second, err := tester.interceptor(
+		newContext("key"), request, tester.info, tester.handler,
+	)
+	assert.NoError(t, err)
+	assert.True(t, proto.Equal(first.(proto.Message), second.(proto.Message)))
+	assert.Equal(t, int32(1), tester.calls)
+}
+
+func TestInterceptor_ReusedKeyDifferentRequest_ShouldFail(t *testing.T) {
+	tester := newTestInterceptor()
+
+	_, err := tester.interceptor(
+		newContext("key"),
+		&model.CreateUserRequest{Name: "first"},
+		tester.info,
+		tester.handler,
+	)
+	assert.NoError(t, err)
+
+	_, err = tester.interceptor(
+		newContext("key"),
+		&model.CreateUserRequest{Name: "second"},
+		tester.info,
+		tester.handler,
+	)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Equal(t, int32(1), tester.calls)
+}
+
+func TestInterceptor_ConcurrentDuplicates_ShouldHandleOnce(t *testing.T) {
+	tester := newTestInterceptor()
+	request := &model.CreateUserRequest{Name: "name"}
+	release := make(chan struct{})
+	handler := func(ctx context.Context, req any) (any, error) {
+		<-release
+		return tester.handler(ctx, req)
+	}
+
+	var wg sync.WaitGroup
+	for n := 0; n < 5; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := tester.interceptor(
+				newContext("key"), request, tester.info, handler,
+			)
+			assert.NoError(t, err)
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), tester.calls)
+}
+
+func TestInterceptor_NoKey_ShouldAlwaysHandle(t *testing.T) {
+	tester := newTestInterceptor()
+	request := &model.CreateUserRequest{Name: "name"}
+
+	for n := 0; n < 2; n++ {
+		_, err := tester.interceptor(
+			context.Background(), request, tester.info, tester.handler,
+		)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), tester.calls)
+}
+
+// stringCache stores values as strings, as a cache serializing to an
+// external store would.
+type stringCache struct {
+	values map[string]string
+}
+
+func (c *stringCache) Get(_ context.Context, key string) (any, bool) {
+	val, ok := c.values[key]
+	return val, ok
+}
+
+func (c *stringCache) Set(_ context.Context, key string, val any, _ time.Duration) error {
+	b, ok := val.([]byte)
+	if !ok {
+		return errors.New("value was not serialized")
+	}
+	c.values[key] = string(b)
+	return nil
+}
+
+func TestInterceptor_SerializingCache_ShouldReplayResponse(t *testing.T) {
+	kvc := &stringCache{values: map[string]string{}}
+	i := NewInterceptor(kvc, nil, time.Minute, createUser)
+	info := &grpc.UnaryServerInfo{FullMethod: createUser}
+	request := &model.CreateUserRequest{Name: "name"}
+	handler := func(context.Context, any) (any, error) {
+		return &model.CreateUserResponse{Success: true, Id: 7}, nil
+	}
+
+	interceptor := i.UnaryServerInterceptor()
+
+	_, err := interceptor(newContext("key"), request, info, handler)
+	assert.NoError(t, err)
+	assert.Len(t, kvc.values, 1)
+
+	response, err := interceptor(newContext("key"), request, info,
+		func(context.Context, any) (any, error) {
+			t.Fatal("replayed request was handled")
+			return nil, nil
+		},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(7), response.(*model.CreateUserResponse).Id)
+
+	_, err = interceptor(
+		newContext("key"), &model.CreateUserRequest{Name: "other"}, info, handler,
+	)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
@@ -0,0 +1,47 @@
+package identity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const authorization = "authorization"
+
+var ErrCallerUnknown = errors.New("caller could not be identified")
+
+type adminKey struct{}
+
+// CallerFunc identifies the caller of a request.
+type CallerFunc func(ctx context.Context) (string, error)
+
+// FromAuthorization identifies the caller by a digest of the authorization
+// header, so raw credentials never end up in logs or cache keys.
+func FromAuthorization(ctx context.Context) (string, error) {
+	values := metadata.ValueFromIncomingContext(ctx, authorization)
+	if len(values) == 0 || values[0] == "" {
+		return "", ErrCallerUnknown
+	}
+	return Digest([]byte(values[0])), nil
+}
+
+// WithAdmin marks the caller of the request carried by ctx as an
+// administrator, once authentication has established it.
+func WithAdmin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, adminKey{}, true)
+}
+
+// IsAdmin reports whether the caller was authenticated as an administrator.
+func IsAdmin(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminKey{}).(bool)
+	return admin
+}
+
+// Digest returns the hex encoded SHA-256 digest of b.
+func Digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,187 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const nanosPerUnit = 1_000_000_000
+
+var (
+	ErrCurrencyInvalid  = errors.New("currency code is not a supported ISO 4217 code")
+	ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+	ErrPrecisionLoss    = errors.New("amount is more precise than the currency's minor unit")
+	ErrSignMismatch     = errors.New("units and nanos must have the same sign")
+	ErrOverflow         = errors.New("amount overflowed")
+)
+
+// Money is an amount of a currency held as an integer number of the
+// currency's minor units, e.g. cents, so that arithmetic is exact.
+type Money struct {
+	currency string
+	minor    int64
+}
+
+// New creates an amount of minor units of currency, which must be an ISO
+// 4217 code.
+func New(currency string, minor int64) (Money, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if _, ok := minorUnits[currency]; !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrCurrencyInvalid, currency)
+	}
+	return Money{currency: currency, minor: minor}, nil
+}
+
+// FromMinor creates an amount of minor units of currency without validating
+// the currency, for amounts which were validated before they were stored.
+// Currencies which are not supported are assumed to have two minor digits.
+func FromMinor(currency string, minor int64) Money {
+	return Money{currency: currency, minor: minor}
+}
+
+// FromUnits creates an amount from whole units and nano (10^-9) units of
+// currency, as used by google.type.Money. The nanos must be representable
+// in the currency's minor unit.
+func FromUnits(currency string, units int64, nanos int32) (Money, error) {
+	m, err := New(currency, 0)
+	if err != nil {
+		return Money{}, err
+	}
+	if nanos <= -nanosPerUnit || nanos >= nanosPerUnit {
+		return Money{}, ErrPrecisionLoss
+	}
+	if (units > 0 && nanos < 0) || (units < 0 && nanos > 0) {
+		return Money{}, ErrSignMismatch
+	}
+
+	nanosPerMinor := int32(nanosPerUnit / pow10(digits(m.currency)))
+	if nanos%nanosPerMinor != 0 {
+		return Money{}, ErrPrecisionLoss
+	}
+
+	whole, err := mul(units, pow10(digits(m.currency)))
+	if err != nil {
+		return Money{}, err
+	}
+	if m.minor, err = add(whole, int64(nanos/nanosPerMinor)); err != nil {
+		return Money{}, err
+	}
+	return m, nil
+}
+
+// Currency returns the ISO 4217 code of the amount's currency.
+func (m Money) Currency() string {
+	return m.currency
+}
+
+// Minor returns the amount in the currency's minor units.
+func (m Money) Minor() int64 {
+	return m.minor
+}
+
+// Units returns the amount as whole units and nano (10^-9) units, as used by
+// google.type.Money.
+func (m Money) Units() (int64, int32) {
+	scale := pow10(digits(m.currency))
+	nanosPerMinor := nanosPerUnit / scale
+	return m.minor / scale, int32((m.minor % scale) * nanosPerMinor)
+}
+
+// IsNegative reports whether the amount is below zero.
+func (m Money) IsNegative() bool {
+	return m.minor < 0
+}
+
+// Add returns the sum of two amounts of the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if m.currency != o.currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	sum, err := add(m.minor, o.minor)
+	return Money{currency: m.currency, minor: sum}, err
+}
+
+// Sub returns the difference of two amounts of the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	if m.currency != o.currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	if o.minor == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	diff, err := add(m.minor, -o.minor)
+	return Money{currency: m.currency, minor: diff}, err
+}
+
+// Mul returns the amount multiplied by a whole quantity.
+func (m Money) Mul(quantity int64) (Money, error) {
+	product, err := mul(m.minor, quantity)
+	return Money{currency: m.currency, minor: product}, err
+}
+
+// Cmp compares two amounts of the same currency, returning -1, 0 or 1.
+func (m Money) Cmp(o Money) (int, error) {
+	if m.currency != o.currency {
+		return 0, ErrCurrencyMismatch
+	}
+	switch {
+	case m.minor < o.minor:
+		return -1, nil
+	case m.minor > o.minor:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// String formats the amount with its currency code, e.g. "12.50 USD".
+func (m Money) String() string {
+	places := digits(m.currency)
+	if places == 0 {
+		return fmt.Sprintf("%d %s", m.minor, m.currency)
+	}
+
+	sign, minor := "", m.minor
+	if minor < 0 {
+		sign = "-"
+	}
+	scale := pow10(places)
+	whole, frac := minor/scale, minor%scale
+	if whole < 0 {
+		whole = -whole
+	}
+	if frac < 0 {
+		frac = -frac
+	}
+	return fmt.Sprintf("%s%d.%0*d %s", sign, whole, places, frac, m.currency)
+}
+
+func add(a, b int64) (int64, error) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, ErrOverflow
+	}
+	return sum, nil
+}
+
+func mul(a, b int64) (int64, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) ||
+		(b == -1 && a == math.MinInt64) {
+		return 0, ErrOverflow
+	}
+	return product, nil
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
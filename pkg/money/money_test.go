@@ -0,0 +1,90 @@
+package money
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_UnknownCurrency_ShouldError(t *testing.T) {
+	_, err := New("XYZ", 100)
+	assert.ErrorIs(t, err, ErrCurrencyInvalid)
+}
+
+func TestIsSupported_ShouldAcceptCurrenciesWithMinorUnits(t *testing.T) {
+	for _, currency := range []string{"CHF", "XCG", "ZWG", "UYI"} {
+		assert.True(t, IsSupported(currency), currency)
+	}
+	// Precious metals have no minor unit.
+	assert.False(t, IsSupported("XAU"))
+}
+
+func TestFromUnits_ShouldConvertToMinorUnits(t *testing.T) {
+	tests := []struct {
+		currency string
+		units    int64
+		nanos    int32
+		minor    int64
+	}{
+		{"USD", 12, 500_000_000, 1250},
+		{"usd", -1, -10_000_000, -101},
+		{"JPY", 500, 0, 500},
+		{"KWD", 1, 1_000_000, 1001},
+		{"CHF", 3, 50_000_000, 305},
+		{"CLF", 2, 500_000, 20005},
+	}
+
+	for _, test := range tests {
+		m, err := FromUnits(test.currency, test.units, test.nanos)
+		assert.NoError(t, err)
+		assert.Equal(t, test.minor, m.Minor())
+
+		units, nanos := m.Units()
+		assert.Equal(t, test.units, units)
+		assert.Equal(t, test.nanos, nanos)
+	}
+}
+
+func TestFromUnits_SubMinorNanos_ShouldError(t *testing.T) {
+	_, err := FromUnits("USD", 1, 1)
+	assert.ErrorIs(t, err, ErrPrecisionLoss)
+
+	_, err = FromUnits("JPY", 1, 500_000_000)
+	assert.ErrorIs(t, err, ErrPrecisionLoss)
+}
+
+func TestFromUnits_MixedSigns_ShouldError(t *testing.T) {
+	_, err := FromUnits("USD", 1, -500_000_000)
+	assert.ErrorIs(t, err, ErrSignMismatch)
+}
+
+func TestArithmetic_ShouldBeExact(t *testing.T) {
+	price, _ := New("USD", 10)
+	total, err := price.Mul(3)
+	assert.NoError(t, err)
+
+	total, err = total.Sub(price)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20), total.Minor())
+	assert.Equal(t, "0.20 USD", total.String())
+}
+
+func TestArithmetic_CurrencyMismatch_ShouldError(t *testing.T) {
+	usd, _ := New("USD", 1)
+	eur, _ := New("EUR", 1)
+
+	_, err := usd.Add(eur)
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+}
+
+func TestArithmetic_Overflow_ShouldError(t *testing.T) {
+	max, _ := New("USD", math.MaxInt64)
+	one, _ := New("USD", 1)
+
+	_, err := max.Add(one)
+	assert.ErrorIs(t, err, ErrOverflow)
+
+	_, err = max.Mul(2)
+	assert.ErrorIs(t, err, ErrOverflow)
+}
@@ -0,0 +1,337 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/clintrovert/go-playground/pkg/postgres"
+	"github.com/clintrovert/go-playground/pkg/postgres/database"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPollInterval = time.Second * 5
+	defaultLease        = time.Second * 30
+	defaultMinBackoff   = time.Second
+	defaultMaxBackoff   = time.Minute * 5
+	defaultRetention    = time.Hour * 24
+	defaultBatchSize    = 100
+	pruneInterval       = time.Hour
+	pruneBatchSize      = 500
+)
+
+// Event is a domain event describing a change to an aggregate, such as a
+// User or a Product.
+type Event struct {
+	// ID identifies the event. Events may be published more than once, so
+	// consumers should use it to discard duplicates.
+	ID            int64  `json:"id"`
+	AggregateType string `json:"aggregate_type"`
+	AggregateID   int32  `json:"aggregate_id"`
+	// Type names the change, such as user.created.
+	Type string `json:"type"`
+	// Payload is the aggregate after the change, or before it when deleted.
+	Payload    json.RawMessage `json:"payload"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+// Publisher delivers events to their consumers.
+type Publisher interface {
+	// Publish delivers an event, returning an error when it may not have
+	// been delivered so that it is retried.
+	Publish(ctx context.Context, event Event) error
+}
+
+// Database provides the outbox operations used by a Relay.
+type Database interface {
+	// LockOutboxClaims serializes claims until the end of the transaction.
+	LockOutboxClaims(ctx context.Context) error
+	// ClaimOutboxEvents leases a batch of the oldest events due to be
+	// published, stopping before the first event which is not yet due.
+	ClaimOutboxEvents(
+		ctx context.Context,
+		params database.ClaimOutboxEventsParams,
+	) ([]database.Outbox, error)
+	// MarkOutboxEventsPublished records that events were published.
+	MarkOutboxEventsPublished(ctx context.Context, ids []int64) error
+	// RetryOutboxEvents records a failed attempt to publish events and when
+	// they are next due.
+	RetryOutboxEvents(
+		ctx context.Context,
+		params database.RetryOutboxEventsParams,
+	) error
+	// PruneOutboxEvents deletes up to a batch of events published before a
+	// given time, returning the number of rows deleted.
+	PruneOutboxEvents(
+		ctx context.Context,
+		params database.PruneOutboxEventsParams,
+	) (int64, error)
+}
+
+// Transactor runs units of work within database transactions.
+type Transactor interface {
+	// RunInTx runs fn within a transaction, rolling back if it fails.
+	RunInTx(
+		ctx context.Context,
+		opts *postgres.TxOptions,
+		fn postgres.TxFunc,
+	) error
+}
+
+// Notifier wakes a Relay when events are written to the outbox.
+type Notifier interface {
+	// Subscribe returns a channel which receives a value after events are
+	// written, and a function which ends the subscription.
+	Subscribe() (<-chan struct{}, func())
+}
+
+// Relay publishes the events written to the outbox. Events are delivered at
+// least once, ordered by the transaction which wrote them and then by ID.
+// Relays claim events one at a time, and an event claimed by one is not
+// claimed by another until its lease expires, so several may run at once. An
+// event which fails to publish is retried with exponential backoff, and no
+// event following it is published until it has been.
+type Relay struct {
+	db        Database
+	tx        Transactor
+	publisher Publisher
+	log       *logrus.Logger
+	events    Notifier
+
+	pollInterval time.Duration
+	lease        time.Duration
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	retention    time.Duration
+	batchSize    int32
+	// queries adapts the queries of a transaction to a Database, and is
+	// replaced by tests.
+	queries func(q *database.Queries) Database
+}
+
+// NewRelay creates a new instance of a Relay publishing the events of db
+// through publisher, claiming them within transactions started by tx.
+func NewRelay(
+	db Database,
+	tx Transactor,
+	publisher Publisher,
+	log *logrus.Logger,
+) *Relay {
+	return &Relay{
+		db:           db,
+		tx:           tx,
+		publisher:    publisher,
+		log:          log,
+		pollInterval: defaultPollInterval,
+		lease:        defaultLease,
+		minBackoff:   defaultMinBackoff,
+		maxBackoff:   defaultMaxBackoff,
+		retention:    defaultRetention,
+		batchSize:    defaultBatchSize,
+		queries: func(q *database.Queries) Database {
+			return q
+		},
+	}
+}
+
+// WithNotifier wakes the Relay through events as soon as events are written,
+// rather than when it next polls.
+func (r *Relay) WithNotifier(events Notifier) *Relay {
+	r.events = events
+	return r
+}
+
+// WithPollInterval sets how often the Relay checks for events to publish,
+// which defaults to five seconds.
+func (r *Relay) WithPollInterval(d time.Duration) *Relay {
+	r.pollInterval = d
+	return r
+}
+
+// WithBackoff sets the delay before the first retry of an event, which
+// doubles with each failed attempt up to max.
+func (r *Relay) WithBackoff(min time.Duration, max time.Duration) *Relay {
+	r.minBackoff, r.maxBackoff = min, max
+	return r
+}
+
+// WithRetention sets how long published events are kept before they are
+// deleted, which defaults to a day.
+func (r *Relay) WithRetention(d time.Duration) *Relay {
+	r.retention = d
+	return r
+}
+
+// Run publishes events until ctx is cancelled, for use as a background
+// worker.
+func (r *Relay) Run(ctx context.Context) error {
+	var wake <-chan struct{}
+	if r.events != nil {
+		var unsubscribe func()
+		wake, unsubscribe = r.events.Subscribe()
+		defer unsubscribe()
+	}
+
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	var prunedAt time.Time
+	for {
+		for {
+			published, err := r.relay(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					r.log.WithError(err).Error("failed to relay outbox events")
+				}
+				break
+			}
+			if published < int(r.batchSize) {
+				break
+			}
+		}
+
+		if time.Since(prunedAt) >= pruneInterval {
+			if pruned, err := r.prune(ctx); err != nil {
+				r.log.WithError(err).Error("failed to prune outbox events")
+			} else if pruned > 0 {
+				r.log.WithField("pruned", pruned).Info("pruned outbox events")
+			}
+			prunedAt = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// relay publishes a batch of due events, returning the number of events
+// claimed. The events following one which fails are retried with it, so
+// that they are not published out of order.
+func (r *Relay) relay(ctx context.Context) (int, error) {
+	events, err := r.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Txid != events[j].Txid {
+			return events[i].Txid < events[j].Txid
+		}
+		return events[i].OutboxID < events[j].OutboxID
+	})
+
+	published := make([]int64, 0, len(events))
+	var failed error
+	var retry []int64
+	for i, event := range events {
+		if err = r.publisher.Publish(ctx, toEvent(event)); err != nil {
+			r.log.WithError(err).
+				WithField("outbox_id", event.OutboxID).
+				WithField("attempts", event.Attempts+1).
+				Warn("failed to publish outbox event")
+
+			failed = err
+			for _, deferred := range events[i:] {
+				retry = append(retry, deferred.OutboxID)
+			}
+			break
+		}
+		published = append(published, event.OutboxID)
+	}
+
+	// Should marking fail the events are published again once their lease
+	// expires, which at least once delivery allows.
+	if len(published) > 0 {
+		if err = r.db.MarkOutboxEventsPublished(ctx, published); err != nil {
+			return len(events), err
+		}
+	}
+	if failed != nil {
+		attempts := events[len(published)].Attempts
+		if err = r.db.RetryOutboxEvents(ctx, database.RetryOutboxEventsParams{
+			LastError:     failed.Error(),
+			NextAttemptAt: time.Now().Add(r.backoff(attempts)),
+			OutboxIds:     retry,
+		}); err != nil {
+			return len(events), err
+		}
+		// Publishing is suspended until the failed event is due again, as
+		// events following it are not claimed before then.
+		return 0, nil
+	}
+
+	return len(events), nil
+}
+
+// claim leases a batch of due events. Claims are serialized, so that the
+// leases of one are visible to the next and events claimed by one Relay are
+// not followed by their successors being claimed by another.
+func (r *Relay) claim(ctx context.Context) ([]database.Outbox, error) {
+	var events []database.Outbox
+	err := r.tx.RunInTx(
+		ctx,
+		&postgres.TxOptions{Isolation: sql.LevelReadCommitted},
+		func(ctx context.Context, q *database.Queries) error {
+			db := r.queries(q)
+			if err := db.LockOutboxClaims(ctx); err != nil {
+				return err
+			}
+
+			var err error
+			events, err = db.ClaimOutboxEvents(ctx, database.ClaimOutboxEventsParams{
+				LeaseUntil: time.Now().Add(r.lease),
+				BatchSize:  r.batchSize,
+			})
+			return err
+		},
+	)
+	return events, err
+}
+
+// backoff returns the delay before retrying an event which has previously
+// failed to publish attempts times.
+func (r *Relay) backoff(attempts int32) time.Duration {
+	delay := r.minBackoff
+	for i := int32(0); i < attempts && delay < r.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.maxBackoff {
+		delay = r.maxBackoff
+	}
+	return delay
+}
+
+// prune deletes expired published events in batches, so that a large prune
+// does not hold locks on the outbox table for long.
+func (r *Relay) prune(ctx context.Context) (int64, error) {
+	params := database.PruneOutboxEventsParams{
+		PublishedBefore: time.Now().Add(-r.retention),
+		BatchSize:       pruneBatchSize,
+	}
+
+	var total int64
+	for {
+		pruned, err := r.db.PruneOutboxEvents(ctx, params)
+		total += pruned
+		if err != nil || pruned < int64(params.BatchSize) {
+			return total, err
+		}
+	}
+}
+
+func toEvent(event database.Outbox) Event {
+	return Event{
+		ID:            event.OutboxID,
+		AggregateType: event.AggregateType,
+		AggregateID:   event.AggregateID,
+		Type:          event.EventType,
+		Payload:       event.Payload,
+		OccurredAt:    event.CreatedAt.UTC(),
+	}
+}
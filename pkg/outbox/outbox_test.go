@@ -0,0 +1,298 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/clintrovert/go-playground/pkg/postgres"
+	"github.com/clintrovert/go-playground/pkg/postgres/database"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// testDatabase holds the outbox in memory, claiming events as
+// ClaimOutboxEvents does and recording what the relay marks published and
+// retries. Every event is treated as written by a transaction which ended.
+type testDatabase struct {
+	// claims is held from LockOutboxClaims until the transaction ends.
+	claims sync.Mutex
+
+	mu        sync.Mutex
+	events    []database.Outbox
+	published []int64
+	retried   *database.RetryOutboxEventsParams
+}
+
+func (db *testDatabase) LockOutboxClaims(context.Context) error {
+	db.claims.Lock()
+	return nil
+}
+
+// ClaimOutboxEvents leases the oldest unpublished events up to the first
+// which is not yet due, returning them in the order they are held. It fails
+// unless claims were locked.
+func (db *testDatabase) ClaimOutboxEvents(
+	_ context.Context,
+	params database.ClaimOutboxEventsParams,
+) ([]database.Outbox, error) {
+	if db.claims.TryLock() {
+		db.claims.Unlock()
+		return nil, errors.New("claims were not locked")
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	pending := make([]*database.Outbox, 0, len(db.events))
+	for i := range db.events {
+		if !db.events[i].PublishedAt.Valid {
+			pending = append(pending, &db.events[i])
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].Txid != pending[j].Txid {
+			return pending[i].Txid < pending[j].Txid
+		}
+		return pending[i].OutboxID < pending[j].OutboxID
+	})
+
+	now := time.Now()
+	leased := map[int64]bool{}
+	for _, event := range pending {
+		if event.NextAttemptAt.After(now) || len(leased) == int(params.BatchSize) {
+			break
+		}
+		event.NextAttemptAt = params.LeaseUntil
+		leased[event.OutboxID] = true
+	}
+
+	var claimed []database.Outbox
+	for _, event := range db.events {
+		if leased[event.OutboxID] {
+			claimed = append(claimed, event)
+		}
+	}
+	return claimed, nil
+}
+
+func (db *testDatabase) MarkOutboxEventsPublished(_ context.Context, ids []int64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.published = append(db.published, ids...)
+	for _, event := range db.find(ids) {
+		event.PublishedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+	return nil
+}
+
+func (db *testDatabase) RetryOutboxEvents(
+	_ context.Context,
+	params database.RetryOutboxEventsParams,
+) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.retried = &params
+	for _, event := range db.find(params.OutboxIds) {
+		event.Attempts++
+		event.LastError = sql.NullString{String: params.LastError, Valid: true}
+		event.NextAttemptAt = params.NextAttemptAt
+	}
+	return nil
+}
+
+func (db *testDatabase) find(ids []int64) []*database.Outbox {
+	var found []*database.Outbox
+	for i := range db.events {
+		for _, id := range ids {
+			if db.events[i].OutboxID == id {
+				found = append(found, &db.events[i])
+			}
+		}
+	}
+	return found
+}
+
+func (db *testDatabase) PruneOutboxEvents(
+	context.Context,
+	database.PruneOutboxEventsParams,
+) (int64, error) {
+	return 0, nil
+}
+
+// testTransactor runs units of work against a testDatabase, ending the
+// transaction by releasing the claims lock every claim takes.
+type testTransactor struct {
+	db *testDatabase
+}
+
+func (tx testTransactor) RunInTx(
+	ctx context.Context,
+	_ *postgres.TxOptions,
+	fn postgres.TxFunc,
+) error {
+	defer tx.db.claims.Unlock()
+	return fn(ctx, nil)
+}
+
+// testPublisher records the events it publishes, failing those named in
+// fail.
+type testPublisher struct {
+	mu     sync.Mutex
+	events []Event
+	fail   map[int64]bool
+}
+
+func (p *testPublisher) Publish(_ context.Context, event Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.fail[event.ID] {
+		return errors.New("broker unavailable")
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+func newTestRelay(db *testDatabase, publisher Publisher) *Relay {
+	relay := NewRelay(db, testTransactor{db: db}, publisher, logrus.New())
+	relay.queries = func(*database.Queries) Database {
+		return db
+	}
+	return relay
+}
+
+func newTestEvents(ids ...int64) []database.Outbox {
+	events := make([]database.Outbox, len(ids))
+	for i, id := range ids {
+		events[i] = database.Outbox{
+			OutboxID:      id,
+			AggregateType: "user",
+			AggregateID:   7,
+			EventType:     "user.updated",
+			Payload:       json.RawMessage(`{"user_id":7}`),
+			CreatedAt:     time.Now(),
+		}
+	}
+	return events
+}
+
+func TestRelay_ClaimedEvents_ShouldPublishInOrder(t *testing.T) {
+	db := &testDatabase{events: newTestEvents(3, 1, 2)}
+	publisher := &testPublisher{}
+	relay := newTestRelay(db, publisher)
+
+	claimed, err := relay.relay(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, claimed)
+
+	assert.Len(t, publisher.events, 3)
+	for i, event := range publisher.events {
+		assert.Equal(t, int64(i+1), event.ID)
+		assert.Equal(t, "user.updated", event.Type)
+	}
+	assert.Equal(t, []int64{1, 2, 3}, db.published)
+	assert.Nil(t, db.retried)
+}
+
+func TestRelay_PublishFails_ShouldRetryFollowingEventsWithBackoff(t *testing.T) {
+	db := &testDatabase{events: newTestEvents(1, 2, 3)}
+	db.events[1].Attempts = 2
+	publisher := &testPublisher{fail: map[int64]bool{2: true}}
+	relay := newTestRelay(db, publisher).
+		WithBackoff(time.Second, time.Minute)
+
+	before := time.Now()
+	claimed, err := relay.relay(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, claimed)
+
+	assert.Equal(t, []int64{1}, db.published)
+	assert.Equal(t, []int64{2, 3}, db.retried.OutboxIds)
+	assert.Equal(t, "broker unavailable", db.retried.LastError)
+	assert.WithinDuration(t, before.Add(time.Second*4), db.retried.NextAttemptAt, time.Second)
+}
+
+func TestRelay_ClaimedEvents_ShouldPublishInTransactionOrder(t *testing.T) {
+	// The event with the lower ID was written by a transaction which began
+	// writing later.
+	db := &testDatabase{events: newTestEvents(1, 2)}
+	db.events[0].Txid, db.events[1].Txid = 20, 10
+	publisher := &testPublisher{}
+	relay := newTestRelay(db, publisher)
+
+	_, err := relay.relay(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 1}, db.published)
+}
+
+func TestRelay_PublishFails_ShouldHoldBackLaterBatches(t *testing.T) {
+	db := &testDatabase{events: newTestEvents(1, 2, 3, 4)}
+	publisher := &testPublisher{fail: map[int64]bool{2: true}}
+	relay := newTestRelay(db, publisher)
+	relay.batchSize = 2
+
+	claimed, err := relay.relay(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, claimed)
+	assert.Equal(t, []int64{2}, db.retried.OutboxIds)
+
+	// The events following the failed one are due, but are not claimed
+	// until it is due again.
+	claimed, err = relay.relay(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, claimed)
+	assert.Equal(t, []int64{1}, db.published)
+
+	db.events[1].NextAttemptAt = time.Now()
+	delete(publisher.fail, 2)
+	claimed, err = relay.relay(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, claimed)
+	claimed, err = relay.relay(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, claimed)
+	assert.Equal(t, []int64{1, 2, 3, 4}, db.published)
+}
+
+func TestRelay_ConcurrentRelays_ShouldPublishInOrder(t *testing.T) {
+	db := &testDatabase{events: newTestEvents(1, 2, 3, 4, 5, 6, 7, 8)}
+	publisher := &testPublisher{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		relay := newTestRelay(db, publisher)
+		relay.batchSize = 2
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for attempt := 0; attempt < 20; attempt++ {
+				_, err := relay.relay(context.Background())
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	ids := make([]int64, len(publisher.events))
+	for i, event := range publisher.events {
+		ids[i] = event.ID
+	}
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7, 8}, ids)
+}
+
+func TestRelay_Backoff_ShouldBeCapped(t *testing.T) {
+	relay := newTestRelay(&testDatabase{}, &testPublisher{}).
+		WithBackoff(time.Second, time.Minute)
+
+	assert.Equal(t, time.Second, relay.backoff(0))
+	assert.Equal(t, time.Second*8, relay.backoff(3))
+	assert.Equal(t, time.Minute, relay.backoff(40))
+}
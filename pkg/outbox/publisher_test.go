@@ -0,0 +1,37 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriterPublisher_Publish_ShouldWriteJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	publisher := NewWriterPublisher(&buf)
+
+	for _, id := range []int64{1, 2} {
+		err := publisher.Publish(context.Background(), Event{
+			ID:            id,
+			AggregateType: "product",
+			AggregateID:   4,
+			Type:          "product.created",
+			Payload:       json.RawMessage(`{"product_id":4}`),
+			OccurredAt:    time.Now(),
+		})
+		assert.NoError(t, err)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 2)
+
+	var event Event
+	assert.NoError(t, json.Unmarshal(lines[1], &event))
+	assert.Equal(t, int64(2), event.ID)
+	assert.Equal(t, "product.created", event.Type)
+	assert.JSONEq(t, `{"product_id":4}`, string(event.Payload))
+}
@@ -6,26 +6,160 @@ package database
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
 )
 
+type ReservationStatus string
+
+const (
+	ReservationStatusPending   ReservationStatus = "pending"
+	ReservationStatusCommitted ReservationStatus = "committed"
+	ReservationStatusReleased  ReservationStatus = "released"
+	ReservationStatusExpired   ReservationStatus = "expired"
+)
+
+func (e *ReservationStatus) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = ReservationStatus(s)
+	case string:
+		*e = ReservationStatus(s)
+	default:
+		return fmt.Errorf("unsupported scan type for ReservationStatus: %T", src)
+	}
+	return nil
+}
+
+type NullReservationStatus struct {
+	ReservationStatus ReservationStatus
+	Valid             bool // Valid is true if ReservationStatus is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullReservationStatus) Scan(value interface{}) error {
+	if value == nil {
+		ns.ReservationStatus, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.ReservationStatus.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullReservationStatus) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.ReservationStatus), nil
+}
+
+type UserEventKind string
+
+const (
+	UserEventKindCreated  UserEventKind = "created"
+	UserEventKindUpdated  UserEventKind = "updated"
+	UserEventKindDeleted  UserEventKind = "deleted"
+	UserEventKindRestored UserEventKind = "restored"
+)
+
+func (e *UserEventKind) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = UserEventKind(s)
+	case string:
+		*e = UserEventKind(s)
+	default:
+		return fmt.Errorf("unsupported scan type for UserEventKind: %T", src)
+	}
+	return nil
+}
+
+type NullUserEventKind struct {
+	UserEventKind UserEventKind
+	Valid         bool // Valid is true if UserEventKind is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullUserEventKind) Scan(value interface{}) error {
+	if value == nil {
+		ns.UserEventKind, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.UserEventKind.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullUserEventKind) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.UserEventKind), nil
+}
+
+type Outbox struct {
+	OutboxID      int64
+	AggregateType string
+	AggregateID   int32
+	EventType     string
+	Payload       json.RawMessage
+	CreatedAt     time.Time
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastError     sql.NullString
+	PublishedAt   sql.NullTime
+	Txid          int64
+}
+
 type Product struct {
 	ProductID  int32
-	Name       sql.NullString
-	Price      sql.NullInt32
-	CreatedAt  sql.NullTime
-	ModifiedAt sql.NullTime
+	Name       string
+	CreatedAt  time.Time
+	ModifiedAt time.Time
+	Version    int32
+	PriceMinor int64
+	Currency   string
+	Stock      int32
+}
+
+type StockReservation struct {
+	ReservationID int32
+	ProductID     int32
+	Quantity      int32
+	Status        ReservationStatus
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	ResolvedAt    sql.NullTime
 }
 
 type User struct {
 	UserID     int32
-	Name       sql.NullString
-	Email      sql.NullString
-	Password   sql.NullString
-	CreatedAt  sql.NullTime
-	ModifiedAt sql.NullTime
-	IsAdmin    sql.NullBool
+	Name       string
+	Email      string
+	Password   string
+	CreatedAt  time.Time
+	ModifiedAt time.Time
+	IsAdmin    bool
+	Version    int32
+	DeletedAt  sql.NullTime
+}
+
+type UserEvent struct {
+	EventID    int64
+	Txid       int64
+	UserID     int32
+	Kind       UserEventKind
+	Version    int32
+	OccurredAt time.Time
 }
 
 type UserProduct struct {
-	UserProductID sql.NullInt32
+	UserProductID int32
+	UserID        int32
+	ProductID     int32
+	Quantity      int32
+	AcquiredAt    time.Time
 }
@@ -8,76 +8,400 @@ package database
 import (
 	"context"
 	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const adjustProductStock = `-- name: AdjustProductStock :exec
+UPDATE products SET
+    stock = stock + $1::int
+WHERE product_id = $2
+`
+
+type AdjustProductStockParams struct {
+	Delta     int32
+	ProductID int32
+}
+
+// AdjustProductStock leaves the version and modified_at unchanged, so that
+// reservations do not conflict with conditional updates of the Product.
+func (q *Queries) AdjustProductStock(ctx context.Context, arg AdjustProductStockParams) error {
+	_, err := q.db.ExecContext(ctx, adjustProductStock, arg.Delta, arg.ProductID)
+	return err
+}
+
+const assignProduct = `-- name: AssignProduct :one
+INSERT INTO user_products (user_id, product_id, quantity, acquired_at)
+SELECT u.user_id, p.product_id, $1::int, now()
+FROM users u, products p
+WHERE u.user_id = $2 AND u.deleted_at IS NULL
+  AND p.product_id = $3
+ON CONFLICT (user_id, product_id)
+    DO UPDATE SET quantity = user_products.quantity + EXCLUDED.quantity,
+                  acquired_at = EXCLUDED.acquired_at
+RETURNING user_product_id, user_id, product_id, quantity, acquired_at
+`
+
+type AssignProductParams struct {
+	Quantity  int32
+	UserID    int32
+	ProductID int32
+}
+
+func (q *Queries) AssignProduct(ctx context.Context, arg AssignProductParams) (UserProduct, error) {
+	row := q.db.QueryRowContext(ctx, assignProduct, arg.Quantity, arg.UserID, arg.ProductID)
+	var i UserProduct
+	err := row.Scan(
+		&i.UserProductID,
+		&i.UserID,
+		&i.ProductID,
+		&i.Quantity,
+		&i.AcquiredAt,
+	)
+	return i, err
+}
+
+const claimOutboxEvents = `-- name: ClaimOutboxEvents :many
+UPDATE outbox
+SET next_attempt_at = $1::timestamptz
+WHERE outbox_id IN (
+    SELECT pending.outbox_id FROM outbox pending
+    WHERE pending.published_at IS NULL
+      AND pending.next_attempt_at <= CURRENT_TIMESTAMP
+      AND pending.txid < txid_snapshot_xmin(txid_current_snapshot())
+      AND NOT EXISTS (
+          SELECT 1 FROM outbox held
+          WHERE held.published_at IS NULL
+            AND held.next_attempt_at > CURRENT_TIMESTAMP
+            AND (held.txid, held.outbox_id) < (pending.txid, pending.outbox_id)
+      )
+    ORDER BY pending.txid, pending.outbox_id
+    LIMIT $2
+    FOR UPDATE
 )
+RETURNING outbox_id, aggregate_type, aggregate_id, event_type, payload, created_at, attempts, next_attempt_at, last_error, published_at, txid
+`
 
-const createProduct = `-- name: CreateProduct :exec
+type ClaimOutboxEventsParams struct {
+	LeaseUntil time.Time
+	BatchSize  int32
+}
+
+// ClaimOutboxEvents leases a batch of the oldest events due to be published
+// until lease_until, so that concurrent relays do not publish them too. It
+// must follow LockOutboxClaims, as a concurrent claim's leases would not be
+// visible and the successors of the events it claims could be claimed.
+// Events are ordered by (txid, outbox_id). Events of transactions which may
+// still be in progress are excluded, and no event is claimed after one which
+// is not yet due, either waiting to be retried or leased by another relay,
+// so that no event is published before those preceding it.
+func (q *Queries) ClaimOutboxEvents(ctx context.Context, arg ClaimOutboxEventsParams) ([]Outbox, error) {
+	rows, err := q.db.QueryContext(ctx, claimOutboxEvents, arg.LeaseUntil, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Outbox
+	for rows.Next() {
+		var i Outbox
+		if err := rows.Scan(
+			&i.OutboxID,
+			&i.AggregateType,
+			&i.AggregateID,
+			&i.EventType,
+			&i.Payload,
+			&i.CreatedAt,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastError,
+			&i.PublishedAt,
+			&i.Txid,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createProduct = `-- name: CreateProduct :one
 INSERT INTO products (
-    product_id, name, price, created_at, modified_at
+    name, price_minor, currency, stock, created_at, modified_at
 ) VALUES (
-     $1, $2, $3, now()::timestamp, now()::timestamp
- )
+    $1, $2, $3, $4, now(), now()
+)
+RETURNING product_id, name, created_at, modified_at, version, price_minor, currency, stock
 `
 
 type CreateProductParams struct {
+	Name       string
+	PriceMinor int64
+	Currency   string
+	Stock      int32
+}
+
+func (q *Queries) CreateProduct(ctx context.Context, arg CreateProductParams) (Product, error) {
+	row := q.db.QueryRowContext(ctx, createProduct,
+		arg.Name,
+		arg.PriceMinor,
+		arg.Currency,
+		arg.Stock,
+	)
+	var i Product
+	err := row.Scan(
+		&i.ProductID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.ModifiedAt,
+		&i.Version,
+		&i.PriceMinor,
+		&i.Currency,
+		&i.Stock,
+	)
+	return i, err
+}
+
+const createReservation = `-- name: CreateReservation :one
+INSERT INTO stock_reservations (
+    product_id, quantity, expires_at
+) VALUES (
+    $1, $2, $3
+)
+RETURNING reservation_id, product_id, quantity, status, created_at, expires_at, resolved_at
+`
+
+type CreateReservationParams struct {
 	ProductID int32
-	Name      sql.NullString
-	Price     sql.NullInt32
+	Quantity  int32
+	ExpiresAt time.Time
 }
 
-func (q *Queries) CreateProduct(ctx context.Context, arg CreateProductParams) error {
-	_, err := q.db.ExecContext(ctx, createProduct, arg.ProductID, arg.Name, arg.Price)
-	return err
+func (q *Queries) CreateReservation(ctx context.Context, arg CreateReservationParams) (StockReservation, error) {
+	row := q.db.QueryRowContext(ctx, createReservation, arg.ProductID, arg.Quantity, arg.ExpiresAt)
+	var i StockReservation
+	err := row.Scan(
+		&i.ReservationID,
+		&i.ProductID,
+		&i.Quantity,
+		&i.Status,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.ResolvedAt,
+	)
+	return i, err
 }
 
-const createUser = `-- name: CreateUser :exec
+const createUser = `-- name: CreateUser :one
 INSERT INTO users (
-    user_id, name, email, password, is_admin, created_at, modified_at
+    name, email, password, is_admin, created_at, modified_at
 ) VALUES (
-    $1, $2, $3, $4, $5, now()::timestamp, now()::timestamp
+    $1, $2, $3, $4, now(), now()
 )
+RETURNING user_id, name, email, password, created_at, modified_at, is_admin, version, deleted_at
 `
 
 type CreateUserParams struct {
-	UserID   int32
-	Name     sql.NullString
-	Email    sql.NullString
-	Password sql.NullString
-	IsAdmin  sql.NullBool
+	Name     string
+	Email    string
+	Password string
+	IsAdmin  bool
 }
 
-func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) error {
-	_, err := q.db.ExecContext(ctx, createUser,
-		arg.UserID,
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, createUser,
 		arg.Name,
 		arg.Email,
 		arg.Password,
 		arg.IsAdmin,
 	)
-	return err
+	var i User
+	err := row.Scan(
+		&i.UserID,
+		&i.Name,
+		&i.Email,
+		&i.Password,
+		&i.CreatedAt,
+		&i.ModifiedAt,
+		&i.IsAdmin,
+		&i.Version,
+		&i.DeletedAt,
+	)
+	return i, err
 }
 
-const deleteProduct = `-- name: DeleteProduct :exec
+const createUsers = `-- name: CreateUsers :many
+INSERT INTO users (
+    name, email, password, is_admin, created_at, modified_at
+)
+SELECT
+    unnest($1::text[]),
+    unnest($2::text[]),
+    unnest($3::text[]),
+    unnest($4::bool[]),
+    now(),
+    now()
+ON CONFLICT (LOWER(email)) WHERE deleted_at IS NULL DO NOTHING
+RETURNING user_id, name, email, password, created_at, modified_at, is_admin, version, deleted_at
+`
+
+type CreateUsersParams struct {
+	Names     []string
+	Emails    []string
+	Passwords []string
+	IsAdmins  []bool
+}
+
+// CreateUsers inserts a batch of Users in one statement, skipping those
+// whose email is already taken by a User which is not deleted.
+func (q *Queries) CreateUsers(ctx context.Context, arg CreateUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, createUsers,
+		pq.Array(arg.Names),
+		pq.Array(arg.Emails),
+		pq.Array(arg.Passwords),
+		pq.Array(arg.IsAdmins),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Name,
+			&i.Email,
+			&i.Password,
+			&i.CreatedAt,
+			&i.ModifiedAt,
+			&i.IsAdmin,
+			&i.Version,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteProduct = `-- name: DeleteProduct :execrows
 DELETE FROM products
 WHERE product_id = $1
+  AND ($2::int IS NULL OR version = $2::int)
 `
 
-func (q *Queries) DeleteProduct(ctx context.Context, productID int32) error {
-	_, err := q.db.ExecContext(ctx, deleteProduct, productID)
-	return err
+type DeleteProductParams struct {
+	ProductID int32
+	Version   sql.NullInt32
+}
+
+func (q *Queries) DeleteProduct(ctx context.Context, arg DeleteProductParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteProduct, arg.ProductID, arg.Version)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-const deleteUser = `-- name: DeleteUser :exec
+const deleteUser = `-- name: DeleteUser :execrows
 DELETE FROM users
 WHERE user_id = $1
+  AND ($2::int IS NULL OR version = $2::int)
 `
 
-func (q *Queries) DeleteUser(ctx context.Context, userID int32) error {
-	_, err := q.db.ExecContext(ctx, deleteUser, userID)
-	return err
+type DeleteUserParams struct {
+	UserID  int32
+	Version sql.NullInt32
+}
+
+func (q *Queries) DeleteUser(ctx context.Context, arg DeleteUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUser, arg.UserID, arg.Version)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteUserProduct = `-- name: DeleteUserProduct :execrows
+DELETE FROM user_products
+WHERE user_id = $1 AND product_id = $2
+`
+
+type DeleteUserProductParams struct {
+	UserID    int32
+	ProductID int32
+}
+
+func (q *Queries) DeleteUserProduct(ctx context.Context, arg DeleteUserProductParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUserProduct, arg.UserID, arg.ProductID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const exportUsers = `-- name: ExportUsers :many
+SELECT user_id, name, email, password, created_at, modified_at, is_admin, version, deleted_at FROM users
+WHERE deleted_at IS NULL
+  AND ($1::int IS NULL OR user_id > $1::int)
+ORDER BY user_id
+LIMIT $2
+`
+
+type ExportUsersParams struct {
+	AfterID   sql.NullInt32
+	PageLimit int32
+}
+
+func (q *Queries) ExportUsers(ctx context.Context, arg ExportUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, exportUsers, arg.AfterID, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Name,
+			&i.Email,
+			&i.Password,
+			&i.CreatedAt,
+			&i.ModifiedAt,
+			&i.IsAdmin,
+			&i.Version,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
 const getProduct = `-- name: GetProduct :one
-SELECT product_id, name, price, created_at, modified_at FROM products
+SELECT product_id, name, created_at, modified_at, version, price_minor, currency, stock FROM products
 WHERE product_id = $1 LIMIT 1
 `
 
@@ -87,16 +411,62 @@ func (q *Queries) GetProduct(ctx context.Context, productID int32) (Product, err
 	err := row.Scan(
 		&i.ProductID,
 		&i.Name,
-		&i.Price,
 		&i.CreatedAt,
 		&i.ModifiedAt,
+		&i.Version,
+		&i.PriceMinor,
+		&i.Currency,
+		&i.Stock,
+	)
+	return i, err
+}
+
+const getProductForUpdate = `-- name: GetProductForUpdate :one
+SELECT product_id, name, created_at, modified_at, version, price_minor, currency, stock FROM products
+WHERE product_id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetProductForUpdate(ctx context.Context, productID int32) (Product, error) {
+	row := q.db.QueryRowContext(ctx, getProductForUpdate, productID)
+	var i Product
+	err := row.Scan(
+		&i.ProductID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.ModifiedAt,
+		&i.Version,
+		&i.PriceMinor,
+		&i.Currency,
+		&i.Stock,
+	)
+	return i, err
+}
+
+const getReservationForUpdate = `-- name: GetReservationForUpdate :one
+SELECT reservation_id, product_id, quantity, status, created_at, expires_at, resolved_at FROM stock_reservations
+WHERE reservation_id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetReservationForUpdate(ctx context.Context, reservationID int32) (StockReservation, error) {
+	row := q.db.QueryRowContext(ctx, getReservationForUpdate, reservationID)
+	var i StockReservation
+	err := row.Scan(
+		&i.ReservationID,
+		&i.ProductID,
+		&i.Quantity,
+		&i.Status,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.ResolvedAt,
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT user_id, name, email, password, created_at, modified_at, is_admin FROM users
-WHERE user_id = $1 LIMIT 1
+SELECT user_id, name, email, password, created_at, modified_at, is_admin, version, deleted_at FROM users
+WHERE user_id = $1 AND deleted_at IS NULL LIMIT 1
 `
 
 func (q *Queries) GetUser(ctx context.Context, userID int32) (User, error) {
@@ -110,31 +480,1053 @@ func (q *Queries) GetUser(ctx context.Context, userID int32) (User, error) {
 		&i.CreatedAt,
 		&i.ModifiedAt,
 		&i.IsAdmin,
+		&i.Version,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
-const updateProduct = `-- name: UpdateProduct :exec
-UPDATE products SET
-     name = $1, price = $2, modified_at = now()::timestamp
-WHERE product_id = $3
+const getUserEventsHead = `-- name: GetUserEventsHead :one
+SELECT txid_snapshot_xmin(txid_current_snapshot())::bigint AS txid
 `
 
-type UpdateProductParams struct {
-	Name      sql.NullString
-	Price     sql.NullInt32
+// GetUserEventsHead returns the oldest transaction which may still record
+// events, before which every event is visible.
+func (q *Queries) GetUserEventsHead(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getUserEventsHead)
+	var txid int64
+	err := row.Scan(&txid)
+	return txid, err
+}
+
+const getUserProductForUpdate = `-- name: GetUserProductForUpdate :one
+SELECT user_product_id, user_id, product_id, quantity, acquired_at FROM user_products
+WHERE user_id = $1 AND product_id = $2
+FOR UPDATE
+`
+
+type GetUserProductForUpdateParams struct {
+	UserID    int32
+	ProductID int32
+}
+
+func (q *Queries) GetUserProductForUpdate(ctx context.Context, arg GetUserProductForUpdateParams) (UserProduct, error) {
+	row := q.db.QueryRowContext(ctx, getUserProductForUpdate, arg.UserID, arg.ProductID)
+	var i UserProduct
+	err := row.Scan(
+		&i.UserProductID,
+		&i.UserID,
+		&i.ProductID,
+		&i.Quantity,
+		&i.AcquiredAt,
+	)
+	return i, err
+}
+
+const listExpiredReservations = `-- name: ListExpiredReservations :many
+SELECT reservation_id, product_id, quantity, status, created_at, expires_at, resolved_at FROM stock_reservations
+WHERE status = 'pending' AND expires_at <= $1::timestamptz
+ORDER BY product_id, reservation_id
+LIMIT $2
+FOR UPDATE SKIP LOCKED
+`
+
+type ListExpiredReservationsParams struct {
+	ExpiredBefore time.Time
+	BatchSize     int32
+}
+
+func (q *Queries) ListExpiredReservations(ctx context.Context, arg ListExpiredReservationsParams) ([]StockReservation, error) {
+	rows, err := q.db.QueryContext(ctx, listExpiredReservations, arg.ExpiredBefore, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []StockReservation
+	for rows.Next() {
+		var i StockReservation
+		if err := rows.Scan(
+			&i.ReservationID,
+			&i.ProductID,
+			&i.Quantity,
+			&i.Status,
+			&i.CreatedAt,
+			&i.ExpiresAt,
+			&i.ResolvedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listProductOwners = `-- name: ListProductOwners :many
+SELECT users.user_id, users.name, users.email, users.password, users.created_at, users.modified_at, users.is_admin, users.version, users.deleted_at, user_products.quantity, user_products.acquired_at
+FROM user_products
+JOIN users ON users.user_id = user_products.user_id
+WHERE user_products.product_id = $1
+  AND users.deleted_at IS NULL
+  AND ($2::int IS NULL OR user_products.user_id > $2::int)
+ORDER BY user_products.user_id
+LIMIT $3
+`
+
+type ListProductOwnersParams struct {
 	ProductID int32
+	AfterID   sql.NullInt32
+	PageLimit int32
 }
 
-func (q *Queries) UpdateProduct(ctx context.Context, arg UpdateProductParams) error {
-	_, err := q.db.ExecContext(ctx, updateProduct, arg.Name, arg.Price, arg.ProductID)
+type ListProductOwnersRow struct {
+	User       User
+	Quantity   int32
+	AcquiredAt time.Time
+}
+
+func (q *Queries) ListProductOwners(ctx context.Context, arg ListProductOwnersParams) ([]ListProductOwnersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listProductOwners, arg.ProductID, arg.AfterID, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListProductOwnersRow
+	for rows.Next() {
+		var i ListProductOwnersRow
+		if err := rows.Scan(
+			&i.User.UserID,
+			&i.User.Name,
+			&i.User.Email,
+			&i.User.Password,
+			&i.User.CreatedAt,
+			&i.User.ModifiedAt,
+			&i.User.IsAdmin,
+			&i.User.Version,
+			&i.User.DeletedAt,
+			&i.Quantity,
+			&i.AcquiredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listProducts = `-- name: ListProducts :many
+SELECT product_id, name, created_at, modified_at, version, price_minor, currency, stock FROM products
+WHERE ($1::text IS NULL
+        OR LOWER(name) LIKE LOWER($1::text) || '%')
+  AND ($2::int IS NULL OR product_id > $2::int)
+ORDER BY product_id
+LIMIT $3
+`
+
+type ListProductsParams struct {
+	NamePrefix sql.NullString
+	AfterID    sql.NullInt32
+	PageLimit  int32
+}
+
+func (q *Queries) ListProducts(ctx context.Context, arg ListProductsParams) ([]Product, error) {
+	rows, err := q.db.QueryContext(ctx, listProducts, arg.NamePrefix, arg.AfterID, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Product
+	for rows.Next() {
+		var i Product
+		if err := rows.Scan(
+			&i.ProductID,
+			&i.Name,
+			&i.CreatedAt,
+			&i.ModifiedAt,
+			&i.Version,
+			&i.PriceMinor,
+			&i.Currency,
+			&i.Stock,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTakenEmails = `-- name: ListTakenEmails :many
+SELECT LOWER(email)::text AS email FROM users
+WHERE LOWER(email) = ANY($1::text[])
+  AND deleted_at IS NULL
+`
+
+// ListTakenEmails returns the lowercased emails of a batch which are taken
+// by Users which are not deleted.
+func (q *Queries) ListTakenEmails(ctx context.Context, emails []string) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listTakenEmails, pq.Array(emails))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, err
+		}
+		items = append(items, email)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserEvents = `-- name: ListUserEvents :many
+SELECT event_id, txid, user_id, kind, version, occurred_at FROM user_events
+WHERE txid < txid_snapshot_xmin(txid_current_snapshot())
+  AND (txid, event_id) > ($1::bigint, $2::bigint)
+  AND ($3::int[] IS NULL OR user_id = ANY($3::int[]))
+  AND ($4::user_event_kind[] IS NULL OR kind = ANY($4::user_event_kind[]))
+ORDER BY txid, event_id
+LIMIT $5
+`
+
+type ListUserEventsParams struct {
+	AfterTxid int64
+	AfterID   int64
+	UserIds   []int32
+	Kinds     []UserEventKind
+	PageLimit int32
+}
+
+// ListUserEvents lists the events after a position, excluding those of
+// transactions which may still be in progress so that no event is skipped.
+func (q *Queries) ListUserEvents(ctx context.Context, arg ListUserEventsParams) ([]UserEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listUserEvents,
+		arg.AfterTxid,
+		arg.AfterID,
+		pq.Array(arg.UserIds),
+		pq.Array(arg.Kinds),
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserEvent
+	for rows.Next() {
+		var i UserEvent
+		if err := rows.Scan(
+			&i.EventID,
+			&i.Txid,
+			&i.UserID,
+			&i.Kind,
+			&i.Version,
+			&i.OccurredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserProducts = `-- name: ListUserProducts :many
+SELECT products.product_id, products.name, products.created_at, products.modified_at, products.version, products.price_minor, products.currency, products.stock, user_products.quantity, user_products.acquired_at
+FROM user_products
+JOIN products ON products.product_id = user_products.product_id
+JOIN users ON users.user_id = user_products.user_id
+WHERE user_products.user_id = $1 AND users.deleted_at IS NULL
+  AND ($2::timestamptz IS NULL
+        OR (user_products.acquired_at, user_products.product_id)
+            < ($2::timestamptz, $3::int))
+ORDER BY user_products.acquired_at DESC, user_products.product_id DESC
+LIMIT $4
+`
+
+type ListUserProductsParams struct {
+	UserID          int32
+	AfterAcquiredAt sql.NullTime
+	AfterID         sql.NullInt32
+	PageLimit       int32
+}
+
+type ListUserProductsRow struct {
+	Product    Product
+	Quantity   int32
+	AcquiredAt time.Time
+}
+
+func (q *Queries) ListUserProducts(ctx context.Context, arg ListUserProductsParams) ([]ListUserProductsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUserProducts,
+		arg.UserID,
+		arg.AfterAcquiredAt,
+		arg.AfterID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserProductsRow
+	for rows.Next() {
+		var i ListUserProductsRow
+		if err := rows.Scan(
+			&i.Product.ProductID,
+			&i.Product.Name,
+			&i.Product.CreatedAt,
+			&i.Product.ModifiedAt,
+			&i.Product.Version,
+			&i.Product.PriceMinor,
+			&i.Product.Currency,
+			&i.Product.Stock,
+			&i.Quantity,
+			&i.AcquiredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersByCreatedAsc = `-- name: ListUsersByCreatedAsc :many
+SELECT user_id, name, email, password, created_at, modified_at, is_admin, version, deleted_at FROM users
+WHERE deleted_at IS NULL
+  AND ($1::text IS NULL
+        OR LOWER(name) LIKE LOWER($1::text) || '%')
+  AND ($2::text IS NULL
+        OR LOWER(email) LIKE LOWER($2::text) || '%')
+  AND ($3::boolean IS NULL
+        OR is_admin = $3::boolean)
+  AND ($4::timestamptz IS NULL
+        OR created_at >= $4::timestamptz)
+  AND ($5::timestamptz IS NULL
+        OR created_at < $5::timestamptz)
+  AND ($6::timestamptz IS NULL
+        OR (created_at, user_id) > ($6::timestamptz, $7::int))
+ORDER BY created_at ASC, user_id ASC
+LIMIT $8
+`
+
+type ListUsersByCreatedAscParams struct {
+	NamePrefix     sql.NullString
+	EmailPrefix    sql.NullString
+	IsAdmin        sql.NullBool
+	CreatedAfter   sql.NullTime
+	CreatedBefore  sql.NullTime
+	AfterCreatedAt sql.NullTime
+	AfterID        sql.NullInt32
+	PageLimit      int32
+}
+
+func (q *Queries) ListUsersByCreatedAsc(ctx context.Context, arg ListUsersByCreatedAscParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsersByCreatedAsc,
+		arg.NamePrefix,
+		arg.EmailPrefix,
+		arg.IsAdmin,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Name,
+			&i.Email,
+			&i.Password,
+			&i.CreatedAt,
+			&i.ModifiedAt,
+			&i.IsAdmin,
+			&i.Version,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersByCreatedDesc = `-- name: ListUsersByCreatedDesc :many
+SELECT user_id, name, email, password, created_at, modified_at, is_admin, version, deleted_at FROM users
+WHERE deleted_at IS NULL
+  AND ($1::text IS NULL
+        OR LOWER(name) LIKE LOWER($1::text) || '%')
+  AND ($2::text IS NULL
+        OR LOWER(email) LIKE LOWER($2::text) || '%')
+  AND ($3::boolean IS NULL
+        OR is_admin = $3::boolean)
+  AND ($4::timestamptz IS NULL
+        OR created_at >= $4::timestamptz)
+  AND ($5::timestamptz IS NULL
+        OR created_at < $5::timestamptz)
+  AND ($6::timestamptz IS NULL
+        OR (created_at, user_id) < ($6::timestamptz, $7::int))
+ORDER BY created_at DESC, user_id DESC
+LIMIT $8
+`
+
+type ListUsersByCreatedDescParams struct {
+	NamePrefix     sql.NullString
+	EmailPrefix    sql.NullString
+	IsAdmin        sql.NullBool
+	CreatedAfter   sql.NullTime
+	CreatedBefore  sql.NullTime
+	AfterCreatedAt sql.NullTime
+	AfterID        sql.NullInt32
+	PageLimit      int32
+}
+
+func (q *Queries) ListUsersByCreatedDesc(ctx context.Context, arg ListUsersByCreatedDescParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsersByCreatedDesc,
+		arg.NamePrefix,
+		arg.EmailPrefix,
+		arg.IsAdmin,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Name,
+			&i.Email,
+			&i.Password,
+			&i.CreatedAt,
+			&i.ModifiedAt,
+			&i.IsAdmin,
+			&i.Version,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersByNameAsc = `-- name: ListUsersByNameAsc :many
+SELECT users.user_id, users.name, users.email, users.password, users.created_at, users.modified_at, users.is_admin, users.version, users.deleted_at, LOWER(name)::text AS sort_name FROM users
+WHERE deleted_at IS NULL
+  AND ($1::text IS NULL
+        OR LOWER(name) LIKE LOWER($1::text) || '%')
+  AND ($2::text IS NULL
+        OR LOWER(email) LIKE LOWER($2::text) || '%')
+  AND ($3::boolean IS NULL
+        OR is_admin = $3::boolean)
+  AND ($4::timestamptz IS NULL
+        OR created_at >= $4::timestamptz)
+  AND ($5::timestamptz IS NULL
+        OR created_at < $5::timestamptz)
+  AND ($6::text IS NULL
+        OR (LOWER(name), user_id) > ($6::text, $7::int))
+ORDER BY LOWER(name) ASC, user_id ASC
+LIMIT $8
+`
+
+type ListUsersByNameAscParams struct {
+	NamePrefix    sql.NullString
+	EmailPrefix   sql.NullString
+	IsAdmin       sql.NullBool
+	CreatedAfter  sql.NullTime
+	CreatedBefore sql.NullTime
+	AfterName     sql.NullString
+	AfterID       sql.NullInt32
+	PageLimit     int32
+}
+
+type ListUsersByNameAscRow struct {
+	User     User
+	SortName string
+}
+
+// ListUsersByNameAsc returns the lowercased name each User is ordered by,
+// from which the position of the next page is built.
+func (q *Queries) ListUsersByNameAsc(ctx context.Context, arg ListUsersByNameAscParams) ([]ListUsersByNameAscRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUsersByNameAsc,
+		arg.NamePrefix,
+		arg.EmailPrefix,
+		arg.IsAdmin,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.AfterName,
+		arg.AfterID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUsersByNameAscRow
+	for rows.Next() {
+		var i ListUsersByNameAscRow
+		if err := rows.Scan(
+			&i.User.UserID,
+			&i.User.Name,
+			&i.User.Email,
+			&i.User.Password,
+			&i.User.CreatedAt,
+			&i.User.ModifiedAt,
+			&i.User.IsAdmin,
+			&i.User.Version,
+			&i.User.DeletedAt,
+			&i.SortName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersByNameDesc = `-- name: ListUsersByNameDesc :many
+SELECT users.user_id, users.name, users.email, users.password, users.created_at, users.modified_at, users.is_admin, users.version, users.deleted_at, LOWER(name)::text AS sort_name FROM users
+WHERE deleted_at IS NULL
+  AND ($1::text IS NULL
+        OR LOWER(name) LIKE LOWER($1::text) || '%')
+  AND ($2::text IS NULL
+        OR LOWER(email) LIKE LOWER($2::text) || '%')
+  AND ($3::boolean IS NULL
+        OR is_admin = $3::boolean)
+  AND ($4::timestamptz IS NULL
+        OR created_at >= $4::timestamptz)
+  AND ($5::timestamptz IS NULL
+        OR created_at < $5::timestamptz)
+  AND ($6::text IS NULL
+        OR (LOWER(name), user_id) < ($6::text, $7::int))
+ORDER BY LOWER(name) DESC, user_id DESC
+LIMIT $8
+`
+
+type ListUsersByNameDescParams struct {
+	NamePrefix    sql.NullString
+	EmailPrefix   sql.NullString
+	IsAdmin       sql.NullBool
+	CreatedAfter  sql.NullTime
+	CreatedBefore sql.NullTime
+	AfterName     sql.NullString
+	AfterID       sql.NullInt32
+	PageLimit     int32
+}
+
+type ListUsersByNameDescRow struct {
+	User     User
+	SortName string
+}
+
+// ListUsersByNameDesc returns the lowercased name each User is ordered by,
+// from which the position of the next page is built.
+func (q *Queries) ListUsersByNameDesc(ctx context.Context, arg ListUsersByNameDescParams) ([]ListUsersByNameDescRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUsersByNameDesc,
+		arg.NamePrefix,
+		arg.EmailPrefix,
+		arg.IsAdmin,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.AfterName,
+		arg.AfterID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUsersByNameDescRow
+	for rows.Next() {
+		var i ListUsersByNameDescRow
+		if err := rows.Scan(
+			&i.User.UserID,
+			&i.User.Name,
+			&i.User.Email,
+			&i.User.Password,
+			&i.User.CreatedAt,
+			&i.User.ModifiedAt,
+			&i.User.IsAdmin,
+			&i.User.Version,
+			&i.User.DeletedAt,
+			&i.SortName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockOutboxClaims = `-- name: LockOutboxClaims :exec
+SELECT pg_advisory_xact_lock(7251923345)
+`
+
+// LockOutboxClaims serializes claims of outbox events until the end of the
+// transaction. Claims must be made by a later statement of the transaction,
+// whose snapshot includes the leases of the claims which preceded it.
+func (q *Queries) LockOutboxClaims(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, lockOutboxClaims)
+	return err
+}
+
+const markOutboxEventsPublished = `-- name: MarkOutboxEventsPublished :exec
+UPDATE outbox
+SET published_at = CURRENT_TIMESTAMP, last_error = NULL
+WHERE outbox_id = ANY($1::bigint[])
+`
+
+func (q *Queries) MarkOutboxEventsPublished(ctx context.Context, outboxIds []int64) error {
+	_, err := q.db.ExecContext(ctx, markOutboxEventsPublished, pq.Array(outboxIds))
+	return err
+}
+
+const pruneOutboxEvents = `-- name: PruneOutboxEvents :execrows
+DELETE FROM outbox
+WHERE outbox_id IN (
+    SELECT outbox_id FROM outbox
+    WHERE published_at < $1::timestamptz
+    ORDER BY outbox_id
+    LIMIT $2
+)
+`
+
+type PruneOutboxEventsParams struct {
+	PublishedBefore time.Time
+	BatchSize       int32
+}
+
+func (q *Queries) PruneOutboxEvents(ctx context.Context, arg PruneOutboxEventsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pruneOutboxEvents, arg.PublishedBefore, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const pruneUserEvents = `-- name: PruneUserEvents :execrows
+DELETE FROM user_events
+WHERE event_id IN (
+    SELECT event_id FROM user_events
+    WHERE occurred_at < $1::timestamptz
+    ORDER BY event_id
+    LIMIT $2
+)
+`
+
+type PruneUserEventsParams struct {
+	OccurredBefore time.Time
+	BatchSize      int32
+}
+
+func (q *Queries) PruneUserEvents(ctx context.Context, arg PruneUserEventsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pruneUserEvents, arg.OccurredBefore, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE user_id IN (
+    SELECT purged.user_id FROM users purged
+    WHERE purged.deleted_at < $1::timestamptz
+    ORDER BY purged.deleted_at
+    LIMIT $2
+)
+`
+
+type PurgeDeletedUsersParams struct {
+	DeletedBefore time.Time
+	BatchSize     int32
+}
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, arg PurgeDeletedUsersParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedUsers, arg.DeletedBefore, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const resolveReservation = `-- name: ResolveReservation :one
+UPDATE stock_reservations SET
+    status = $2,
+    resolved_at = now()
+WHERE reservation_id = $1
+RETURNING reservation_id, product_id, quantity, status, created_at, expires_at, resolved_at
+`
+
+type ResolveReservationParams struct {
+	ReservationID int32
+	Status        ReservationStatus
+}
+
+func (q *Queries) ResolveReservation(ctx context.Context, arg ResolveReservationParams) (StockReservation, error) {
+	row := q.db.QueryRowContext(ctx, resolveReservation, arg.ReservationID, arg.Status)
+	var i StockReservation
+	err := row.Scan(
+		&i.ReservationID,
+		&i.ProductID,
+		&i.Quantity,
+		&i.Status,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const restoreUser = `-- name: RestoreUser :one
+UPDATE users SET
+    deleted_at = NULL,
+    modified_at = now(),
+    version = version + 1
+WHERE user_id = $1 AND deleted_at IS NOT NULL
+RETURNING user_id, name, email, password, created_at, modified_at, is_admin, version, deleted_at
+`
+
+func (q *Queries) RestoreUser(ctx context.Context, userID int32) (User, error) {
+	row := q.db.QueryRowContext(ctx, restoreUser, userID)
+	var i User
+	err := row.Scan(
+		&i.UserID,
+		&i.Name,
+		&i.Email,
+		&i.Password,
+		&i.CreatedAt,
+		&i.ModifiedAt,
+		&i.IsAdmin,
+		&i.Version,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const retryOutboxEvents = `-- name: RetryOutboxEvents :exec
+UPDATE outbox
+SET attempts = attempts + 1,
+    last_error = $1::text,
+    next_attempt_at = $2::timestamptz
+WHERE outbox_id = ANY($3::bigint[])
+`
+
+type RetryOutboxEventsParams struct {
+	LastError     string
+	NextAttemptAt time.Time
+	OutboxIds     []int64
+}
+
+func (q *Queries) RetryOutboxEvents(ctx context.Context, arg RetryOutboxEventsParams) error {
+	_, err := q.db.ExecContext(ctx, retryOutboxEvents, arg.LastError, arg.NextAttemptAt, pq.Array(arg.OutboxIds))
+	return err
+}
+
+const searchProducts = `-- name: SearchProducts :many
+SELECT products.product_id, products.name, products.created_at, products.modified_at, products.version, products.price_minor, products.currency, products.stock,
+    ts_rank(to_tsvector('english', products.name), query)::real AS rank,
+    ts_headline('english', html_escape(products.name), query, 'HighlightAll=true')::text AS name_highlight
+FROM products, websearch_to_tsquery('english', $1) query
+WHERE to_tsvector('english', products.name) @@ query
+    AND ($2::real IS NULL
+        OR (ts_rank(to_tsvector('english', products.name), query), products.product_id) <
+           ($2::real, $3::int))
+ORDER BY rank DESC, products.product_id DESC
+LIMIT $4
+`
+
+type SearchProductsParams struct {
+	Query     string
+	AfterRank sql.NullFloat64
+	AfterID   sql.NullInt32
+	PageLimit int32
+}
+
+type SearchProductsRow struct {
+	Product       Product
+	Rank          float32
+	NameHighlight string
+}
+
+// SearchProducts matches the expression of products_search_idx, and
+// highlights the escaped name so that only highlights are markup.
+func (q *Queries) SearchProducts(ctx context.Context, arg SearchProductsParams) ([]SearchProductsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchProducts,
+		arg.Query,
+		arg.AfterRank,
+		arg.AfterID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchProductsRow
+	for rows.Next() {
+		var i SearchProductsRow
+		if err := rows.Scan(
+			&i.Product.ProductID,
+			&i.Product.Name,
+			&i.Product.CreatedAt,
+			&i.Product.ModifiedAt,
+			&i.Product.Version,
+			&i.Product.PriceMinor,
+			&i.Product.Currency,
+			&i.Product.Stock,
+			&i.Rank,
+			&i.NameHighlight,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT users.user_id, users.name, users.email, users.password, users.created_at, users.modified_at, users.is_admin, users.version, users.deleted_at,
+    ts_rank(user_search_vector(users.name, users.email), query)::real AS rank,
+    ts_headline('simple', html_escape(users.name), query, 'HighlightAll=true')::text AS name_highlight,
+    ts_headline('simple', html_escape(users.email), query, 'HighlightAll=true')::text AS email_highlight
+FROM users, websearch_to_tsquery('simple', $1) query
+WHERE user_search_vector(users.name, users.email) @@ query
+    AND users.deleted_at IS NULL
+    AND ($2::real IS NULL
+        OR (ts_rank(user_search_vector(users.name, users.email), query), users.user_id) <
+           ($2::real, $3::int))
+ORDER BY rank DESC, users.user_id DESC
+LIMIT $4
+`
+
+type SearchUsersParams struct {
+	Query     string
+	AfterRank sql.NullFloat64
+	AfterID   sql.NullInt32
+	PageLimit int32
+}
+
+type SearchUsersRow struct {
+	User           User
+	Rank           float32
+	NameHighlight  string
+	EmailHighlight string
+}
+
+// SearchUsers matches the expression of users_search_idx, and highlights
+// the escaped name and email so that only highlights are markup.
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]SearchUsersRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchUsers,
+		arg.Query,
+		arg.AfterRank,
+		arg.AfterID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchUsersRow
+	for rows.Next() {
+		var i SearchUsersRow
+		if err := rows.Scan(
+			&i.User.UserID,
+			&i.User.Name,
+			&i.User.Email,
+			&i.User.Password,
+			&i.User.CreatedAt,
+			&i.User.ModifiedAt,
+			&i.User.IsAdmin,
+			&i.User.Version,
+			&i.User.DeletedAt,
+			&i.Rank,
+			&i.NameHighlight,
+			&i.EmailHighlight,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setUserProductQuantity = `-- name: SetUserProductQuantity :exec
+UPDATE user_products SET quantity = $1
+WHERE user_id = $2 AND product_id = $3
+`
+
+type SetUserProductQuantityParams struct {
+	Quantity  int32
+	UserID    int32
+	ProductID int32
+}
+
+func (q *Queries) SetUserProductQuantity(ctx context.Context, arg SetUserProductQuantityParams) error {
+	_, err := q.db.ExecContext(ctx, setUserProductQuantity, arg.Quantity, arg.UserID, arg.ProductID)
 	return err
 }
 
-const updateUser = `-- name: UpdateUser :exec
+const softDeleteUser = `-- name: SoftDeleteUser :execrows
 UPDATE users SET
-    name = $1, email = $2, password = $3, is_admin = $4, modified_at = now()::timestamp
+    deleted_at = now(),
+    version = version + 1
+WHERE user_id = $1
+  AND deleted_at IS NULL
+  AND ($2::int IS NULL OR version = $2::int)
+`
+
+type SoftDeleteUserParams struct {
+	UserID  int32
+	Version sql.NullInt32
+}
+
+func (q *Queries) SoftDeleteUser(ctx context.Context, arg SoftDeleteUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, softDeleteUser, arg.UserID, arg.Version)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateProduct = `-- name: UpdateProduct :one
+UPDATE products SET
+    name = COALESCE($1, name),
+    price_minor = COALESCE($2, price_minor),
+    currency = COALESCE($3, currency),
+    stock = COALESCE($4, stock),
+    modified_at = now(),
+    version = version + 1
+WHERE product_id = $5
+  AND ($6::int IS NULL OR version = $6::int)
+RETURNING product_id, name, created_at, modified_at, version, price_minor, currency, stock
+`
+
+type UpdateProductParams struct {
+	Name       sql.NullString
+	PriceMinor sql.NullInt64
+	Currency   sql.NullString
+	Stock      sql.NullInt32
+	ProductID  int32
+	Version    sql.NullInt32
+}
+
+func (q *Queries) UpdateProduct(ctx context.Context, arg UpdateProductParams) (Product, error) {
+	row := q.db.QueryRowContext(ctx, updateProduct,
+		arg.Name,
+		arg.PriceMinor,
+		arg.Currency,
+		arg.Stock,
+		arg.ProductID,
+		arg.Version,
+	)
+	var i Product
+	err := row.Scan(
+		&i.ProductID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.ModifiedAt,
+		&i.Version,
+		&i.PriceMinor,
+		&i.Currency,
+		&i.Stock,
+	)
+	return i, err
+}
+
+const updateUser = `-- name: UpdateUser :one
+UPDATE users SET
+    name = COALESCE($1, name),
+    email = COALESCE($2, email),
+    password = COALESCE($3, password),
+    is_admin = COALESCE($4, is_admin),
+    modified_at = now(),
+    version = version + 1
 WHERE user_id = $5
+  AND deleted_at IS NULL
+  AND ($6::int IS NULL OR version = $6::int)
+RETURNING user_id, name, email, password, created_at, modified_at, is_admin, version, deleted_at
 `
 
 type UpdateUserParams struct {
@@ -143,15 +1535,40 @@ type UpdateUserParams struct {
 	Password sql.NullString
 	IsAdmin  sql.NullBool
 	UserID   int32
+	Version  sql.NullInt32
 }
 
-func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) error {
-	_, err := q.db.ExecContext(ctx, updateUser,
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUser,
 		arg.Name,
 		arg.Email,
 		arg.Password,
 		arg.IsAdmin,
 		arg.UserID,
+		arg.Version,
 	)
-	return err
+	var i User
+	err := row.Scan(
+		&i.UserID,
+		&i.Name,
+		&i.Email,
+		&i.Password,
+		&i.CreatedAt,
+		&i.ModifiedAt,
+		&i.IsAdmin,
+		&i.Version,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const userEventExists = `-- name: UserEventExists :one
+SELECT EXISTS(SELECT 1 FROM user_events WHERE event_id = $1)
+`
+
+func (q *Queries) UserEventExists(ctx context.Context, eventID int64) (bool, error) {
+	row := q.db.QueryRowContext(ctx, userEventExists, eventID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
 }
@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+var errTestConnect = errors.New("connection refused")
+
+// testDriver is a database/sql driver whose connections record the
+// statements and transaction boundaries they are sent and answer every
+// query with row. Connecting fails until connectFailures attempts have been
+// made.
+type testDriver struct {
+	mu              sync.Mutex
+	connectFailures int
+	connects        int
+	statements      []string
+	row             []driver.Value
+}
+
+func newTestDB(d *testDriver) *sql.DB {
+	return sql.OpenDB(d)
+}
+
+func (d *testDriver) Connect(context.Context) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.connects++
+	if d.connects <= d.connectFailures {
+		return nil, errTestConnect
+	}
+	return &testConn{driver: d}, nil
+}
+
+func (d *testDriver) record(statement string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.statements = append(d.statements, statement)
+}
+
+func (d *testDriver) recorded() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.statements...)
+}
+
+func (d *testDriver) Driver() driver.Driver {
+	return d
+}
+
+func (d *testDriver) Open(string) (driver.Conn, error) {
+	return d.Connect(context.Background())
+}
+
+type testConn struct {
+	driver *testDriver
+}
+
+func (c *testConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *testConn) Close() error {
+	return nil
+}
+
+func (c *testConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *testConn) BeginTx(
+	_ context.Context,
+	opts driver.TxOptions,
+) (driver.Tx, error) {
+	begin := "BEGIN " + sql.IsolationLevel(opts.Isolation).String()
+	if opts.ReadOnly {
+		begin += " READ ONLY"
+	}
+	c.driver.record(begin)
+	return &testTx{driver: c.driver}, nil
+}
+
+func (c *testConn) ExecContext(
+	_ context.Context,
+	query string,
+	_ []driver.NamedValue,
+) (driver.Result, error) {
+	c.driver.record(query)
+	return driver.RowsAffected(0), nil
+}
+
+func (c *testConn) Ping(context.Context) error {
+	return nil
+}
+
+type testTx struct {
+	driver *testDriver
+}
+
+func (tx *testTx) Commit() error {
+	tx.driver.record("COMMIT")
+	return nil
+}
+
+func (tx *testTx) Rollback() error {
+	tx.driver.record("ROLLBACK")
+	return nil
+}
+
+func (c *testConn) QueryContext(
+	_ context.Context,
+	query string,
+	_ []driver.NamedValue,
+) (driver.Rows, error) {
+	c.driver.record(query)
+	return &testRows{row: c.driver.row}, nil
+}
+
+// testRows returns a single row.
+type testRows struct {
+	row  []driver.Value
+	read bool
+}
+
+func (r *testRows) Columns() []string {
+	columns := make([]string, len(r.row))
+	for i := range columns {
+		columns[i] = fmt.Sprintf("column%d", i)
+	}
+	return columns
+}
+
+func (r *testRows) Close() error {
+	return nil
+}
+
+func (r *testRows) Next(dest []driver.Value) error {
+	if r.read {
+		return io.EOF
+	}
+	r.read = true
+	copy(dest, r.row)
+	return nil
+}
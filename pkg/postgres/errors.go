@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/lib/pq"
+)
+
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+	checkViolation      = "23514"
+	notNullViolation    = "23502"
+	connectionClass     = "08"
+)
+
+var (
+	ErrNotFound             = errors.New("record not found")
+	ErrUniqueViolation      = errors.New("unique constraint violated")
+	ErrForeignKeyViolation  = errors.New("foreign key constraint violated")
+	ErrCheckViolation       = errors.New("check constraint violated")
+	ErrSerializationFailure = errors.New("transaction could not be serialized")
+	ErrConnection           = errors.New("database connection failed")
+)
+
+// Error is a database error classified into one of the package's sentinel
+// errors, retaining the driver error and the constraint involved.
+type Error struct {
+	// Kind is the sentinel error describing the failure.
+	Kind error
+	// Constraint is the name of the violated constraint, if any.
+	Constraint string
+	// Err is the error returned by the driver.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Constraint != "" {
+		return e.Kind.Error() + " (" + e.Constraint + "): " + e.Err.Error()
+	}
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap exposes both the classification and the driver error, so that
+// errors.Is matches either.
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// Classify wraps err in an *Error when it can be mapped to one of the
+// package's sentinel errors, returning it unchanged otherwise. Context
+// cancellation and deadline errors are never reclassified.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var classified *Error
+	if errors.As(err, &classified) {
+		return err
+	}
+	if errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Error{Kind: ErrNotFound, Err: err}
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return classifyPq(pqErr, err)
+	}
+
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &netErr) {
+		return &Error{Kind: ErrConnection, Err: err}
+	}
+
+	return err
+}
+
+func classifyPq(pqErr *pq.Error, err error) error {
+	var kind error
+	switch string(pqErr.Code) {
+	case uniqueViolation:
+		kind = ErrUniqueViolation
+	case foreignKeyViolation:
+		kind = ErrForeignKeyViolation
+	case checkViolation, notNullViolation:
+		kind = ErrCheckViolation
+	case serializationFailure, deadlockDetected:
+		kind = ErrSerializationFailure
+	default:
+		if string(pqErr.Code.Class()) == connectionClass {
+			kind = ErrConnection
+		}
+	}
+
+	if kind == nil {
+		return err
+	}
+	return &Error{Kind: kind, Constraint: pqErr.Constraint, Err: err}
+}
@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind error
+	}{
+		{sql.ErrNoRows, ErrNotFound},
+		{fmt.Errorf("scan: %w", sql.ErrNoRows), ErrNotFound},
+		{&pq.Error{Code: "23505"}, ErrUniqueViolation},
+		{&pq.Error{Code: "23503"}, ErrForeignKeyViolation},
+		{&pq.Error{Code: "23514"}, ErrCheckViolation},
+		{&pq.Error{Code: "40001"}, ErrSerializationFailure},
+		{&pq.Error{Code: "40P01"}, ErrSerializationFailure},
+		{&pq.Error{Code: "08006"}, ErrConnection},
+		{driver.ErrBadConn, ErrConnection},
+	}
+
+	for _, test := range tests {
+		err := Classify(test.err)
+		assert.ErrorIs(t, err, test.kind, test.err.Error())
+		assert.ErrorIs(t, err, test.err)
+	}
+}
+
+func TestClassify_Unclassified_ShouldReturnUnchanged(t *testing.T) {
+	for _, err := range []error{
+		errors.New("test-error"),
+		&pq.Error{Code: "42601"},
+		context.DeadlineExceeded,
+	} {
+		assert.Equal(t, err, Classify(err))
+	}
+}
+
+func TestClassify_Constraint_ShouldBeRetained(t *testing.T) {
+	var classified *Error
+	err := Classify(&pq.Error{Code: "23505", Constraint: "users_email_key"})
+	assert.True(t, errors.As(err, &classified))
+	assert.Equal(t, "users_email_key", classified.Constraint)
+}
@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	minListenerReconnect = time.Second
+	maxListenerReconnect = time.Minute
+	listenerPingInterval = time.Minute
+)
+
+// Listener wakes subscribers whenever a Postgres channel is notified through
+// NOTIFY. Notifications carry no payload to subscribers, which are expected
+// to read whatever changed from the database. Subscribers are also woken
+// when the connection is re-established, since notifications may have been
+// missed while it was down.
+type Listener struct {
+	listener *pq.Listener
+	channel  string
+
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+}
+
+// NewListener creates a Listener for channel on the database at connStr. It
+// does not listen until Run is called.
+func NewListener(connStr string, channel string) *Listener {
+	return &Listener{
+		listener: pq.NewListener(
+			connStr, minListenerReconnect, maxListenerReconnect, nil,
+		),
+		channel:     channel,
+		subscribers: map[chan struct{}]struct{}{},
+	}
+}
+
+// Subscribe returns a channel which receives a value after the Listener's
+// channel is notified, and a function which ends the subscription.
+// Notifications arriving before the subscriber receives are coalesced.
+func (l *Listener) Subscribe() (<-chan struct{}, func()) {
+	wake := make(chan struct{}, 1)
+
+	l.mu.Lock()
+	l.subscribers[wake] = struct{}{}
+	l.mu.Unlock()
+
+	return wake, func() {
+		l.mu.Lock()
+		delete(l.subscribers, wake)
+		l.mu.Unlock()
+	}
+}
+
+// Run listens on the channel until ctx is cancelled, waking subscribers on
+// every notification, for use as a background worker.
+func (l *Listener) Run(ctx context.Context) error {
+	if err := l.listener.Listen(l.channel); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-l.listener.Notify:
+			if !ok {
+				return nil
+			}
+			l.broadcast()
+		case <-ticker.C:
+			// Pinging detects a dead connection which would otherwise go
+			// unnoticed while the channel is quiet.
+			_ = l.listener.Ping()
+		}
+	}
+}
+
+// Close stops listening and closes the Listener's connection.
+func (l *Listener) Close() error {
+	return l.listener.Close()
+}
+
+func (l *Listener) broadcast() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for wake := range l.subscribers {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
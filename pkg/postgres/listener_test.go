@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListener_RepeatedNotifications_ShouldCoalesce(t *testing.T) {
+	listener := &Listener{subscribers: map[chan struct{}]struct{}{}}
+	wake, unsubscribe := listener.Subscribe()
+	defer unsubscribe()
+
+	listener.broadcast()
+	listener.broadcast()
+
+	assert.Len(t, wake, 1)
+	<-wake
+	assert.Len(t, wake, 0)
+}
+
+func TestListener_Unsubscribed_ShouldNotWake(t *testing.T) {
+	listener := &Listener{subscribers: map[chan struct{}]struct{}{}}
+	wake, unsubscribe := listener.Subscribe()
+
+	unsubscribe()
+	listener.broadcast()
+
+	assert.Len(t, wake, 0)
+}
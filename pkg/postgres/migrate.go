@@ -0,0 +1,331 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	migrationsDir = "migrations"
+
+	// migrationLockId is the key of the advisory lock held while migrating,
+	// preventing concurrent deployments from applying the same migration.
+	migrationLockId = 7251923344
+
+	createMigrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+	selectAppliedMigrations = `SELECT version, applied_at FROM schema_migrations`
+	insertMigration         = `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`
+	deleteMigration         = `DELETE FROM schema_migrations WHERE version = $1`
+	acquireMigrationLock    = `SELECT pg_advisory_lock($1)`
+	releaseMigrationLock    = `SELECT pg_advisory_unlock($1)`
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationFile matches files named <version>_<name>.<up|down>.sql.
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var (
+	ErrMigrationInvalid  = errors.New("migration file name was invalid")
+	ErrMigrationMissing  = errors.New("migration is missing its up or down script")
+	ErrMigrationUnknown  = errors.New("applied migration is not known to this build")
+	ErrNothingToRollback = errors.New("no migrations have been applied")
+)
+
+// Migration is a numbered schema change with the scripts to apply and
+// revert it.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a Migration has been applied.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies the embedded migrations, recording applied versions in
+// the schema_migrations table.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator creates a new instance of a Migrator for the embedded
+// migrations.
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	if db == nil {
+		return nil, errors.New("db is required")
+	}
+
+	migrations, err := LoadMigrations(migrationFiles, migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+// LoadMigrations reads the migrations in dir of fsys, ordered by version.
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		m := migrationFile.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("%w: %s", ErrMigrationInvalid, entry.Name())
+		}
+
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrMigrationInvalid, entry.Name())
+		}
+
+		script, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != m[2] {
+			return nil, fmt.Errorf("%w: %s", ErrMigrationInvalid, entry.Name())
+		}
+
+		if m[3] == "up" {
+			migration.Up = string(script)
+		} else {
+			migration.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf(
+				"%w: %d_%s", ErrMigrationMissing, migration.Version, migration.Name,
+			)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies every pending migration in order, returning those applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+
+			if err = m.run(ctx, conn, migration.Up, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(
+					ctx, insertMigration, migration.Version, migration.Name,
+				)
+				return err
+			}); err != nil {
+				return fmt.Errorf(
+					"migration %d_%s failed: %w",
+					migration.Version, migration.Name, err,
+				)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	var reverted *Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			return ErrNothingToRollback
+		}
+
+		var latest int64
+		for version := range done {
+			if version > latest {
+				latest = version
+			}
+		}
+
+		migration, ok := m.find(latest)
+		if !ok {
+			return fmt.Errorf("%w: %d", ErrMigrationUnknown, latest)
+		}
+
+		if err = m.run(ctx, conn, migration.Down, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, deleteMigration, migration.Version)
+			return err
+		}); err != nil {
+			return fmt.Errorf(
+				"rollback of %d_%s failed: %w",
+				migration.Version, migration.Name, err,
+			)
+		}
+
+		reverted = &migration
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status reports every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			appliedAt, ok := done[migration.Version]
+			statuses = append(statuses, MigrationStatus{
+				Migration: migration,
+				Applied:   ok,
+				AppliedAt: appliedAt,
+			})
+		}
+		return nil
+	})
+
+	return statuses, err
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// withLock runs fn on a dedicated connection holding the migration advisory
+// lock, creating the schema_migrations table if required.
+func (m *Migrator) withLock(
+	ctx context.Context,
+	fn func(conn *sql.Conn) error,
+) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, acquireMigrationLock, migrationLockId); err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = conn.ExecContext(
+			context.Background(), releaseMigrationLock, migrationLockId,
+		)
+	}()
+
+	if _, err = conn.ExecContext(ctx, createMigrationsTable); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func (m *Migrator) applied(
+	ctx context.Context,
+	conn *sql.Conn,
+) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, selectAppliedMigrations)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// run executes script and records the outcome in a single transaction, so
+// a failing migration leaves neither a partial schema change nor a record.
+func (m *Migrator) run(
+	ctx context.Context,
+	conn *sql.Conn,
+	script string,
+	record func(tx *sql.Tx) error,
+) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, script); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err = record(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
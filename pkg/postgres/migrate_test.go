@@ -0,0 +1,40 @@
+package postgres
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadMigrations_Embedded_ShouldBeOrderedAndComplete(t *testing.T) {
+	migrations, err := LoadMigrations(migrationFiles, migrationsDir)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		assert.NotEmpty(t, m.Up)
+		assert.NotEmpty(t, m.Down)
+		if i > 0 {
+			assert.Greater(t, m.Version, migrations[i-1].Version)
+		}
+	}
+}
+
+func TestLoadMigrations_MissingDown_ShouldError(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0001_init.up.sql": {Data: []byte("CREATE TABLE a (id INT);")},
+	}
+
+	_, err := LoadMigrations(fsys, "m")
+	assert.ErrorIs(t, err, ErrMigrationMissing)
+}
+
+func TestLoadMigrations_InvalidName_ShouldError(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/init.sql": {Data: []byte("CREATE TABLE a (id INT);")},
+	}
+
+	_, err := LoadMigrations(fsys, "m")
+	assert.ErrorIs(t, err, ErrMigrationInvalid)
+}
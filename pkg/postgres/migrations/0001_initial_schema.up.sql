@@ -1,7 +1,5 @@
 -- PostgresSQL
-CREATE DATABASE playground;
-
-CREATE TABLE users
+CREATE TABLE IF NOT EXISTS users
 (
     user_id SERIAL,
     name VARCHAR(30),
@@ -13,7 +11,7 @@ CREATE TABLE users
     PRIMARY KEY(user_id)
 );
 
-CREATE TABLE products
+CREATE TABLE IF NOT EXISTS products
 (
     product_id    SERIAL,
     name  VARCHAR(30),
@@ -23,9 +21,12 @@ CREATE TABLE products
     PRIMARY KEY(product_id)
 );
 
-CREATE TABLE user_products
+CREATE TABLE IF NOT EXISTS user_products
 (
     user_product_id SERIAL,
+    user_id INT,
+    product_id INT,
+    PRIMARY KEY(user_product_id),
     CONSTRAINT fk_user_id
     FOREIGN KEY(user_id)
     REFERENCES users(user_id)
@@ -34,4 +35,4 @@ CREATE TABLE user_products
     FOREIGN KEY(product_id)
     REFERENCES products(product_id)
     ON DELETE CASCADE
-)
\ No newline at end of file
+);
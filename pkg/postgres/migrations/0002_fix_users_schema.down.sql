@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS users_email_lower_key;
+
+-- Narrowing fails rather than truncates if longer values have been stored.
+ALTER TABLE users
+    ALTER COLUMN name DROP NOT NULL,
+    ALTER COLUMN name TYPE VARCHAR(30),
+    ALTER COLUMN email DROP NOT NULL,
+    ALTER COLUMN email TYPE VARCHAR(30),
+    ALTER COLUMN password DROP NOT NULL,
+    ALTER COLUMN password TYPE VARCHAR(30),
+    ALTER COLUMN created_at DROP NOT NULL,
+    ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN modified_at DROP NOT NULL,
+    ALTER COLUMN modified_at TYPE TIMESTAMP USING modified_at AT TIME ZONE 'UTC',
+    ALTER COLUMN is_admin DROP NOT NULL,
+    ALTER COLUMN is_admin DROP DEFAULT;
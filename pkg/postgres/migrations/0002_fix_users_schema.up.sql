@@ -0,0 +1,36 @@
+-- Backfill the columns which become mandatory.
+UPDATE users SET name = '' WHERE name IS NULL;
+UPDATE users SET is_admin = FALSE WHERE is_admin IS NULL;
+UPDATE users SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+UPDATE users SET modified_at = created_at WHERE modified_at IS NULL;
+
+-- Users without an email, and all but the earliest of users whose emails
+-- differ only by case, are given a unique placeholder under the reserved
+-- .invalid domain, which fits the 30 characters email is still limited to.
+UPDATE users SET email = 'user-' || user_id || '@users.invalid'
+    WHERE email IS NULL;
+UPDATE users later SET email = 'user-' || later.user_id || '@users.invalid'
+    FROM users earlier
+    WHERE LOWER(later.email) = LOWER(earlier.email)
+      AND later.user_id > earlier.user_id;
+-- No bcrypt hash matches an empty password, so users without one cannot
+-- sign in until it is reset.
+UPDATE users SET password = '' WHERE password IS NULL;
+
+ALTER TABLE users
+    ALTER COLUMN name TYPE VARCHAR(100),
+    ALTER COLUMN name SET NOT NULL,
+    -- 254 characters is the longest address permitted by RFC 5321.
+    ALTER COLUMN email TYPE VARCHAR(254),
+    ALTER COLUMN email SET NOT NULL,
+    -- Wide enough for bcrypt's 60 character hashes and future algorithms.
+    ALTER COLUMN password TYPE VARCHAR(255),
+    ALTER COLUMN password SET NOT NULL,
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN created_at SET NOT NULL,
+    ALTER COLUMN modified_at TYPE TIMESTAMPTZ USING modified_at AT TIME ZONE 'UTC',
+    ALTER COLUMN modified_at SET NOT NULL,
+    ALTER COLUMN is_admin SET DEFAULT FALSE,
+    ALTER COLUMN is_admin SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_lower_key ON users (LOWER(email));
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS users_email_prefix_idx;
+DROP INDEX IF EXISTS users_name_prefix_idx;
+DROP INDEX IF EXISTS users_name_idx;
+DROP INDEX IF EXISTS users_created_at_idx;
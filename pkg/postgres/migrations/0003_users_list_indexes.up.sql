@@ -0,0 +1,7 @@
+-- Keyset pagination of ListUsers in each supported sort order.
+CREATE INDEX IF NOT EXISTS users_created_at_idx ON users (created_at, user_id);
+CREATE INDEX IF NOT EXISTS users_name_idx ON users (LOWER(name), user_id);
+
+-- Case-insensitive prefix filters.
+CREATE INDEX IF NOT EXISTS users_name_prefix_idx ON users (LOWER(name) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS users_email_prefix_idx ON users (LOWER(email) text_pattern_ops);
@@ -0,0 +1,4 @@
+-- Incremented on every update, so that writers can detect that a row has
+-- changed since they read it.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,12 @@
+-- Soft deleted users are hidden from reads until restored or purged.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS users_deleted_at_idx ON users (deleted_at)
+    WHERE deleted_at IS NOT NULL;
+
+-- Soft deleted users no longer hold on to their email, so it may be taken
+-- by a new user. Restoring a user whose email was taken in the meantime
+-- violates the index.
+DROP INDEX IF EXISTS users_email_lower_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_lower_key ON users (LOWER(email))
+    WHERE deleted_at IS NULL;
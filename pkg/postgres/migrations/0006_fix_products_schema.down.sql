@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS products_name_prefix_idx;
+
+ALTER TABLE products
+    DROP CONSTRAINT IF EXISTS products_price_check,
+    ALTER COLUMN name DROP NOT NULL,
+    ALTER COLUMN name TYPE VARCHAR(30),
+    ALTER COLUMN price DROP NOT NULL,
+    ALTER COLUMN created_at DROP NOT NULL,
+    ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN modified_at DROP NOT NULL,
+    ALTER COLUMN modified_at TYPE TIMESTAMP USING modified_at AT TIME ZONE 'UTC';
@@ -0,0 +1,16 @@
+UPDATE products SET name = '' WHERE name IS NULL;
+UPDATE products SET price = 0 WHERE price IS NULL;
+UPDATE products SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+UPDATE products SET modified_at = created_at WHERE modified_at IS NULL;
+
+ALTER TABLE products
+    ALTER COLUMN name TYPE VARCHAR(100),
+    ALTER COLUMN name SET NOT NULL,
+    ALTER COLUMN price SET NOT NULL,
+    ADD CONSTRAINT products_price_check CHECK (price >= 0),
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN created_at SET NOT NULL,
+    ALTER COLUMN modified_at TYPE TIMESTAMPTZ USING modified_at AT TIME ZONE 'UTC',
+    ALTER COLUMN modified_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS products_name_prefix_idx ON products (LOWER(name) text_pattern_ops);
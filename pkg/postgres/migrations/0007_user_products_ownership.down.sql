@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS user_products_owner_idx;
+DROP INDEX IF EXISTS user_products_acquired_at_idx;
+
+ALTER TABLE user_products
+    DROP CONSTRAINT IF EXISTS user_products_user_product_key,
+    DROP CONSTRAINT IF EXISTS user_products_quantity_check,
+    DROP COLUMN IF EXISTS acquired_at,
+    DROP COLUMN IF EXISTS quantity,
+    ALTER COLUMN product_id DROP NOT NULL,
+    ALTER COLUMN user_id DROP NOT NULL;
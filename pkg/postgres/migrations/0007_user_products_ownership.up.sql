@@ -0,0 +1,22 @@
+-- Rows without an owner or product are meaningless, and duplicate pairs are
+-- folded into the earliest row before ownership becomes unique per pair.
+DELETE FROM user_products WHERE user_id IS NULL OR product_id IS NULL;
+DELETE FROM user_products later
+    USING user_products earlier
+    WHERE later.user_id = earlier.user_id
+      AND later.product_id = earlier.product_id
+      AND later.user_product_id > earlier.user_product_id;
+
+ALTER TABLE user_products
+    ALTER COLUMN user_id SET NOT NULL,
+    ALTER COLUMN product_id SET NOT NULL,
+    ADD COLUMN IF NOT EXISTS quantity INT NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS acquired_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD CONSTRAINT user_products_quantity_check CHECK (quantity > 0),
+    ADD CONSTRAINT user_products_user_product_key UNIQUE (user_id, product_id);
+
+-- Listing a user's products by acquisition and a product's owners.
+CREATE INDEX IF NOT EXISTS user_products_acquired_at_idx
+    ON user_products (user_id, acquired_at, product_id);
+CREATE INDEX IF NOT EXISTS user_products_owner_idx
+    ON user_products (product_id, user_id);
@@ -0,0 +1,13 @@
+-- Prices in currencies other than USD cannot be represented, so reverting
+-- fails while any exist.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS price INT;
+
+UPDATE products SET price = price_minor::int WHERE currency = 'USD';
+
+ALTER TABLE products
+    ALTER COLUMN price SET NOT NULL,
+    ADD CONSTRAINT products_price_check CHECK (price >= 0),
+    DROP CONSTRAINT IF EXISTS products_currency_check,
+    DROP CONSTRAINT IF EXISTS products_price_minor_check,
+    DROP COLUMN currency,
+    DROP COLUMN price_minor;
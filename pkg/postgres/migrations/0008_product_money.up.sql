@@ -0,0 +1,16 @@
+-- Prices are held as an integer number of the currency's minor units, e.g.
+-- cents, alongside the ISO 4217 currency code. Existing prices are taken to
+-- be US cents.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS price_minor BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD',
+    ADD CONSTRAINT products_price_minor_check CHECK (price_minor >= 0),
+    ADD CONSTRAINT products_currency_check CHECK (currency ~ '^[A-Z]{3}$');
+
+UPDATE products SET price_minor = price;
+
+ALTER TABLE products
+    DROP CONSTRAINT IF EXISTS products_price_check,
+    DROP COLUMN price,
+    ALTER COLUMN price_minor DROP DEFAULT,
+    ALTER COLUMN currency DROP DEFAULT;
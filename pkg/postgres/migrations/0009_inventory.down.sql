@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS stock_reservations;
+DROP TYPE IF EXISTS reservation_status;
+
+ALTER TABLE products
+    DROP CONSTRAINT IF EXISTS products_stock_check,
+    DROP COLUMN IF EXISTS stock;
@@ -0,0 +1,28 @@
+-- stock is the quantity of a product available to reserve; reserved units
+-- are deducted until their reservation is released or expires.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS stock INT NOT NULL DEFAULT 0,
+    ADD CONSTRAINT products_stock_check CHECK (stock >= 0);
+
+CREATE TYPE reservation_status AS ENUM ('pending', 'committed', 'released', 'expired');
+
+CREATE TABLE IF NOT EXISTS stock_reservations
+(
+    reservation_id SERIAL,
+    product_id INT NOT NULL,
+    quantity INT NOT NULL,
+    status reservation_status NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMPTZ NOT NULL,
+    resolved_at TIMESTAMPTZ,
+    PRIMARY KEY(reservation_id),
+    CONSTRAINT stock_reservations_quantity_check CHECK (quantity > 0),
+    CONSTRAINT fk_reservation_product_id
+    FOREIGN KEY(product_id)
+    REFERENCES products(product_id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS stock_reservations_pending_idx
+    ON stock_reservations (expires_at)
+    WHERE status = 'pending';
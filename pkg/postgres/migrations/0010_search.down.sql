@@ -0,0 +1,6 @@
+DROP FUNCTION IF EXISTS html_escape(TEXT);
+
+DROP INDEX IF EXISTS users_search_idx;
+DROP FUNCTION IF EXISTS user_search_vector(TEXT, TEXT);
+
+DROP INDEX IF EXISTS products_search_idx;
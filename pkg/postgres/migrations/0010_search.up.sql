@@ -0,0 +1,29 @@
+-- Full-text search vectors are indexed as expressions over the searchable
+-- columns, so they cannot drift from them and are not read back with every
+-- row. Queries must use the same expressions for the indexes to apply.
+-- Product names are stemmed as English, while user names and emails are
+-- matched as written, with emails also split into the words of their local
+-- part and domain.
+CREATE INDEX IF NOT EXISTS products_search_idx
+    ON products USING GIN (to_tsvector('english', name));
+
+CREATE OR REPLACE FUNCTION user_search_vector(name TEXT, email TEXT)
+RETURNS tsvector
+LANGUAGE sql IMMUTABLE PARALLEL SAFE
+AS $$
+    SELECT setweight(to_tsvector('simple', name), 'A') ||
+        setweight(to_tsvector('simple', email || ' ' || translate(email, '@._+-', '     ')), 'B')
+$$;
+
+CREATE INDEX IF NOT EXISTS users_search_idx
+    ON users USING GIN (user_search_vector(name, email))
+    WHERE deleted_at IS NULL;
+
+-- html_escape escapes text for inclusion in HTML, so that highlighted search
+-- results contain no markup other than the highlights.
+CREATE OR REPLACE FUNCTION html_escape(value TEXT)
+RETURNS TEXT
+LANGUAGE sql IMMUTABLE PARALLEL SAFE
+AS $$
+    SELECT replace(replace(replace(value, '&', '&amp;'), '<', '&lt;'), '>', '&gt;')
+$$;
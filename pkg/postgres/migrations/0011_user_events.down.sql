@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS users_record_event ON users;
+DROP FUNCTION IF EXISTS record_user_event();
+DROP TABLE IF EXISTS user_events;
+DROP TYPE IF EXISTS user_event_kind;
@@ -0,0 +1,62 @@
+CREATE TYPE user_event_kind AS ENUM ('created', 'updated', 'deleted', 'restored');
+
+-- user_events records every change to a user, so that watchers can resume
+-- from the last event they received. Events are ordered by the transaction
+-- which recorded them, since event IDs are allocated before transactions
+-- commit and so may become visible out of order. Events outlive the users
+-- they describe, so user_id is not a foreign key.
+CREATE TABLE IF NOT EXISTS user_events
+(
+    event_id BIGSERIAL,
+    txid BIGINT NOT NULL DEFAULT txid_current(),
+    user_id INT NOT NULL,
+    kind user_event_kind NOT NULL,
+    version INT NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(event_id)
+);
+
+CREATE INDEX IF NOT EXISTS user_events_position_idx
+    ON user_events (txid, event_id);
+
+CREATE INDEX IF NOT EXISTS user_events_occurred_at_idx
+    ON user_events (occurred_at);
+
+-- record_user_event records the change to a user and wakes watchers, which
+-- are notified when the transaction commits. Purging a user which was
+-- already soft deleted is not recorded again.
+CREATE OR REPLACE FUNCTION record_user_event() RETURNS trigger AS $$
+DECLARE
+    event_kind user_event_kind;
+    changed users;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        event_kind := 'created';
+        changed := NEW;
+    ELSIF TG_OP = 'DELETE' THEN
+        IF OLD.deleted_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        event_kind := 'deleted';
+        changed := OLD;
+    ELSIF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        event_kind := 'deleted';
+        changed := NEW;
+    ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+        event_kind := 'restored';
+        changed := NEW;
+    ELSE
+        event_kind := 'updated';
+        changed := NEW;
+    END IF;
+
+    INSERT INTO user_events (user_id, kind, version)
+    VALUES (changed.user_id, event_kind, changed.version);
+    PERFORM pg_notify('user_events', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_record_event
+    AFTER INSERT OR UPDATE OR DELETE ON users
+    FOR EACH ROW EXECUTE FUNCTION record_user_event();
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS products_record_outbox_event ON products;
+DROP TRIGGER IF EXISTS users_record_outbox_event ON users;
+DROP FUNCTION IF EXISTS record_outbox_event();
+DROP TABLE IF EXISTS outbox;
@@ -0,0 +1,79 @@
+-- outbox holds the domain events of changes to users and products until they
+-- are published. Events are written by triggers, so they commit or roll back
+-- with the change they describe, and are published by the outbox relay.
+-- aggregate_id is not a foreign key, since events outlive what they describe.
+-- Outbox IDs are allocated before the writing transaction commits, so events
+-- are ordered by the ID of the transaction which wrote them, txid, and only
+-- relayed once every transaction with a lower ID has ended, so that no event
+-- can appear before those already relayed.
+CREATE TABLE IF NOT EXISTS outbox
+(
+    outbox_id BIGSERIAL,
+    aggregate_type TEXT NOT NULL,
+    aggregate_id INT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT,
+    published_at TIMESTAMPTZ,
+    txid BIGINT NOT NULL DEFAULT txid_current(),
+    PRIMARY KEY(outbox_id)
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx
+    ON outbox (txid, outbox_id) WHERE published_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS outbox_published_at_idx
+    ON outbox (published_at) WHERE published_at IS NOT NULL;
+
+-- record_outbox_event writes an event named after the aggregate given as the
+-- trigger's first argument, whose ID is the column named by the second, and
+-- wakes the relay once the transaction commits. The payload is the changed
+-- row, without password hashes. Soft deletion and restoration are recorded
+-- as deleted and restored, and purging a row which was already soft deleted
+-- is not recorded again.
+CREATE OR REPLACE FUNCTION record_outbox_event() RETURNS trigger AS $$
+DECLARE
+    aggregate TEXT := TG_ARGV[0];
+    changed JSONB;
+    previous JSONB;
+    event TEXT;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        event := 'created';
+        changed := to_jsonb(NEW);
+    ELSIF TG_OP = 'DELETE' THEN
+        changed := to_jsonb(OLD);
+        IF changed->>'deleted_at' IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        event := 'deleted';
+    ELSE
+        changed := to_jsonb(NEW);
+        previous := to_jsonb(OLD);
+        IF previous->>'deleted_at' IS NULL AND changed->>'deleted_at' IS NOT NULL THEN
+            event := 'deleted';
+        ELSIF previous->>'deleted_at' IS NOT NULL AND changed->>'deleted_at' IS NULL THEN
+            event := 'restored';
+        ELSE
+            event := 'updated';
+        END IF;
+    END IF;
+
+    changed := changed - 'password';
+    INSERT INTO outbox (aggregate_type, aggregate_id, event_type, payload)
+    VALUES (aggregate, (changed->>TG_ARGV[1])::int, aggregate || '.' || event, changed);
+    PERFORM pg_notify('outbox', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_record_outbox_event
+    AFTER INSERT OR UPDATE OR DELETE ON users
+    FOR EACH ROW EXECUTE FUNCTION record_outbox_event('user', 'user_id');
+
+CREATE TRIGGER products_record_outbox_event
+    AFTER INSERT OR UPDATE OR DELETE ON products
+    FOR EACH ROW EXECUTE FUNCTION record_outbox_event('product', 'product_id');
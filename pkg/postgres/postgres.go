@@ -0,0 +1,251 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	// Registers the postgres driver with database/sql.
+	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const (
+	// DriverName is the database/sql driver registered by this package.
+	DriverName = "postgres"
+
+	connEnvVar            = "POSTGRES_CONN_STR"
+	maxOpenConnsEnvVar    = "POSTGRES_MAX_OPEN_CONNS"
+	maxIdleConnsEnvVar    = "POSTGRES_MAX_IDLE_CONNS"
+	connMaxLifetimeEnvVar = "POSTGRES_CONN_MAX_LIFETIME"
+	connMaxIdleTimeEnvVar = "POSTGRES_CONN_MAX_IDLE_TIME"
+	connectAttemptsEnvVar = "POSTGRES_CONNECT_ATTEMPTS"
+	replicasEnvVar        = "POSTGRES_REPLICA_CONN_STRS"
+
+	defaultMetricsName = "playground"
+)
+
+var ErrConnStrMissing = errors.New("postgres connection string was not specified")
+
+// connectAfter waits between connection attempts, and is replaced by tests.
+var connectAfter = time.After
+
+// queryName matches the name annotation sqlc places at the start of every
+// generated query, e.g. "-- name: GetUser :one".
+var queryName = regexp.MustCompile(`^--\s*name:\s*(\w+)`)
+
+// QueryName returns the sqlc name of a generated query, or an empty string
+// for queries which were not generated by sqlc.
+func QueryName(query string) string {
+	if m := queryName.FindStringSubmatch(query); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// Config controls how the connection pool is established and sized.
+type Config struct {
+	ConnStr string
+	// ReplicaConnStrs are read replicas of ConnStr which read-only queries
+	// may be routed to.
+	ReplicaConnStrs []string
+	// MetricsName identifies the pool in the exported pool statistics.
+	MetricsName     string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// ConnectAttempts is the number of times the initial connection is
+	// attempted before giving up.
+	ConnectAttempts int
+	// ConnectBackoff is the delay before the first retry, doubling on each
+	// subsequent attempt up to MaxConnectBackoff.
+	ConnectBackoff    time.Duration
+	MaxConnectBackoff time.Duration
+	// PingTimeout bounds each connection attempt.
+	PingTimeout time.Duration
+}
+
+// DefaultConfig returns a Config with conservative pool settings.
+func DefaultConfig() Config {
+	return Config{
+		MetricsName:       defaultMetricsName,
+		MaxOpenConns:      25,
+		MaxIdleConns:      25,
+		ConnMaxLifetime:   time.Minute * 30,
+		ConnMaxIdleTime:   time.Minute * 5,
+		ConnectAttempts:   5,
+		ConnectBackoff:    time.Millisecond * 500,
+		MaxConnectBackoff: time.Second * 10,
+		PingTimeout:       time.Second * 5,
+	}
+}
+
+// ConfigFromEnv returns the DefaultConfig overridden by any POSTGRES_*
+// environment variables which are set.
+func ConfigFromEnv() (Config, error) {
+	cfg := DefaultConfig()
+	cfg.ConnStr = os.Getenv(connEnvVar)
+	for _, connStr := range strings.Split(os.Getenv(replicasEnvVar), ",") {
+		if connStr = strings.TrimSpace(connStr); connStr != "" {
+			cfg.ReplicaConnStrs = append(cfg.ReplicaConnStrs, connStr)
+		}
+	}
+
+	var err error
+	if cfg.MaxOpenConns, err = intFromEnv(maxOpenConnsEnvVar, cfg.MaxOpenConns); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxIdleConns, err = intFromEnv(maxIdleConnsEnvVar, cfg.MaxIdleConns); err != nil {
+		return cfg, err
+	}
+	if cfg.ConnectAttempts, err = intFromEnv(connectAttemptsEnvVar, cfg.ConnectAttempts); err != nil {
+		return cfg, err
+	}
+	if cfg.ConnMaxLifetime, err = durationFromEnv(connMaxLifetimeEnvVar, cfg.ConnMaxLifetime); err != nil {
+		return cfg, err
+	}
+	if cfg.ConnMaxIdleTime, err = durationFromEnv(connMaxIdleTimeEnvVar, cfg.ConnMaxIdleTime); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// OpenReplicas opens a pool for each of the replicas in cfg, configured in
+// the same way as the primary pool.
+func OpenReplicas(
+	ctx context.Context,
+	cfg Config,
+	registerer prometheus.Registerer,
+) ([]*sql.DB, error) {
+	replicas := make([]*sql.DB, 0, len(cfg.ReplicaConnStrs))
+	for i, connStr := range cfg.ReplicaConnStrs {
+		replicaCfg := cfg
+		replicaCfg.ConnStr = connStr
+		replicaCfg.MetricsName = fmt.Sprintf("%s_replica_%d", cfg.MetricsName, i)
+
+		db, err := Open(ctx, replicaCfg, registerer)
+		if err != nil {
+			for _, opened := range replicas {
+				_ = opened.Close()
+			}
+			return nil, err
+		}
+		replicas = append(replicas, db)
+	}
+
+	return replicas, nil
+}
+
+// Open creates a connection pool configured by cfg, retrying the initial
+// connection with exponential backoff until the database is reachable. When
+// registerer is not nil the pool statistics are registered as metrics.
+func Open(
+	ctx context.Context,
+	cfg Config,
+	registerer prometheus.Registerer,
+) (*sql.DB, error) {
+	if cfg.ConnStr == "" {
+		return nil, ErrConnStrMissing
+	}
+
+	db, err := sql.Open(DriverName, cfg.ConnStr)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	if err = connect(ctx, db, cfg); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	if registerer != nil {
+		if err = registerer.Register(
+			collectors.NewDBStatsCollector(db, cfg.MetricsName),
+		); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+func connect(ctx context.Context, db *sql.DB, cfg Config) error {
+	attempts := cfg.ConnectAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := cfg.ConnectBackoff
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = ping(ctx, db, cfg.PingTimeout); err == nil {
+			return nil
+		}
+		if attempt >= attempts {
+			return fmt.Errorf(
+				"postgres unreachable after %d attempts: %w", attempt, err,
+			)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-connectAfter(backoff):
+		}
+
+		backoff *= 2
+		if cfg.MaxConnectBackoff > 0 && backoff > cfg.MaxConnectBackoff {
+			backoff = cfg.MaxConnectBackoff
+		}
+	}
+}
+
+func ping(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return db.PingContext(ctx)
+}
+
+func intFromEnv(key string, fallback int) (int, error) {
+	val, ok := os.LookupEnv(key)
+	if !ok || val == "" {
+		return fallback, nil
+	}
+
+	parsed, err := strconv.Atoi(val)
+	if err != nil {
+		return fallback, fmt.Errorf("%s must be an integer: %w", key, err)
+	}
+	return parsed, nil
+}
+
+func durationFromEnv(key string, fallback time.Duration) (time.Duration, error) {
+	val, ok := os.LookupEnv(key)
+	if !ok || val == "" {
+		return fallback, nil
+	}
+
+	parsed, err := time.ParseDuration(val)
+	if err != nil {
+		return fallback, fmt.Errorf("%s must be a duration: %w", key, err)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigFromEnv_Variables_ShouldOverrideDefaults(t *testing.T) {
+	defaults := DefaultConfig()
+	cases := []struct {
+		name    string
+		env     map[string]string
+		want    func(cfg *Config)
+		wantErr bool
+	}{
+		{
+			name: "unset",
+			env:  map[string]string{},
+			want: func(*Config) {},
+		},
+		{
+			name: "connection strings",
+			env: map[string]string{
+				connEnvVar:     "postgres://primary",
+				replicasEnvVar: " postgres://a , ,postgres://b",
+			},
+			want: func(cfg *Config) {
+				cfg.ConnStr = "postgres://primary"
+				cfg.ReplicaConnStrs = []string{"postgres://a", "postgres://b"}
+			},
+		},
+		{
+			name: "pool settings",
+			env: map[string]string{
+				maxOpenConnsEnvVar:    "50",
+				maxIdleConnsEnvVar:    "10",
+				connectAttemptsEnvVar: "2",
+				connMaxLifetimeEnvVar: "1h",
+				connMaxIdleTimeEnvVar: "90s",
+			},
+			want: func(cfg *Config) {
+				cfg.MaxOpenConns = 50
+				cfg.MaxIdleConns = 10
+				cfg.ConnectAttempts = 2
+				cfg.ConnMaxLifetime = time.Hour
+				cfg.ConnMaxIdleTime = time.Second * 90
+			},
+		},
+		{
+			name:    "invalid integer",
+			env:     map[string]string{maxOpenConnsEnvVar: "many"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid duration",
+			env:     map[string]string{connMaxIdleTimeEnvVar: "90"},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for _, key := range []string{
+				connEnvVar, replicasEnvVar, maxOpenConnsEnvVar,
+				maxIdleConnsEnvVar, connectAttemptsEnvVar,
+				connMaxLifetimeEnvVar, connMaxIdleTimeEnvVar,
+			} {
+				t.Setenv(key, c.env[key])
+			}
+
+			cfg, err := ConfigFromEnv()
+			if c.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			want := defaults
+			c.want(&want)
+			assert.Equal(t, want, cfg)
+		})
+	}
+}
+
+// recordDelays replaces the wait between connection attempts for the
+// duration of a test, recording each delay instead of sleeping.
+func recordDelays(t *testing.T) *[]time.Duration {
+	delays := &[]time.Duration{}
+	connectAfter = func(d time.Duration) <-chan time.Time {
+		*delays = append(*delays, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+	t.Cleanup(func() { connectAfter = time.After })
+	return delays
+}
+
+func TestConnect_TransientFailures_ShouldRetryWithCappedBackoff(t *testing.T) {
+	delays := recordDelays(t)
+	d := &testDriver{connectFailures: 4}
+	db := newTestDB(d)
+	defer db.Close()
+
+	err := connect(context.Background(), db, Config{
+		ConnectAttempts:   6,
+		ConnectBackoff:    time.Millisecond * 100,
+		MaxConnectBackoff: time.Millisecond * 300,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, d.connects)
+	assert.Equal(t, []time.Duration{
+		time.Millisecond * 100,
+		time.Millisecond * 200,
+		time.Millisecond * 300,
+		time.Millisecond * 300,
+	}, *delays)
+}
+
+func TestConnect_AttemptsExhausted_ShouldReturnLastError(t *testing.T) {
+	delays := recordDelays(t)
+	d := &testDriver{connectFailures: 10}
+	db := newTestDB(d)
+	defer db.Close()
+
+	err := connect(context.Background(), db, Config{
+		ConnectAttempts: 3,
+		ConnectBackoff:  time.Millisecond * 100,
+	})
+	assert.ErrorIs(t, err, errTestConnect)
+	assert.Contains(t, err.Error(), "after 3 attempts")
+	assert.Equal(t, 3, d.connects)
+	assert.Len(t, *delays, 2)
+}
+
+func TestConnect_ContextCancelled_ShouldStopRetrying(t *testing.T) {
+	d := &testDriver{connectFailures: 10}
+	db := newTestDB(d)
+	defer db.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := connect(ctx, db, Config{
+		ConnectAttempts: 5,
+		ConnectBackoff:  time.Hour,
+	})
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestOpen_MissingConnStr_ShouldFail(t *testing.T) {
+	_, err := Open(context.Background(), DefaultConfig(), nil)
+	assert.ErrorIs(t, err, ErrConnStrMissing)
+}
+
+func TestOpen_Unreachable_ShouldFailAfterAttempts(t *testing.T) {
+	cfg := DefaultConfig()
+	// Nothing listens on port 1, so connecting is refused immediately.
+	cfg.ConnStr = "postgres://127.0.0.1:1/playground?sslmode=disable&connect_timeout=1"
+	cfg.ConnectAttempts = 1
+	cfg.PingTimeout = time.Second * 2
+
+	db, err := Open(context.Background(), cfg, nil)
+	assert.Nil(t, db)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "after 1 attempts")
+}
@@ -1,39 +1,395 @@
 -- name: GetUser :one
 SELECT * FROM users
-WHERE user_id = $1 LIMIT 1;
+WHERE user_id = $1 AND deleted_at IS NULL LIMIT 1;
 
--- name: DeleteUser :exec
+-- name: DeleteUser :execrows
 DELETE FROM users
-WHERE user_id = $1;
+WHERE user_id = sqlc.arg('user_id')
+  AND (sqlc.narg('version')::int IS NULL OR version = sqlc.narg('version')::int);
 
--- name: UpdateUser :exec
+-- name: SoftDeleteUser :execrows
 UPDATE users SET
-    name = $1, email = $2, password = $3, is_admin = $4, modified_at = now()::timestamp
-WHERE user_id = $5;
+    deleted_at = now(),
+    version = version + 1
+WHERE user_id = sqlc.arg('user_id')
+  AND deleted_at IS NULL
+  AND (sqlc.narg('version')::int IS NULL OR version = sqlc.narg('version')::int);
 
--- name: CreateUser :exec
+-- name: RestoreUser :one
+UPDATE users SET
+    deleted_at = NULL,
+    modified_at = now(),
+    version = version + 1
+WHERE user_id = $1 AND deleted_at IS NOT NULL
+RETURNING *;
+
+-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE user_id IN (
+    SELECT purged.user_id FROM users purged
+    WHERE purged.deleted_at < sqlc.arg('deleted_before')::timestamptz
+    ORDER BY purged.deleted_at
+    LIMIT sqlc.arg('batch_size')
+);
+
+-- name: UpdateUser :one
+UPDATE users SET
+    name = COALESCE(sqlc.narg('name'), name),
+    email = COALESCE(sqlc.narg('email'), email),
+    password = COALESCE(sqlc.narg('password'), password),
+    is_admin = COALESCE(sqlc.narg('is_admin'), is_admin),
+    modified_at = now(),
+    version = version + 1
+WHERE user_id = sqlc.arg('user_id')
+  AND deleted_at IS NULL
+  AND (sqlc.narg('version')::int IS NULL OR version = sqlc.narg('version')::int)
+RETURNING *;
+
+-- name: CreateUser :one
 INSERT INTO users (
-    user_id, name, email, password, is_admin, created_at, modified_at
+    name, email, password, is_admin, created_at, modified_at
 ) VALUES (
-    $1, $2, $3, $4, $5, now()::timestamp, now()::timestamp
-);
+    $1, $2, $3, $4, now(), now()
+)
+RETURNING *;
+
+-- name: CreateUsers :many
+-- CreateUsers inserts a batch of Users in one statement, skipping those
+-- whose email is already taken by a User which is not deleted.
+INSERT INTO users (
+    name, email, password, is_admin, created_at, modified_at
+)
+SELECT
+    unnest(sqlc.arg('names')::text[]),
+    unnest(sqlc.arg('emails')::text[]),
+    unnest(sqlc.arg('passwords')::text[]),
+    unnest(sqlc.arg('is_admins')::bool[]),
+    now(),
+    now()
+ON CONFLICT (LOWER(email)) WHERE deleted_at IS NULL DO NOTHING
+RETURNING *;
+
+-- name: ListTakenEmails :many
+-- ListTakenEmails returns the lowercased emails of a batch which are taken
+-- by Users which are not deleted.
+SELECT LOWER(email)::text AS email FROM users
+WHERE LOWER(email) = ANY(sqlc.arg('emails')::text[])
+  AND deleted_at IS NULL;
+
+-- name: ExportUsers :many
+SELECT * FROM users
+WHERE deleted_at IS NULL
+  AND (sqlc.narg('after_id')::int IS NULL OR user_id > sqlc.narg('after_id')::int)
+ORDER BY user_id
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListUsersByCreatedAsc :many
+SELECT * FROM users
+WHERE deleted_at IS NULL
+  AND (sqlc.narg('name_prefix')::text IS NULL
+        OR LOWER(name) LIKE LOWER(sqlc.narg('name_prefix')::text) || '%')
+  AND (sqlc.narg('email_prefix')::text IS NULL
+        OR LOWER(email) LIKE LOWER(sqlc.narg('email_prefix')::text) || '%')
+  AND (sqlc.narg('is_admin')::boolean IS NULL
+        OR is_admin = sqlc.narg('is_admin')::boolean)
+  AND (sqlc.narg('created_after')::timestamptz IS NULL
+        OR created_at >= sqlc.narg('created_after')::timestamptz)
+  AND (sqlc.narg('created_before')::timestamptz IS NULL
+        OR created_at < sqlc.narg('created_before')::timestamptz)
+  AND (sqlc.narg('after_created_at')::timestamptz IS NULL
+        OR (created_at, user_id) > (sqlc.narg('after_created_at')::timestamptz, sqlc.narg('after_id')::int))
+ORDER BY created_at ASC, user_id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListUsersByCreatedDesc :many
+SELECT * FROM users
+WHERE deleted_at IS NULL
+  AND (sqlc.narg('name_prefix')::text IS NULL
+        OR LOWER(name) LIKE LOWER(sqlc.narg('name_prefix')::text) || '%')
+  AND (sqlc.narg('email_prefix')::text IS NULL
+        OR LOWER(email) LIKE LOWER(sqlc.narg('email_prefix')::text) || '%')
+  AND (sqlc.narg('is_admin')::boolean IS NULL
+        OR is_admin = sqlc.narg('is_admin')::boolean)
+  AND (sqlc.narg('created_after')::timestamptz IS NULL
+        OR created_at >= sqlc.narg('created_after')::timestamptz)
+  AND (sqlc.narg('created_before')::timestamptz IS NULL
+        OR created_at < sqlc.narg('created_before')::timestamptz)
+  AND (sqlc.narg('after_created_at')::timestamptz IS NULL
+        OR (created_at, user_id) < (sqlc.narg('after_created_at')::timestamptz, sqlc.narg('after_id')::int))
+ORDER BY created_at DESC, user_id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListUsersByNameAsc :many
+-- ListUsersByNameAsc returns the lowercased name each User is ordered by,
+-- from which the position of the next page is built.
+SELECT sqlc.embed(users), LOWER(name)::text AS sort_name FROM users
+WHERE deleted_at IS NULL
+  AND (sqlc.narg('name_prefix')::text IS NULL
+        OR LOWER(name) LIKE LOWER(sqlc.narg('name_prefix')::text) || '%')
+  AND (sqlc.narg('email_prefix')::text IS NULL
+        OR LOWER(email) LIKE LOWER(sqlc.narg('email_prefix')::text) || '%')
+  AND (sqlc.narg('is_admin')::boolean IS NULL
+        OR is_admin = sqlc.narg('is_admin')::boolean)
+  AND (sqlc.narg('created_after')::timestamptz IS NULL
+        OR created_at >= sqlc.narg('created_after')::timestamptz)
+  AND (sqlc.narg('created_before')::timestamptz IS NULL
+        OR created_at < sqlc.narg('created_before')::timestamptz)
+  AND (sqlc.narg('after_name')::text IS NULL
+        OR (LOWER(name), user_id) > (sqlc.narg('after_name')::text, sqlc.narg('after_id')::int))
+ORDER BY LOWER(name) ASC, user_id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListUsersByNameDesc :many
+-- ListUsersByNameDesc returns the lowercased name each User is ordered by,
+-- from which the position of the next page is built.
+SELECT sqlc.embed(users), LOWER(name)::text AS sort_name FROM users
+WHERE deleted_at IS NULL
+  AND (sqlc.narg('name_prefix')::text IS NULL
+        OR LOWER(name) LIKE LOWER(sqlc.narg('name_prefix')::text) || '%')
+  AND (sqlc.narg('email_prefix')::text IS NULL
+        OR LOWER(email) LIKE LOWER(sqlc.narg('email_prefix')::text) || '%')
+  AND (sqlc.narg('is_admin')::boolean IS NULL
+        OR is_admin = sqlc.narg('is_admin')::boolean)
+  AND (sqlc.narg('created_after')::timestamptz IS NULL
+        OR created_at >= sqlc.narg('created_after')::timestamptz)
+  AND (sqlc.narg('created_before')::timestamptz IS NULL
+        OR created_at < sqlc.narg('created_before')::timestamptz)
+  AND (sqlc.narg('after_name')::text IS NULL
+        OR (LOWER(name), user_id) < (sqlc.narg('after_name')::text, sqlc.narg('after_id')::int))
+ORDER BY LOWER(name) DESC, user_id DESC
+LIMIT sqlc.arg('page_limit');
 
 -- name: GetProduct :one
 SELECT * FROM products
 WHERE product_id = $1 LIMIT 1;
 
--- name: DeleteProduct :exec
+-- name: ListProducts :many
+SELECT * FROM products
+WHERE (sqlc.narg('name_prefix')::text IS NULL
+        OR LOWER(name) LIKE LOWER(sqlc.narg('name_prefix')::text) || '%')
+  AND (sqlc.narg('after_id')::int IS NULL OR product_id > sqlc.narg('after_id')::int)
+ORDER BY product_id
+LIMIT sqlc.arg('page_limit');
+
+-- name: DeleteProduct :execrows
 DELETE FROM products
-WHERE product_id = $1;
+WHERE product_id = sqlc.arg('product_id')
+  AND (sqlc.narg('version')::int IS NULL OR version = sqlc.narg('version')::int);
 
--- name: UpdateProduct :exec
+-- name: UpdateProduct :one
 UPDATE products SET
-     name = $1, price = $2, modified_at = now()::timestamp
-WHERE product_id = $3;
+    name = COALESCE(sqlc.narg('name'), name),
+    price_minor = COALESCE(sqlc.narg('price_minor'), price_minor),
+    currency = COALESCE(sqlc.narg('currency'), currency),
+    stock = COALESCE(sqlc.narg('stock'), stock),
+    modified_at = now(),
+    version = version + 1
+WHERE product_id = sqlc.arg('product_id')
+  AND (sqlc.narg('version')::int IS NULL OR version = sqlc.narg('version')::int)
+RETURNING *;
 
--- name: CreateProduct :exec
+-- name: CreateProduct :one
 INSERT INTO products (
-    product_id, name, price, created_at, modified_at
+    name, price_minor, currency, stock, created_at, modified_at
 ) VALUES (
-     $1, $2, $3, now()::timestamp, now()::timestamp
- );
\ No newline at end of file
+    $1, $2, $3, $4, now(), now()
+)
+RETURNING *;
+
+-- name: GetProductForUpdate :one
+SELECT * FROM products
+WHERE product_id = $1
+FOR UPDATE;
+
+-- name: AdjustProductStock :exec
+-- AdjustProductStock leaves the version and modified_at unchanged, so that
+-- reservations do not conflict with conditional updates of the Product.
+UPDATE products SET
+    stock = stock + sqlc.arg('delta')::int
+WHERE product_id = sqlc.arg('product_id');
+
+-- name: CreateReservation :one
+INSERT INTO stock_reservations (
+    product_id, quantity, expires_at
+) VALUES (
+    $1, $2, $3
+)
+RETURNING *;
+
+-- name: GetReservationForUpdate :one
+SELECT * FROM stock_reservations
+WHERE reservation_id = $1
+FOR UPDATE;
+
+-- name: ResolveReservation :one
+UPDATE stock_reservations SET
+    status = $2,
+    resolved_at = now()
+WHERE reservation_id = $1
+RETURNING *;
+
+-- name: ListExpiredReservations :many
+SELECT * FROM stock_reservations
+WHERE status = 'pending' AND expires_at <= sqlc.arg('expired_before')::timestamptz
+ORDER BY product_id, reservation_id
+LIMIT sqlc.arg('batch_size')
+FOR UPDATE SKIP LOCKED;
+
+-- name: AssignProduct :one
+INSERT INTO user_products (user_id, product_id, quantity, acquired_at)
+SELECT u.user_id, p.product_id, sqlc.arg('quantity')::int, now()
+FROM users u, products p
+WHERE u.user_id = sqlc.arg('user_id') AND u.deleted_at IS NULL
+  AND p.product_id = sqlc.arg('product_id')
+ON CONFLICT (user_id, product_id)
+    DO UPDATE SET quantity = user_products.quantity + EXCLUDED.quantity,
+                  acquired_at = EXCLUDED.acquired_at
+RETURNING *;
+
+-- name: GetUserProductForUpdate :one
+SELECT * FROM user_products
+WHERE user_id = $1 AND product_id = $2
+FOR UPDATE;
+
+-- name: SetUserProductQuantity :exec
+UPDATE user_products SET quantity = sqlc.arg('quantity')
+WHERE user_id = sqlc.arg('user_id') AND product_id = sqlc.arg('product_id');
+
+-- name: DeleteUserProduct :execrows
+DELETE FROM user_products
+WHERE user_id = $1 AND product_id = $2;
+
+-- name: ListUserProducts :many
+SELECT sqlc.embed(products), user_products.quantity, user_products.acquired_at
+FROM user_products
+JOIN products ON products.product_id = user_products.product_id
+JOIN users ON users.user_id = user_products.user_id
+WHERE user_products.user_id = sqlc.arg('user_id') AND users.deleted_at IS NULL
+  AND (sqlc.narg('after_acquired_at')::timestamptz IS NULL
+        OR (user_products.acquired_at, user_products.product_id)
+            < (sqlc.narg('after_acquired_at')::timestamptz, sqlc.narg('after_id')::int))
+ORDER BY user_products.acquired_at DESC, user_products.product_id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListProductOwners :many
+SELECT sqlc.embed(users), user_products.quantity, user_products.acquired_at
+FROM user_products
+JOIN users ON users.user_id = user_products.user_id
+WHERE user_products.product_id = sqlc.arg('product_id')
+  AND users.deleted_at IS NULL
+  AND (sqlc.narg('after_id')::int IS NULL OR user_products.user_id > sqlc.narg('after_id')::int)
+ORDER BY user_products.user_id
+LIMIT sqlc.arg('page_limit');
+
+-- name: SearchProducts :many
+-- SearchProducts matches the expression of products_search_idx, and
+-- highlights the escaped name so that only highlights are markup.
+SELECT sqlc.embed(products),
+    ts_rank(to_tsvector('english', products.name), query)::real AS rank,
+    ts_headline('english', html_escape(products.name), query, 'HighlightAll=true')::text AS name_highlight
+FROM products, websearch_to_tsquery('english', sqlc.arg('query')) query
+WHERE to_tsvector('english', products.name) @@ query
+    AND (sqlc.narg('after_rank')::real IS NULL
+        OR (ts_rank(to_tsvector('english', products.name), query), products.product_id) <
+           (sqlc.narg('after_rank')::real, sqlc.narg('after_id')::int))
+ORDER BY rank DESC, products.product_id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: SearchUsers :many
+-- SearchUsers matches the expression of users_search_idx, and highlights
+-- the escaped name and email so that only highlights are markup.
+SELECT sqlc.embed(users),
+    ts_rank(user_search_vector(users.name, users.email), query)::real AS rank,
+    ts_headline('simple', html_escape(users.name), query, 'HighlightAll=true')::text AS name_highlight,
+    ts_headline('simple', html_escape(users.email), query, 'HighlightAll=true')::text AS email_highlight
+FROM users, websearch_to_tsquery('simple', sqlc.arg('query')) query
+WHERE user_search_vector(users.name, users.email) @@ query
+    AND users.deleted_at IS NULL
+    AND (sqlc.narg('after_rank')::real IS NULL
+        OR (ts_rank(user_search_vector(users.name, users.email), query), users.user_id) <
+           (sqlc.narg('after_rank')::real, sqlc.narg('after_id')::int))
+ORDER BY rank DESC, users.user_id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: GetUserEventsHead :one
+-- GetUserEventsHead returns the oldest transaction which may still record
+-- events, before which every event is visible.
+SELECT txid_snapshot_xmin(txid_current_snapshot())::bigint AS txid;
+
+-- name: UserEventExists :one
+SELECT EXISTS(SELECT 1 FROM user_events WHERE event_id = $1);
+
+-- name: ListUserEvents :many
+-- ListUserEvents lists the events after a position, excluding those of
+-- transactions which may still be in progress so that no event is skipped.
+SELECT * FROM user_events
+WHERE txid < txid_snapshot_xmin(txid_current_snapshot())
+  AND (txid, event_id) > (sqlc.arg('after_txid')::bigint, sqlc.arg('after_id')::bigint)
+  AND (sqlc.narg('user_ids')::int[] IS NULL OR user_id = ANY(sqlc.narg('user_ids')::int[]))
+  AND (sqlc.narg('kinds')::user_event_kind[] IS NULL OR kind = ANY(sqlc.narg('kinds')::user_event_kind[]))
+ORDER BY txid, event_id
+LIMIT sqlc.arg('page_limit');
+
+-- name: PruneUserEvents :execrows
+DELETE FROM user_events
+WHERE event_id IN (
+    SELECT event_id FROM user_events
+    WHERE occurred_at < sqlc.arg('occurred_before')::timestamptz
+    ORDER BY event_id
+    LIMIT sqlc.arg('batch_size')
+);
+
+-- name: LockOutboxClaims :exec
+-- LockOutboxClaims serializes claims of outbox events until the end of the
+-- transaction. Claims must be made by a later statement of the transaction,
+-- whose snapshot includes the leases of the claims which preceded it.
+SELECT pg_advisory_xact_lock(7251923345);
+
+-- name: ClaimOutboxEvents :many
+-- ClaimOutboxEvents leases a batch of the oldest events due to be published
+-- until lease_until, so that concurrent relays do not publish them too. It
+-- must follow LockOutboxClaims, as a concurrent claim's leases would not be
+-- visible and the successors of the events it claims could be claimed.
+-- Events are ordered by (txid, outbox_id). Events of transactions which may
+-- still be in progress are excluded, and no event is claimed after one which
+-- is not yet due, either waiting to be retried or leased by another relay,
+-- so that no event is published before those preceding it.
+UPDATE outbox
+SET next_attempt_at = sqlc.arg('lease_until')::timestamptz
+WHERE outbox_id IN (
+    SELECT pending.outbox_id FROM outbox pending
+    WHERE pending.published_at IS NULL
+      AND pending.next_attempt_at <= CURRENT_TIMESTAMP
+      AND pending.txid < txid_snapshot_xmin(txid_current_snapshot())
+      AND NOT EXISTS (
+          SELECT 1 FROM outbox held
+          WHERE held.published_at IS NULL
+            AND held.next_attempt_at > CURRENT_TIMESTAMP
+            AND (held.txid, held.outbox_id) < (pending.txid, pending.outbox_id)
+      )
+    ORDER BY pending.txid, pending.outbox_id
+    LIMIT sqlc.arg('batch_size')
+    FOR UPDATE
+)
+RETURNING *;
+
+-- name: MarkOutboxEventsPublished :exec
+UPDATE outbox
+SET published_at = CURRENT_TIMESTAMP, last_error = NULL
+WHERE outbox_id = ANY(sqlc.arg('outbox_ids')::bigint[]);
+
+-- name: RetryOutboxEvents :exec
+UPDATE outbox
+SET attempts = attempts + 1,
+    last_error = sqlc.arg('last_error')::text,
+    next_attempt_at = sqlc.arg('next_attempt_at')::timestamptz
+WHERE outbox_id = ANY(sqlc.arg('outbox_ids')::bigint[]);
+
+-- name: PruneOutboxEvents :execrows
+DELETE FROM outbox
+WHERE outbox_id IN (
+    SELECT outbox_id FROM outbox
+    WHERE published_at < sqlc.arg('published_before')::timestamptz
+    ORDER BY outbox_id
+    LIMIT sqlc.arg('batch_size')
+);
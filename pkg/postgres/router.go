@@ -0,0 +1,330 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/clintrovert/go-playground/pkg/identity"
+	"github.com/clintrovert/go-playground/pkg/postgres/database"
+)
+
+const (
+	defaultStickiness = time.Second * 5
+	defaultMaxLag     = time.Second * 10
+
+	// replicationLag reports whether a standby has replayed all of the WAL
+	// it has received, and how long ago it replayed the last transaction.
+	// Against a primary it reports being caught up. The time since the last
+	// replayed transaction grows while the primary is idle, so it only
+	// measures lag when the standby is behind.
+	replicationLag = `
+SELECT
+    NOT pg_is_in_recovery()
+        OR COALESCE(pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn(), false),
+    COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)`
+)
+
+// DefaultReadQueries are the sqlc queries routed to replicas by default.
+var DefaultReadQueries = []string{
+	"GetUser",
+	"GetProduct",
+	"ListUsersByCreatedAsc",
+	"ListUsersByCreatedDesc",
+	"ListUsersByNameAsc",
+	"ListUsersByNameDesc",
+	"ListProducts",
+	"ListUserProducts",
+	"ListProductOwners",
+	"SearchProducts",
+	"SearchUsers",
+	"ExportUsers",
+}
+
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// Router is a database.DBTX which sends the configured read-only queries to
+// a pool of replicas and everything else to the primary. A caller is pinned
+// to the primary for a short window after it writes, so that it always
+// reads its own writes, and replicas which fail or lag too far behind are
+// skipped until a health check finds them usable again.
+type Router struct {
+	primary     *sql.DB
+	replicas    []*replica
+	reads       map[string]struct{}
+	caller      identity.CallerFunc
+	stickiness  time.Duration
+	maxLag      time.Duration
+	next        atomic.Uint32
+	mu          sync.Mutex
+	lastWriteAt map[string]time.Time
+}
+
+var _ database.DBTX = (*Router)(nil)
+
+// NewRouter creates a new instance of a Router. Replicas start out healthy
+// and reads defaults to DefaultReadQueries when empty.
+func NewRouter(primary *sql.DB, replicas []*sql.DB, reads ...string) *Router {
+	if len(reads) == 0 {
+		reads = DefaultReadQueries
+	}
+
+	r := &Router{
+		primary:     primary,
+		reads:       make(map[string]struct{}, len(reads)),
+		caller:      identity.FromAuthorization,
+		stickiness:  defaultStickiness,
+		maxLag:      defaultMaxLag,
+		lastWriteAt: map[string]time.Time{},
+	}
+	for _, name := range reads {
+		r.reads[name] = struct{}{}
+	}
+	for _, db := range replicas {
+		rep := &replica{db: db}
+		rep.healthy.Store(true)
+		r.replicas = append(r.replicas, rep)
+	}
+
+	return r
+}
+
+// WithStickiness sets how long a caller reads from the primary after
+// writing.
+func (r *Router) WithStickiness(d time.Duration) *Router {
+	r.stickiness = d
+	return r
+}
+
+// WithMaxLag sets the replication lag beyond which a replica is skipped.
+func (r *Router) WithMaxLag(d time.Duration) *Router {
+	r.maxLag = d
+	return r
+}
+
+func (r *Router) ExecContext(
+	ctx context.Context,
+	query string,
+	args ...interface{},
+) (sql.Result, error) {
+	r.recordWrite(ctx)
+	return r.primary.ExecContext(ctx, query, args...)
+}
+
+func (r *Router) PrepareContext(
+	ctx context.Context,
+	query string,
+) (*sql.Stmt, error) {
+	return r.primary.PrepareContext(ctx, query)
+}
+
+func (r *Router) QueryContext(
+	ctx context.Context,
+	query string,
+	args ...interface{},
+) (*sql.Rows, error) {
+	if rep := r.route(ctx, query); rep != nil {
+		rows, err := rep.db.QueryContext(ctx, query, args...)
+		if err == nil || !r.failover(ctx, rep, err) {
+			return rows, err
+		}
+	}
+
+	if r.isWrite(query) {
+		r.recordWrite(ctx)
+	}
+	return r.primary.QueryContext(ctx, query, args...)
+}
+
+func (r *Router) QueryRowContext(
+	ctx context.Context,
+	query string,
+	args ...interface{},
+) *sql.Row {
+	if rep := r.route(ctx, query); rep != nil {
+		row := rep.db.QueryRowContext(ctx, query, args...)
+		if err := row.Err(); err == nil || !r.failover(ctx, rep, err) {
+			return row
+		}
+	}
+
+	if r.isWrite(query) {
+		r.recordWrite(ctx)
+	}
+	return r.primary.QueryRowContext(ctx, query, args...)
+}
+
+// CheckHealth probes every replica, marking those which are unreachable or
+// lagging beyond the maximum as unhealthy.
+func (r *Router) CheckHealth(ctx context.Context) {
+	for _, rep := range r.replicas {
+		var caughtUp bool
+		var lag float64
+		err := rep.db.QueryRowContext(ctx, replicationLag).Scan(&caughtUp, &lag)
+		rep.healthy.Store(
+			err == nil &&
+				(caughtUp || time.Duration(lag*float64(time.Second)) <= r.maxLag),
+		)
+	}
+
+	r.mu.Lock()
+	for caller, at := range r.lastWriteAt {
+		if time.Since(at) > r.stickiness {
+			delete(r.lastWriteAt, caller)
+		}
+	}
+	r.mu.Unlock()
+}
+
+// HealthCheckWorker returns a function which checks replica health every
+// interval until ctx is cancelled, for use as a background worker.
+func (r *Router) HealthCheckWorker(
+	interval time.Duration,
+) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			r.CheckHealth(ctx)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// route returns the replica a read should be sent to, or nil when it must
+// be served by the primary.
+func (r *Router) route(ctx context.Context, query string) *replica {
+	if len(r.replicas) == 0 {
+		return nil
+	}
+	if _, ok := r.reads[QueryName(query)]; !ok {
+		return nil
+	}
+	if r.recentlyWrote(ctx) {
+		return nil
+	}
+
+	start := r.next.Add(1)
+	for i := 0; i < len(r.replicas); i++ {
+		rep := r.replicas[(int(start)+i)%len(r.replicas)]
+		if rep.healthy.Load() {
+			return rep
+		}
+	}
+	return nil
+}
+
+// failover reports whether a failed replica read should be retried against
+// the primary, marking the replica unhealthy if so. Only connection failures
+// are retried; errors in the query itself would fail on the primary too.
+func (r *Router) failover(ctx context.Context, rep *replica, err error) bool {
+	if ctx.Err() != nil || !errors.Is(Classify(err), ErrConnection) {
+		return false
+	}
+	rep.healthy.Store(false)
+	return true
+}
+
+// TrackWrites wraps a DBTX which bypasses the Router, such as a transaction
+// on the primary, so that writes made through it still pin the caller to
+// the primary.
+func (r *Router) TrackWrites(db database.DBTX) database.DBTX {
+	return &writeTracker{DBTX: db, router: r}
+}
+
+type writeTracker struct {
+	database.DBTX
+	router *Router
+}
+
+func (w *writeTracker) ExecContext(
+	ctx context.Context,
+	query string,
+	args ...interface{},
+) (sql.Result, error) {
+	w.router.recordWrite(ctx)
+	return w.DBTX.ExecContext(ctx, query, args...)
+}
+
+func (w *writeTracker) QueryContext(
+	ctx context.Context,
+	query string,
+	args ...interface{},
+) (*sql.Rows, error) {
+	if w.router.isWrite(query) {
+		w.router.recordWrite(ctx)
+	}
+	return w.DBTX.QueryContext(ctx, query, args...)
+}
+
+func (w *writeTracker) QueryRowContext(
+	ctx context.Context,
+	query string,
+	args ...interface{},
+) *sql.Row {
+	if w.router.isWrite(query) {
+		w.router.recordWrite(ctx)
+	}
+	return w.DBTX.QueryRowContext(ctx, query, args...)
+}
+
+// isWrite reports whether query modifies data, such as an INSERT ...
+// RETURNING issued through QueryRowContext. Statements beginning with WITH
+// may modify data in any of their parts, so are writes unless they are among
+// the router's reads.
+func (r *Router) isWrite(query string) bool {
+	for _, line := range strings.Split(query, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "--") {
+			continue
+		}
+
+		switch strings.ToUpper(strings.Fields(line)[0]) {
+		case "INSERT", "UPDATE", "DELETE":
+			return true
+		case "WITH":
+			_, ok := r.reads[QueryName(query)]
+			return !ok
+		}
+		return false
+	}
+	return false
+}
+
+func (r *Router) recordWrite(ctx context.Context) {
+	if len(r.replicas) == 0 {
+		return
+	}
+	caller, err := r.caller(ctx)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	r.lastWriteAt[caller] = time.Now()
+	r.mu.Unlock()
+}
+
+func (r *Router) recentlyWrote(ctx context.Context) bool {
+	caller, err := r.caller(ctx)
+	if err != nil {
+		return false
+	}
+
+	r.mu.Lock()
+	at, ok := r.lastWriteAt[caller]
+	r.mu.Unlock()
+	return ok && time.Since(at) <= r.stickiness
+}
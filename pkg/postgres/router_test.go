@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	getUserQuery    = "-- name: GetUser :one\nSELECT * FROM users"
+	createUserQuery = "-- name: CreateUser :one\nINSERT INTO users"
+)
+
+func newTestRouter(t *testing.T) (*Router, *sql.DB) {
+	// Pools connect lazily, so no database is required to exercise routing.
+	primary, err := sql.Open(DriverName, "postgres://primary")
+	assert.NoError(t, err)
+	replica, err := sql.Open(DriverName, "postgres://replica")
+	assert.NoError(t, err)
+
+	return NewRouter(primary, []*sql.DB{replica}), replica
+}
+
+func callerContext(token string) context.Context {
+	return metadata.NewIncomingContext(
+		context.Background(),
+		metadata.Pairs("authorization", "Bearer "+token),
+	)
+}
+
+func TestRouter_ReadQuery_ShouldRouteToReplica(t *testing.T) {
+	router, replica := newTestRouter(t)
+
+	rep := router.route(callerContext("a"), getUserQuery)
+	assert.NotNil(t, rep)
+	assert.Same(t, replica, rep.db)
+}
+
+func TestRouter_UnlistedQuery_ShouldRouteToPrimary(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	assert.Nil(t, router.route(callerContext("a"), createUserQuery))
+}
+
+func TestRouter_AfterWrite_ShouldPinCallerToPrimary(t *testing.T) {
+	router, _ := newTestRouter(t)
+	router.recordWrite(callerContext("a"))
+
+	assert.Nil(t, router.route(callerContext("a"), getUserQuery))
+	assert.NotNil(t, router.route(callerContext("b"), getUserQuery))
+}
+
+func TestRouter_StickinessElapsed_ShouldRouteToReplica(t *testing.T) {
+	router, _ := newTestRouter(t)
+	router.WithStickiness(time.Millisecond)
+	router.recordWrite(callerContext("a"))
+	time.Sleep(5 * time.Millisecond)
+
+	assert.NotNil(t, router.route(callerContext("a"), getUserQuery))
+}
+
+func TestRouter_UnhealthyReplica_ShouldRouteToPrimary(t *testing.T) {
+	router, _ := newTestRouter(t)
+	router.replicas[0].healthy.Store(false)
+
+	assert.Nil(t, router.route(callerContext("a"), getUserQuery))
+}
+
+func TestIsWrite(t *testing.T) {
+	router := NewRouter(nil, nil, "SearchUsers")
+	assert.True(t, router.isWrite(createUserQuery))
+	assert.True(t, router.isWrite("UPDATE users SET name = $1"))
+	assert.False(t, router.isWrite(getUserQuery))
+	assert.True(t, router.isWrite(
+		"-- name: PurgeUsers :many\nWITH purged AS (DELETE FROM users RETURNING *)\nSELECT * FROM purged",
+	))
+	assert.False(t, router.isWrite(
+		"-- name: SearchUsers :many\nWITH matches AS (SELECT * FROM users)\nSELECT * FROM matches",
+	))
+}
+
+func TestRouter_CheckHealth_ShouldIgnoreReplayAgeOnceCaughtUp(t *testing.T) {
+	cases := []struct {
+		name     string
+		caughtUp bool
+		lag      float64
+		healthy  bool
+	}{
+		// An idle primary leaves the last replayed transaction ever older.
+		{name: "idle primary", caughtUp: true, lag: 3600, healthy: true},
+		{name: "behind within limit", caughtUp: false, lag: 1, healthy: true},
+		{name: "behind beyond limit", caughtUp: false, lag: 60, healthy: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := &testDriver{row: []driver.Value{c.caughtUp, c.lag}}
+			replica := newTestDB(d)
+			defer replica.Close()
+			primary, err := sql.Open(DriverName, "postgres://primary")
+			assert.NoError(t, err)
+
+			router := NewRouter(primary, []*sql.DB{replica})
+			router.CheckHealth(context.Background())
+
+			assert.Equal(t, c.healthy, router.replicas[0].healthy.Load())
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/clintrovert/go-playground/pkg/postgres/database"
+)
+
+const (
+	defaultTxRetries = 3
+	txRetryBackoff   = time.Millisecond * 20
+
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+// TxOptions controls how RunInTx begins and retries a transaction.
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+	// MaxRetries is the number of times a transaction is retried after a
+	// serialization failure or deadlock. Zero uses the default of 3, a
+	// negative value disables retries.
+	MaxRetries int
+}
+
+// TxFunc is the unit of work run by RunInTx. Nested calls to RunInTx must be
+// made with the supplied ctx, which identifies the enclosing transaction.
+type TxFunc func(ctx context.Context, q *database.Queries) error
+
+// Transactor runs units of work within database transactions.
+type Transactor struct {
+	db   *sql.DB
+	wrap func(database.DBTX) database.DBTX
+}
+
+type txKey struct{}
+
+// txState is the transaction shared by a RunInTx call and any calls nested
+// within it.
+type txState struct {
+	tx         *sql.Tx
+	queries    *database.Queries
+	savepoints int
+}
+
+// NewTransactor creates a new instance of a Transactor. When wrap is not nil
+// it is applied to every transaction before queries are built on it, e.g.
+// to trace the statements issued.
+func NewTransactor(
+	db *sql.DB,
+	wrap func(database.DBTX) database.DBTX,
+) *Transactor {
+	return &Transactor{
+		db:   db,
+		wrap: wrap,
+	}
+}
+
+// RunInTx runs fn within a transaction, committing when it returns nil and
+// rolling back when it returns an error or panics. Transactions which fail
+// with a serialization failure or deadlock are retried from the start, so
+// fn must be safe to run more than once. When ctx already belongs to a
+// transaction, fn runs within a savepoint of it instead, and only the work
+// done by fn is rolled back on failure.
+func (t *Transactor) RunInTx(
+	ctx context.Context,
+	opts *TxOptions,
+	fn TxFunc,
+) error {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.savepoint(ctx, fn)
+	}
+
+	if opts == nil {
+		opts = &TxOptions{}
+	}
+	retries := opts.MaxRetries
+	if retries == 0 {
+		retries = defaultTxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := t.run(ctx, opts, fn)
+		if err == nil || !IsRetryable(err) || attempt >= retries {
+			return err
+		}
+
+		// Jitter the backoff so that conflicting transactions do not retry
+		// in lock step.
+		backoff := txRetryBackoff*time.Duration(1<<attempt) +
+			time.Duration(rand.Int63n(int64(txRetryBackoff)))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+func (t *Transactor) run(ctx context.Context, opts *TxOptions, fn TxFunc) (err error) {
+	tx, err := t.db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: opts.Isolation,
+		ReadOnly:  opts.ReadOnly,
+	})
+	if err != nil {
+		return err
+	}
+
+	var dbtx database.DBTX = tx
+	if t.wrap != nil {
+		dbtx = t.wrap(tx)
+	}
+	state := &txState{tx: tx, queries: database.New(dbtx)}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(context.WithValue(ctx, txKey{}, state), state.queries); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *txState) savepoint(ctx context.Context, fn TxFunc) (err error) {
+	s.savepoints++
+	name := fmt.Sprintf("sp_%d", s.savepoints)
+
+	if _, err = s.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_, _ = s.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			panic(p)
+		}
+	}()
+
+	if err = fn(ctx, s.queries); err != nil {
+		if _, rbErr := s.tx.ExecContext(
+			ctx, "ROLLBACK TO SAVEPOINT "+name,
+		); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	_, err = s.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
+
+// IsRetryable reports whether err is a serialization failure or deadlock,
+// after which the whole transaction may be safely retried.
+func IsRetryable(err error) bool {
+	return errors.Is(Classify(err), ErrSerializationFailure)
+}
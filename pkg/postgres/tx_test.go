@@ -0,0 +1,180 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/clintrovert/go-playground/pkg/postgres/database"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var errTestWork = errors.New("work failed")
+
+func newTestTransactor(t *testing.T) (*Transactor, *testDriver) {
+	d := &testDriver{}
+	db := newTestDB(d)
+	t.Cleanup(func() { _ = db.Close() })
+	return NewTransactor(db, nil), d
+}
+
+func TestRunInTx_Success_ShouldCommit(t *testing.T) {
+	tx, d := newTestTransactor(t)
+
+	err := tx.RunInTx(context.Background(), nil, func(
+		ctx context.Context,
+		q *database.Queries,
+	) error {
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"BEGIN Default", "COMMIT"}, d.recorded())
+}
+
+func TestRunInTx_Options_ShouldBeginWithIsolationAndReadOnly(t *testing.T) {
+	tx, d := newTestTransactor(t)
+
+	err := tx.RunInTx(context.Background(), &TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	}, func(context.Context, *database.Queries) error {
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"BEGIN Repeatable Read READ ONLY", "COMMIT"}, d.recorded())
+}
+
+func TestRunInTx_Error_ShouldRollBack(t *testing.T) {
+	tx, d := newTestTransactor(t)
+
+	calls := 0
+	err := tx.RunInTx(context.Background(), nil, func(context.Context, *database.Queries) error {
+		calls++
+		return errTestWork
+	})
+	assert.ErrorIs(t, err, errTestWork)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, []string{"BEGIN Default", "ROLLBACK"}, d.recorded())
+}
+
+func TestRunInTx_Panic_ShouldRollBackAndRepanic(t *testing.T) {
+	tx, d := newTestTransactor(t)
+
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = tx.RunInTx(context.Background(), nil, func(context.Context, *database.Queries) error {
+			panic("boom")
+		})
+	})
+	assert.Equal(t, []string{"BEGIN Default", "ROLLBACK"}, d.recorded())
+}
+
+func TestRunInTx_RetryableErrors_ShouldRetry(t *testing.T) {
+	for _, code := range []pq.ErrorCode{serializationFailure, deadlockDetected} {
+		t.Run(string(code), func(t *testing.T) {
+			tx, d := newTestTransactor(t)
+
+			calls := 0
+			err := tx.RunInTx(context.Background(), nil, func(context.Context, *database.Queries) error {
+				calls++
+				if calls < 3 {
+					return &pq.Error{Code: code}
+				}
+				return nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, 3, calls)
+			assert.Equal(t, []string{
+				"BEGIN Default", "ROLLBACK",
+				"BEGIN Default", "ROLLBACK",
+				"BEGIN Default", "COMMIT",
+			}, d.recorded())
+		})
+	}
+}
+
+func TestRunInTx_MaxRetries_ShouldBoundAttempts(t *testing.T) {
+	cases := []struct {
+		maxRetries int
+		attempts   int
+	}{
+		{maxRetries: 0, attempts: defaultTxRetries + 1},
+		{maxRetries: 1, attempts: 2},
+		{maxRetries: -1, attempts: 1},
+	}
+
+	for _, c := range cases {
+		tx, _ := newTestTransactor(t)
+
+		calls := 0
+		err := tx.RunInTx(
+			context.Background(),
+			&TxOptions{MaxRetries: c.maxRetries},
+			func(context.Context, *database.Queries) error {
+				calls++
+				return &pq.Error{Code: serializationFailure}
+			},
+		)
+		assert.True(t, IsRetryable(err))
+		assert.Equal(t, c.attempts, calls, "MaxRetries %d", c.maxRetries)
+	}
+}
+
+func TestRunInTx_NonRetryableError_ShouldNotRetry(t *testing.T) {
+	tx, _ := newTestTransactor(t)
+
+	calls := 0
+	err := tx.RunInTx(context.Background(), nil, func(context.Context, *database.Queries) error {
+		calls++
+		return &pq.Error{Code: uniqueViolation}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRunInTx_Nested_ShouldUseSavepoints(t *testing.T) {
+	tx, d := newTestTransactor(t)
+
+	err := tx.RunInTx(context.Background(), nil, func(ctx context.Context, _ *database.Queries) error {
+		if err := tx.RunInTx(ctx, nil, func(context.Context, *database.Queries) error {
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		// A failed nested call rolls back only its own work.
+		err := tx.RunInTx(ctx, nil, func(context.Context, *database.Queries) error {
+			return errTestWork
+		})
+		assert.ErrorIs(t, err, errTestWork)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"BEGIN Default",
+		"SAVEPOINT sp_1",
+		"RELEASE SAVEPOINT sp_1",
+		"SAVEPOINT sp_2",
+		"ROLLBACK TO SAVEPOINT sp_2",
+		"COMMIT",
+	}, d.recorded())
+}
+
+func TestRunInTx_NestedPanic_ShouldRollBackSavepoint(t *testing.T) {
+	tx, d := newTestTransactor(t)
+
+	assert.Panics(t, func() {
+		_ = tx.RunInTx(context.Background(), nil, func(ctx context.Context, _ *database.Queries) error {
+			return tx.RunInTx(ctx, nil, func(context.Context, *database.Queries) error {
+				panic("boom")
+			})
+		})
+	})
+	assert.Equal(t, []string{
+		"BEGIN Default",
+		"SAVEPOINT sp_1",
+		"ROLLBACK TO SAVEPOINT sp_1",
+		"ROLLBACK",
+	}, d.recorded())
+}
@@ -0,0 +1,158 @@
+package requestlog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/clintrovert/go-playground/pkg/identity"
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// RequestIdKey is the header used to correlate a request across services.
+	RequestIdKey = "x-request-id"
+
+	maxRequestIdLength = 128
+
+	methodLogField    = "grpc.method"
+	peerLogField      = "peer"
+	callerLogField    = "caller"
+	requestIdLogField = "request_id"
+	codeLogField      = "grpc.code"
+	latencyLogField   = "latency_ms"
+)
+
+type entryKey struct{}
+
+// NewContext returns a copy of ctx carrying the request scoped log entry.
+func NewContext(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, entryKey{}, entry)
+}
+
+// FromContext returns the request scoped log entry stored in ctx, falling
+// back to a plain entry of log when the request was not intercepted.
+func FromContext(ctx context.Context, log *logrus.Logger) *logrus.Entry {
+	if entry, ok := ctx.Value(entryKey{}).(*logrus.Entry); ok {
+		return entry
+	}
+	return logrus.NewEntry(log)
+}
+
+// Interceptor assigns a request ID to every call, exposes a request scoped
+// logger to handlers and writes a single access log line once the call
+// completes.
+type Interceptor struct {
+	log    *logrus.Logger
+	caller identity.CallerFunc
+}
+
+// NewInterceptor creates a new instance of an Interceptor.
+func NewInterceptor(log *logrus.Logger, caller identity.CallerFunc) *Interceptor {
+	if caller == nil {
+		caller = identity.FromAuthorization
+	}
+	return &Interceptor{
+		log:    log,
+		caller: caller,
+	}
+}
+
+func (i *Interceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		request any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		start := time.Now()
+		requestId := requestIdFromContext(ctx)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIdKey, requestId))
+
+		entry := i.entry(ctx, info.FullMethod, requestId)
+		resp, err := handler(NewContext(ctx, entry), request)
+		i.access(entry, start, err)
+
+		return resp, err
+	}
+}
+
+func (i *Interceptor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		start := time.Now()
+		ctx := stream.Context()
+		requestId := requestIdFromContext(ctx)
+		_ = stream.SetHeader(metadata.Pairs(RequestIdKey, requestId))
+
+		entry := i.entry(ctx, info.FullMethod, requestId)
+		wrapped := middleware.WrapServerStream(stream)
+		wrapped.WrappedContext = NewContext(ctx, entry)
+
+		err := handler(srv, wrapped)
+		i.access(entry, start, err)
+
+		return err
+	}
+}
+
+func (i *Interceptor) entry(
+	ctx context.Context,
+	method, requestId string,
+) *logrus.Entry {
+	fields := logrus.Fields{
+		methodLogField:    method,
+		requestIdLogField: requestId,
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields[peerLogField] = p.Addr.String()
+	}
+	if caller, err := i.caller(ctx); err == nil {
+		fields[callerLogField] = caller
+	}
+
+	return i.log.WithFields(fields)
+}
+
+func (i *Interceptor) access(entry *logrus.Entry, start time.Time, err error) {
+	code := status.Code(err)
+	entry = entry.WithFields(logrus.Fields{
+		codeLogField:    code.String(),
+		latencyLogField: time.Since(start).Milliseconds(),
+	})
+
+	switch code {
+	case codes.OK:
+		entry.Info("finished call")
+	case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unavailable:
+		entry.Error("finished call")
+	default:
+		entry.Warn("finished call")
+	}
+}
+
+// requestIdFromContext returns the request ID supplied by the caller, or a
+// freshly generated one when it is absent or unreasonably long.
+func requestIdFromContext(ctx context.Context) string {
+	values := metadata.ValueFromIncomingContext(ctx, RequestIdKey)
+	if len(values) > 0 && values[0] != "" && len(values[0]) <= maxRequestIdLength {
+		return values[0]
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().UTC().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
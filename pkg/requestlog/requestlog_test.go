@@ -0,0 +1,180 @@
+package requestlog
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const getUser = "/playground.UserService/GetUser"
+
+// testTransportStream captures the headers set by the interceptor.
+type testTransportStream struct {
+	header metadata.MD
+}
+
+func (s *testTransportStream) Method() string {
+	return getUser
+}
+
+func (s *testTransportStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *testTransportStream) SendHeader(md metadata.MD) error {
+	return s.SetHeader(md)
+}
+
+func (s *testTransportStream) SetTrailer(metadata.MD) error {
+	return nil
+}
+
+// testServerStream is a server stream capturing the headers it is sent.
+type testServerStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testServerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+type testRequestLog struct {
+	interceptor *Interceptor
+	hook        *test.Hook
+	transport   *testTransportStream
+	ctx         context.Context
+}
+
+func newTestRequestLog(md metadata.MD) *testRequestLog {
+	log, hook := test.NewNullLogger()
+	transport := &testTransportStream{}
+
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	ctx = peer.NewContext(ctx, &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000},
+	})
+	ctx = grpc.NewContextWithServerTransportStream(ctx, transport)
+
+	caller := func(context.Context) (string, error) {
+		return "caller-digest", nil
+	}
+	return &testRequestLog{
+		interceptor: NewInterceptor(log, caller),
+		hook:        hook,
+		transport:   transport,
+		ctx:         ctx,
+	}
+}
+
+func (tr *testRequestLog) call(err error) *logrus.Entry {
+	var handled *logrus.Entry
+	_, _ = tr.interceptor.UnaryServerInterceptor()(
+		tr.ctx,
+		nil,
+		&grpc.UnaryServerInfo{FullMethod: getUser},
+		func(ctx context.Context, _ any) (any, error) {
+			handled = FromContext(ctx, nil)
+			return nil, err
+		},
+	)
+	return handled
+}
+
+func TestUnaryInterceptor_IncomingRequestId_ShouldBeReused(t *testing.T) {
+	tr := newTestRequestLog(metadata.Pairs(RequestIdKey, "abc-123"))
+
+	entry := tr.call(nil)
+
+	assert.Equal(t, "abc-123", entry.Data[requestIdLogField])
+	assert.Equal(t, []string{"abc-123"}, tr.transport.header.Get(RequestIdKey))
+}
+
+func TestUnaryInterceptor_NoRequestId_ShouldGenerateOne(t *testing.T) {
+	tr := newTestRequestLog(metadata.MD{})
+
+	entry := tr.call(nil)
+
+	requestId, ok := entry.Data[requestIdLogField].(string)
+	assert.True(t, ok)
+	assert.Len(t, requestId, 32)
+	assert.Equal(t, []string{requestId}, tr.transport.header.Get(RequestIdKey))
+}
+
+func TestUnaryInterceptor_Entry_ShouldCarryCallFields(t *testing.T) {
+	tr := newTestRequestLog(metadata.MD{})
+
+	entry := tr.call(nil)
+
+	assert.Equal(t, getUser, entry.Data[methodLogField])
+	assert.Equal(t, "10.0.0.1:5000", entry.Data[peerLogField])
+	assert.Equal(t, "caller-digest", entry.Data[callerLogField])
+}
+
+func TestUnaryInterceptor_StatusCodes_ShouldLogOneLineAtLevel(t *testing.T) {
+	cases := []struct {
+		err   error
+		code  codes.Code
+		level logrus.Level
+	}{
+		{nil, codes.OK, logrus.InfoLevel},
+		{status.Error(codes.NotFound, "missing"), codes.NotFound, logrus.WarnLevel},
+		{status.Error(codes.InvalidArgument, "bad"), codes.InvalidArgument, logrus.WarnLevel},
+		{status.Error(codes.Internal, "broken"), codes.Internal, logrus.ErrorLevel},
+		{status.Error(codes.Unavailable, "down"), codes.Unavailable, logrus.ErrorLevel},
+		{errors.New("plain"), codes.Unknown, logrus.ErrorLevel},
+	}
+
+	for _, c := range cases {
+		t.Run(c.code.String(), func(t *testing.T) {
+			tr := newTestRequestLog(metadata.MD{})
+
+			tr.call(c.err)
+
+			assert.Len(t, tr.hook.AllEntries(), 1)
+			access := tr.hook.LastEntry()
+			assert.Equal(t, c.level, access.Level)
+			assert.Equal(t, c.code.String(), access.Data[codeLogField])
+			assert.Contains(t, access.Data, latencyLogField)
+		})
+	}
+}
+
+func TestStreamInterceptor_Stream_ShouldEchoRequestIdAndLogOnce(t *testing.T) {
+	tr := newTestRequestLog(metadata.Pairs(RequestIdKey, "stream-1"))
+	stream := &testServerStream{ctx: tr.ctx}
+
+	var entry *logrus.Entry
+	err := tr.interceptor.StreamServerInterceptor()(
+		nil,
+		stream,
+		&grpc.StreamServerInfo{FullMethod: getUser},
+		func(_ any, stream grpc.ServerStream) error {
+			entry = FromContext(stream.Context(), nil)
+			return nil
+		},
+	)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "stream-1", entry.Data[requestIdLogField])
+	assert.Equal(t, []string{"stream-1"}, stream.header.Get(RequestIdKey))
+	assert.Len(t, tr.hook.AllEntries(), 1)
+	assert.Equal(t, logrus.InfoLevel, tr.hook.LastEntry().Level)
+}
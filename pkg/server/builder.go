@@ -6,18 +6,31 @@ import (
 	"time"
 
 	"github.com/clintrovert/go-playground/pkg/cache"
+	"github.com/clintrovert/go-playground/pkg/deadline"
+	"github.com/clintrovert/go-playground/pkg/idempotency"
+	"github.com/clintrovert/go-playground/pkg/identity"
+	"github.com/clintrovert/go-playground/pkg/requestlog"
+	"github.com/clintrovert/go-playground/pkg/tracing"
 	openmetrics "github.com/grpc-ecosystem/go-grpc-middleware/providers/openmetrics/v2"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/ratelimit"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/validator"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 )
 
 const (
 	tcp = "tcp"
+
+	// goCollectorMetric and processCollectorMetric are exported by the Go
+	// and process collectors respectively.
+	goCollectorMetric      = "go_goroutines"
+	processCollectorMetric = "process_start_time_seconds"
 )
 
 // Builder is a construct
@@ -30,6 +43,10 @@ type Builder struct {
 	auth               *authInterceptorConfig
 	recovery           *recoveryInterceptorConfig
 	cache              *cacheInterceptorConfig
+	deadlines          *deadlineInterceptorConfig
+	idempotency        *idempotencyInterceptorConfig
+	requestLog         *requestLogInterceptorConfig
+	tracing            *tracingInterceptorConfig
 	reflectionEnabled  bool
 	validationEnabled  bool
 }
@@ -43,23 +60,33 @@ func NewBuilder(grpcAddr, httpAddr string) *Builder {
 	}
 }
 
-// WithMetrics adds metrics interceptors for the supplied registerer.
+// WithMetrics adds metrics interceptors and exposes the gRPC metrics, along
+// with Go runtime and process metrics, on the HTTP /metrics endpoint.
+// Collectors the application registers with registerer are merged into the
+// same endpoint, so registerer must also be a prometheus.Gatherer (as a
+// *prometheus.Registry is). When registerer already has the Go or process
+// collectors when Build is called, as prometheus.DefaultRegisterer does, the
+// server does not register them again. A nil registerer serves only the
+// server's own metrics.
 func (b *Builder) WithMetrics(registerer prometheus.Registerer) *Builder {
-	metrics := openmetrics.NewRegisteredServerMetrics(
-		registerer,
+	metrics := openmetrics.NewServerMetrics(
 		openmetrics.WithServerHandlingTimeHistogram(),
 	)
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(metrics)
 
 	b.metrics = &metricsInterceptorConfig{
-		metrics:  metrics,
-		registry: registry,
+		metrics:    metrics,
+		registry:   registry,
+		registerer: registerer,
 	}
 
 	return b
 }
 
+// WithCache serves unary responses from kvc, storing them for ttl under the
+// key keyGenFunc generates for each request. Requests for which keyGenFunc
+// returns an empty key are never cached.
 func (b *Builder) WithCache(
 	kvc cache.KeyValCache,
 	keyGenFunc cache.KeyGenerationFunc,
@@ -74,6 +101,66 @@ func (b *Builder) WithCache(
 	return b
 }
 
+// WithDeadlines bounds the lifetime of every request. Requests which arrive
+// without a deadline receive the default configured for the method in
+// defaultTimeouts, falling back to defaultTimeout, and client supplied
+// deadlines are capped at the maximum configured for the method in
+// maxTimeouts, falling back to the method's default. Both maps are keyed by
+// full method name.
+func (b *Builder) WithDeadlines(
+	defaultTimeout time.Duration,
+	defaultTimeouts map[string]time.Duration,
+	maxTimeouts map[string]time.Duration,
+) *Builder {
+	b.deadlines = &deadlineInterceptorConfig{
+		enforcer: deadline.NewEnforcer(defaultTimeout, maxTimeouts).
+			WithDefaultTimeouts(defaultTimeouts),
+	}
+
+	return b
+}
+
+// WithIdempotency honours the idempotency-key request header on the supplied
+// full method names, storing completed responses in kvc for ttl so that
+// retried requests are replayed rather than executed again.
+func (b *Builder) WithIdempotency(
+	kvc cache.KeyValCache,
+	ttl time.Duration,
+	methods ...string,
+) *Builder {
+	b.idempotency = &idempotencyInterceptorConfig{
+		interceptor: idempotency.NewInterceptor(
+			kvc,
+			identity.FromAuthorization,
+			ttl,
+			methods...,
+		),
+	}
+
+	return b
+}
+
+// WithRequestLogging assigns every request an x-request-id, exposes a request
+// scoped logger to handlers through the context and writes one structured
+// access log line per RPC to log.
+func (b *Builder) WithRequestLogging(log *logrus.Logger) *Builder {
+	b.requestLog = &requestLogInterceptorConfig{
+		interceptor: requestlog.NewInterceptor(log, identity.FromAuthorization),
+	}
+
+	return b
+}
+
+// WithTracing creates a server span per RPC using the supplied provider,
+// continuing traces propagated by callers through W3C trace-context metadata.
+func (b *Builder) WithTracing(tp trace.TracerProvider) *Builder {
+	b.tracing = &tracingInterceptorConfig{
+		interceptor: tracing.NewInterceptor(tp),
+	}
+
+	return b
+}
+
 func (b *Builder) WithRateLimiter(limiter ratelimit.Limiter) *Builder {
 	b.rateLimit = &rateLimitInterceptorConfig{
 		limiter: limiter,
@@ -118,12 +205,54 @@ func (b *Builder) Build() (*Server, error) {
 		return nil, err
 	}
 
-	return &Server{
+	srv := &Server{
 		GrpcServer: grpcServer,
 		HttpServer: httpServer,
 		grpcPort:   b.grpcAddr,
 		httpPort:   b.httpAddr,
-	}, nil
+	}
+
+	if b.metrics != nil {
+		srv.MetricsRegistry = b.metrics.registry
+		if b.metrics.registerer != nil {
+			srv.appMetrics = b.metrics.registerer.(prometheus.Gatherer)
+		}
+		if err = b.registerRuntimeCollectors(srv.appMetrics); err != nil {
+			return nil, err
+		}
+	}
+
+	return srv, nil
+}
+
+// registerRuntimeCollectors registers the Go and process collectors with the
+// server's registry, unless app already exports them, since gathering the
+// same metrics from both would fail every scrape.
+func (b *Builder) registerRuntimeCollectors(app prometheus.Gatherer) error {
+	exported := map[string]bool{}
+	if app != nil {
+		families, err := app.Gather()
+		if err != nil {
+			return err
+		}
+		for _, family := range families {
+			exported[family.GetName()] = true
+		}
+	}
+
+	if !exported[goCollectorMetric] {
+		if err := b.metrics.registry.Register(collectors.NewGoCollector()); err != nil {
+			return err
+		}
+	}
+	if !exported[processCollectorMetric] {
+		if err := b.metrics.registry.Register(
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (b *Builder) generateHttpServer() (*http.Server, error) {
@@ -144,6 +273,30 @@ func (b *Builder) generateGrpcServer() (*grpc.Server, error) {
 	var unaryInterceptors []grpc.UnaryServerInterceptor
 	var streamInterceptors []grpc.StreamServerInterceptor
 
+	if b.tracing != nil && b.tracing.interceptor != nil {
+		unaryInterceptors = append(
+			unaryInterceptors,
+			b.tracing.interceptor.UnaryServerInterceptor(),
+		)
+
+		streamInterceptors = append(
+			streamInterceptors,
+			b.tracing.interceptor.StreamServerInterceptor(),
+		)
+	}
+
+	if b.requestLog != nil && b.requestLog.interceptor != nil {
+		unaryInterceptors = append(
+			unaryInterceptors,
+			b.requestLog.interceptor.UnaryServerInterceptor(),
+		)
+
+		streamInterceptors = append(
+			streamInterceptors,
+			b.requestLog.interceptor.StreamServerInterceptor(),
+		)
+	}
+
 	if b.metrics != nil && b.metrics.registry != nil {
 		unaryInterceptors = append(
 			unaryInterceptors,
@@ -156,6 +309,18 @@ func (b *Builder) generateGrpcServer() (*grpc.Server, error) {
 		)
 	}
 
+	if b.deadlines != nil && b.deadlines.enforcer != nil {
+		unaryInterceptors = append(
+			unaryInterceptors,
+			b.deadlines.enforcer.UnaryServerInterceptor(),
+		)
+
+		streamInterceptors = append(
+			streamInterceptors,
+			b.deadlines.enforcer.StreamServerInterceptor(),
+		)
+	}
+
 	if b.auth != nil && b.auth.authFunc != nil {
 		unaryInterceptors = append(
 			unaryInterceptors,
@@ -192,6 +357,26 @@ func (b *Builder) generateGrpcServer() (*grpc.Server, error) {
 		)
 	}
 
+	// Responses are cached per request, after it is authorized and
+	// validated, so a cached response is never served to a request which
+	// would have been rejected.
+	if b.cache != nil && b.cache.kvc != nil && b.cache.keyGen != nil {
+		unaryInterceptors = append(
+			unaryInterceptors,
+			cache.NewKeyValCacheInterceptor(
+				b.cache.kvc,
+				logrus.NewEntry(logrus.StandardLogger()),
+			).UnaryServerInterceptor(b.cache.keyGen, b.cache.ttl),
+		)
+	}
+
+	if b.idempotency != nil && b.idempotency.interceptor != nil {
+		unaryInterceptors = append(
+			unaryInterceptors,
+			b.idempotency.interceptor.UnaryServerInterceptor(),
+		)
+	}
+
 	grpcServer := grpc.NewServer(
 		grpc.ChainUnaryInterceptor(unaryInterceptors...),
 		grpc.ChainStreamInterceptor(streamInterceptors...),
@@ -206,9 +391,14 @@ func (b *Builder) generateGrpcServer() (*grpc.Server, error) {
 }
 
 func (b *Builder) validateGrpcConfig() error {
-	if b.metrics != nil && b.metrics == nil {
+	if b.metrics != nil && b.metrics.registry == nil {
 		return errors.New("metrics registry was not defined")
 	}
+	if b.metrics != nil && b.metrics.registerer != nil {
+		if _, ok := b.metrics.registerer.(prometheus.Gatherer); !ok {
+			return errors.New("metrics registerer must also be a gatherer")
+		}
+	}
 	return nil
 }
 
@@ -4,6 +4,10 @@ import (
 	"time"
 
 	"github.com/clintrovert/go-playground/pkg/cache"
+	"github.com/clintrovert/go-playground/pkg/deadline"
+	"github.com/clintrovert/go-playground/pkg/idempotency"
+	"github.com/clintrovert/go-playground/pkg/requestlog"
+	"github.com/clintrovert/go-playground/pkg/tracing"
 	openmetrics "github.com/grpc-ecosystem/go-grpc-middleware/providers/openmetrics/v2"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/ratelimit"
@@ -16,8 +20,9 @@ type rateLimitInterceptorConfig struct {
 }
 
 type metricsInterceptorConfig struct {
-	metrics  *openmetrics.ServerMetrics
-	registry *prometheus.Registry
+	metrics    *openmetrics.ServerMetrics
+	registry   *prometheus.Registry
+	registerer prometheus.Registerer
 }
 
 type authInterceptorConfig struct {
@@ -33,3 +38,19 @@ type cacheInterceptorConfig struct {
 	keyGen cache.KeyGenerationFunc
 	ttl    time.Duration
 }
+
+type deadlineInterceptorConfig struct {
+	enforcer *deadline.Enforcer
+}
+
+type idempotencyInterceptorConfig struct {
+	interceptor *idempotency.Interceptor
+}
+
+type requestLogInterceptorConfig struct {
+	interceptor *requestlog.Interceptor
+}
+
+type tracingInterceptorConfig struct {
+	interceptor *tracing.Interceptor
+}
@@ -1,10 +1,16 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/oklog/run"
 	"github.com/prometheus/client_golang/prometheus"
@@ -14,25 +20,101 @@ import (
 
 const (
 	metricsEndpoint = "/metrics"
+	// grpcShutdownGrace bounds how long in-flight RPCs may run on shutdown
+	// before they are cancelled, since streams such as watches never finish
+	// on their own.
+	grpcShutdownGrace = time.Second * 10
 )
 
+var errShutdownSignal = errors.New("shutdown signal received")
+
+// Worker is a background task run alongside the servers. It must return
+// once ctx is cancelled when the server shuts down.
+type Worker func(ctx context.Context) error
+
 type Server struct {
 	grpcPort, httpPort string
 	GrpcServer         *grpc.Server
 	HttpServer         *http.Server
 	MetricsRegistry    *prometheus.Registry
+	appMetrics         prometheus.Gatherer
+	closers            []io.Closer
+	workers            []Worker
+}
+
+// RunInBackground registers workers which run for the lifetime of Serve. A
+// worker failing shuts the server down.
+func (srv *Server) RunInBackground(w ...Worker) {
+	srv.workers = append(srv.workers, w...)
 }
 
+// CloseOnShutdown registers resources, such as database pools, which are
+// closed once the server has stopped serving.
+func (srv *Server) CloseOnShutdown(c ...io.Closer) {
+	srv.closers = append(srv.closers, c...)
+}
+
+// Serve runs the gRPC and HTTP servers until either fails or the process
+// receives SIGINT or SIGTERM, then closes any registered resources.
 func (srv *Server) Serve() {
 	g := &run.Group{}
 	g.Add(srv.serveGrpc())
 	g.Add(srv.serveHttp())
+	g.Add(srv.awaitSignal())
+	for _, w := range srv.workers {
+		g.Add(srv.runWorker(w))
+	}
+
+	err := g.Run()
+	srv.close()
 
-	if err := g.Run(); err != nil {
+	if err != nil && err != errShutdownSignal {
 		os.Exit(1)
 	}
 }
 
+func (srv *Server) close() {
+	// Close in reverse order of registration, mirroring deferred cleanup.
+	for i := len(srv.closers) - 1; i >= 0; i-- {
+		if err := srv.closers[i].Close(); err != nil {
+			log.Printf("failed to close resource on shutdown: %v", err)
+		}
+	}
+}
+
+func (srv *Server) runWorker(w Worker) (execute func() error, interrupt func(error)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	return func() error {
+			if err := w(ctx); err != nil && ctx.Err() == nil {
+				return err
+			}
+			// A worker which finishes early must not stop the servers.
+			<-ctx.Done()
+			return nil
+		}, func(error) {
+			cancel()
+		}
+}
+
+func (srv *Server) awaitSignal() (execute func() error, interrupt func(error)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	return func() error {
+			sig := make(chan os.Signal, 1)
+			signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+			defer signal.Stop(sig)
+
+			select {
+			case <-sig:
+				log.Println("shutdown signal received")
+				return errShutdownSignal
+			case <-ctx.Done():
+				return nil
+			}
+		}, func(error) {
+			cancel()
+		}
+}
+
 func (srv *Server) serveGrpc() (execute func() error, interrupt func(error)) {
 	return func() error {
 			l, err := net.Listen(tcp, srv.grpcPort)
@@ -41,18 +123,33 @@ func (srv *Server) serveGrpc() (execute func() error, interrupt func(error)) {
 			}
 			return srv.GrpcServer.Serve(l)
 		}, func(err error) {
-			srv.GrpcServer.GracefulStop()
+			stopped := make(chan struct{})
+			go func() {
+				srv.GrpcServer.GracefulStop()
+				close(stopped)
+			}()
+
+			timer := time.NewTimer(grpcShutdownGrace)
+			defer timer.Stop()
+			select {
+			case <-stopped:
+			case <-timer.C:
+				log.Println("grpc shutdown grace period elapsed, cancelling in-flight rpcs")
+			}
 			srv.GrpcServer.Stop()
 		}
 }
 
 func (srv *Server) serveHttp() (execute func() error, interrupt func(error)) {
-	httpSrv := &http.Server{Addr: srv.httpPort}
+	httpSrv := srv.HttpServer
+	if httpSrv == nil {
+		httpSrv = &http.Server{Addr: srv.httpPort}
+	}
 	return func() error {
 			m := http.NewServeMux()
 			if srv.MetricsRegistry != nil {
 				m.Handle(metricsEndpoint, promhttp.HandlerFor(
-					srv.MetricsRegistry,
+					srv.metricsGatherer(),
 					promhttp.HandlerOpts{
 						EnableOpenMetrics: true,
 					},
@@ -68,3 +165,12 @@ func (srv *Server) serveHttp() (execute func() error, interrupt func(error)) {
 			}
 		}
 }
+
+// metricsGatherer merges the server's own metrics with those registered by
+// the application.
+func (srv *Server) metricsGatherer() prometheus.Gatherer {
+	if srv.appMetrics == nil {
+		return srv.MetricsRegistry
+	}
+	return prometheus.Gatherers{srv.MetricsRegistry, srv.appMetrics}
+}
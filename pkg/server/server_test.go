@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clintrovert/go-playground/pkg/cache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBuild_WithMetrics_ShouldExposeRegistry(t *testing.T) {
+	app := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "playground_test_total",
+		Help: "Counter registered by the application.",
+	})
+	app.MustRegister(counter)
+	counter.Inc()
+
+	srv, err := NewBuilder(":0", ":0").WithMetrics(app).Build()
+	assert.NoError(t, err)
+	assert.NotNil(t, srv.MetricsRegistry)
+
+	body := scrape(t, srv)
+	assert.Contains(t, body, "go_goroutines")
+	assert.Contains(t, body, "process_start_time_seconds")
+	assert.Contains(t, body, "playground_test_total 1")
+}
+
+func TestBuild_RegistererWithRuntimeCollectors_ShouldScrapeOnce(t *testing.T) {
+	app := prometheus.NewRegistry()
+	app.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	srv, err := NewBuilder(":0", ":0").WithMetrics(app).Build()
+	assert.NoError(t, err)
+
+	body := scrape(t, srv)
+	assert.Equal(t, 1, strings.Count(body, "# TYPE go_goroutines "))
+	assert.Equal(t, 1, strings.Count(body, "# TYPE process_start_time_seconds "))
+}
+
+func TestBuild_WithoutMetrics_ShouldNotExposeRegistry(t *testing.T) {
+	srv, err := NewBuilder(":0", ":0").Build()
+	assert.NoError(t, err)
+	assert.Nil(t, srv.MetricsRegistry)
+}
+
+func TestBuild_WithCache_ShouldServeCachedResponses(t *testing.T) {
+	srv, err := NewBuilder(":0", ":0").
+		WithCache(
+			cache.NewMemoryCache(),
+			func(_ context.Context, _ proto.Message, info *grpc.UnaryServerInfo) (string, error) {
+				return info.FullMethod, nil
+			},
+			time.Minute,
+		).
+		Build()
+	assert.NoError(t, err)
+
+	checker := health.NewServer()
+	grpc_health_v1.RegisterHealthServer(srv.GrpcServer, checker)
+	client := grpc_health_v1.NewHealthClient(dial(t, srv.GrpcServer))
+
+	response, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, response.Status)
+
+	// The changed status is not seen until the cached response expires.
+	checker.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	response, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, response.Status)
+}
+
+// dial serves srv over an in-memory listener, returning a connection to it
+// which is closed along with srv when the test ends.
+func dial(t *testing.T, srv *grpc.Server) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(
+		"bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return conn
+}
+
+func scrape(t *testing.T, srv *Server) string {
+	handler := promhttp.HandlerFor(srv.metricsGatherer(), promhttp.HandlerOpts{})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, metricsEndpoint, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	body, err := io.ReadAll(rec.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/clintrovert/go-playground/pkg/postgres"
+	"github.com/clintrovert/go-playground/pkg/postgres/database"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const unnamedQuery = "query"
+
+// DB wraps a database.DBTX so that every statement issued through
+// database.Queries is recorded as a child span of the active request.
+type DB struct {
+	db database.DBTX
+}
+
+var _ database.DBTX = (*DB)(nil)
+
+// WrapDB creates a new instance of a traced DB.
+func WrapDB(db database.DBTX) *DB {
+	return &DB{db: db}
+}
+
+func (d *DB) ExecContext(
+	ctx context.Context,
+	query string,
+	args ...interface{},
+) (sql.Result, error) {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+
+	res, err := d.db.ExecContext(ctx, query, args...)
+	finishQuery(span, err)
+	return res, err
+}
+
+func (d *DB) PrepareContext(
+	ctx context.Context,
+	query string,
+) (*sql.Stmt, error) {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+
+	stmt, err := d.db.PrepareContext(ctx, query)
+	finishQuery(span, err)
+	return stmt, err
+}
+
+func (d *DB) QueryContext(
+	ctx context.Context,
+	query string,
+	args ...interface{},
+) (*sql.Rows, error) {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	finishQuery(span, err)
+	return rows, err
+}
+
+func (d *DB) QueryRowContext(
+	ctx context.Context,
+	query string,
+	args ...interface{},
+) *sql.Row {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+
+	row := d.db.QueryRowContext(ctx, query, args...)
+	finishQuery(span, row.Err())
+	return row
+}
+
+func startQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	name := postgres.QueryName(query)
+	if name == "" {
+		name = unnamedQuery
+	}
+
+	return StartSpan(
+		ctx,
+		"db."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationKey.String(name),
+			semconv.DBStatementKey.String(query),
+		),
+	)
+}
+
+func finishQuery(span trace.Span, err error) {
+	if err != nil && err != sql.ErrNoRows {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+}
@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ExporterOtlp exports spans to an OTLP collector over gRPC, configured
+	// through the standard OTEL_EXPORTER_OTLP_* environment variables.
+	ExporterOtlp = "otlp"
+	// ExporterStdout writes spans to standard output.
+	ExporterStdout = "stdout"
+
+	instrumentationName = "github.com/clintrovert/go-playground/pkg/tracing"
+)
+
+var ErrExporterUnknown = errors.New("tracing exporter was not recognized")
+
+// NewExporter creates the span exporter identified by kind.
+func NewExporter(
+	ctx context.Context,
+	kind string,
+) (sdktrace.SpanExporter, error) {
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case ExporterOtlp:
+		return otlptracegrpc.New(ctx)
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, ErrExporterUnknown
+	}
+}
+
+// NewTracerProvider creates a TracerProvider which batches spans into the
+// supplied exporter, identifying them with serviceName.
+func NewTracerProvider(
+	exporter sdktrace.SpanExporter,
+	serviceName string,
+) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(serviceName),
+		)),
+	)
+}
+
+// tracerFromContext returns a tracer from the provider which created the
+// span active in ctx, so that child spans are only recorded for requests
+// which are already being traced.
+func tracerFromContext(ctx context.Context) trace.Tracer {
+	return trace.SpanFromContext(ctx).TracerProvider().Tracer(instrumentationName)
+}
+
+// StartSpan starts a child of the span active in ctx. When ctx carries no
+// span the returned span is a no-op.
+func StartSpan(
+	ctx context.Context,
+	name string,
+	opts ...trace.SpanStartOption,
+) (context.Context, trace.Span) {
+	return tracerFromContext(ctx).Start(ctx, name, opts...)
+}
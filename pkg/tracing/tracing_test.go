@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	traceId  = "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentId = "00f067aa0ba902b7"
+)
+
+type fakeDB struct{}
+
+func (fakeDB) ExecContext(
+	context.Context, string, ...interface{},
+) (sql.Result, error) {
+	return nil, nil
+}
+
+func (fakeDB) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, nil
+}
+
+func (fakeDB) QueryContext(
+	context.Context, string, ...interface{},
+) (*sql.Rows, error) {
+	return nil, nil
+}
+
+func (fakeDB) QueryRowContext(
+	context.Context, string, ...interface{},
+) *sql.Row {
+	return &sql.Row{}
+}
+
+func TestInterceptor_PropagatedContext_ShouldContinueTrace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	interceptor := NewInterceptor(tp).UnaryServerInterceptor()
+	db := WrapDB(fakeDB{})
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"traceparent", "00-"+traceId+"-"+parentId+"-01",
+	))
+	info := &grpc.UnaryServerInfo{
+		FullMethod: "/playground.UserService/DeleteUser",
+	}
+	handler := func(ctx context.Context, req any) (any, error) {
+		_, err := db.ExecContext(ctx, "-- name: DeleteUser :exec\nDELETE")
+		return nil, err
+	}
+
+	_, err := interceptor(ctx, nil, info, handler)
+	assert.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 2)
+
+	query, server := spans[0], spans[1]
+	assert.Equal(t, "db.DeleteUser", query.Name)
+	assert.Equal(t, trace.SpanKindClient, query.SpanKind)
+	assert.Equal(t, server.SpanContext.SpanID(), query.Parent.SpanID())
+
+	assert.Equal(t, "playground.UserService/DeleteUser", server.Name)
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind)
+	assert.Equal(t, traceId, server.SpanContext.TraceID().String())
+	assert.Equal(t, parentId, server.Parent.SpanID().String())
+	assert.True(t, server.Parent.IsRemote())
+}
+
+func TestWrapDB_NoActiveSpan_ShouldNotRecord(t *testing.T) {
+	// Queries outside a traced request must not fall back to the global
+	// provider.
+	exporter := tracetest.NewInMemoryExporter()
+	globalTp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(globalTp)
+	defer func() {
+		otel.SetTracerProvider(previous)
+		_ = globalTp.Shutdown(context.Background())
+	}()
+
+	_, err := WrapDB(fakeDB{}).ExecContext(context.Background(), "SELECT 1")
+	assert.NoError(t, err)
+	assert.Empty(t, exporter.GetSpans())
+}